@@ -0,0 +1,241 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+	"github.com/sourcegraph/conc/iter"
+)
+
+// resourceActionsPath is the API path of the resource types that support actions queries.
+var resourceActionsPath = map[ResourceType]string{
+	ResourceTypeServer:       "servers",
+	ResourceTypeLoadBalancer: "load_balancers",
+}
+
+// resourceAction is an action of one of the selected resources.
+type resourceAction struct {
+	name   string
+	action *hcloud.Action
+}
+
+func (qm QueryModel) validateActions() error {
+	if err := qm.validate(); err != nil {
+		return err
+	}
+
+	if len(qm.ResourceTypes) > 0 {
+		return errors.New("resourceTypes is only supported by resource list queries, use resourceType instead")
+	}
+	if _, ok := resourceActionsPath[qm.ResourceType]; !ok {
+		return fmt.Errorf("actions are only supported for resourceType %s and %s, got %q", ResourceTypeServer, ResourceTypeLoadBalancer, qm.ResourceType)
+	}
+	if qm.SelectBy == "" {
+		return errors.New("selectBy is required")
+	}
+
+	return nil
+}
+
+// queryActions returns the actions (ie. reboots or rescales) of the selected resources that were started in the time
+// range, as a frame that Grafana can use for annotations. Resources are selected like in metrics queries, so label
+// selectors work for whole fleets.
+func (d *Datasource) queryActions(ctx context.Context, query backend.DataQuery) backend.DataResponse {
+	var qm QueryModel
+	err := json.Unmarshal(query.JSON, &qm)
+	if err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("json unmarshal: %v", err.Error()))
+	}
+
+	if err := qm.validateActions(); err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("invalid query: %v", err))
+	}
+
+	if d.isEmptySelection(qm) {
+		return emptySelectionResponse()
+	}
+
+	resourceIDs, err := d.GetResourceIDs(ctx, qm)
+	if err != nil {
+		err = NicerErrorMessages(err)
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourceDownstream, fmt.Sprintf("failed to resolve resources: %v", err.Error()))
+	}
+
+	type result struct {
+		actions []resourceAction
+		err     error
+	}
+	results := iter.Map(resourceIDs, func(id *int64) result {
+		actions, err := d.getResourceActions(ctx, qm.ResourceType, *id, query.TimeRange)
+		if err != nil {
+			return result{err: err}
+		}
+
+		name, err := d.resourceName(ctx, qm.ResourceType, *id)
+		if err != nil {
+			logger.FromContext(ctx).Warn("failed to get resource name", "resourceType", qm.ResourceType, "id", *id, "error", err)
+			name = strconv.FormatInt(*id, 10)
+		}
+
+		resourceActions := make([]resourceAction, 0, len(actions))
+		for _, action := range actions {
+			resourceActions = append(resourceActions, resourceAction{name: name, action: action})
+		}
+		return result{actions: resourceActions}
+	})
+
+	var actions []resourceAction
+	for _, r := range results {
+		if r.err != nil {
+			err = NicerErrorMessages(r.err)
+			return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, fmt.Sprintf("error getting actions: %v", err.Error()))
+		}
+		actions = append(actions, r.actions...)
+	}
+
+	return backend.DataResponse{Frames: data.Frames{actionsToFrame(actions, query.TimeRange.To)}}
+}
+
+// resourceName returns the cached name of a server or load balancer.
+func (d *Datasource) resourceName(ctx context.Context, resourceType ResourceType, id int64) (string, error) {
+	if resourceType == ResourceTypeLoadBalancer {
+		return d.nameCacheLoadBalancer.Get(ctx, id)
+	}
+	return d.nameCacheServer.Get(ctx, id)
+}
+
+// getResourceActions returns the actions of a single resource that were started in the time range.
+//
+// hcloud-go only lists the actions of all resources of a type, which can be a lot in large projects. The per-resource
+// endpoint is requested directly instead.
+func (d *Datasource) getResourceActions(ctx context.Context, resourceType ResourceType, id int64, timeRange backend.TimeRange) ([]*hcloud.Action, error) {
+	return d.listActions(ctx, fmt.Sprintf("/%s/%d/actions", resourceActionsPath[resourceType], id), timeRange)
+}
+
+// listActions returns the actions of an actions endpoint (ie. "/servers/1/actions") that were started in the time
+// range. Actions are sorted by their start, so pagination stops at the first action that was started before the time
+// range.
+func (d *Datasource) listActions(ctx context.Context, path string, timeRange backend.TimeRange) ([]*hcloud.Action, error) {
+	var actions []*hcloud.Action
+
+	for page := 1; page > 0; {
+		params := url.Values{
+			"page":     []string{strconv.Itoa(page)},
+			"per_page": []string{strconv.Itoa(ResourceListPerPage)},
+			"sort":     []string{"started:desc"},
+		}
+		req, err := d.client.NewRequest(ctx, http.MethodGet, path+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var body schema.ActionListResponse
+		resp, err := d.client.Do(req, &body)
+		if err != nil {
+			if hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+				// The resource was deleted after the selection was resolved
+				return actions, nil
+			}
+			return nil, err
+		}
+
+		for _, actionSchema := range body.Actions {
+			action := hcloud.ActionFromSchema(actionSchema)
+			if action.Started.Before(timeRange.From) {
+				return actions, nil
+			}
+			if !action.Started.After(timeRange.To) {
+				actions = append(actions, action)
+			}
+		}
+
+		page = 0
+		if resp.Meta.Pagination != nil {
+			page = resp.Meta.Pagination.NextPage
+		}
+	}
+
+	return actions, nil
+}
+
+// actionsToFrame returns an annotations frame with one row per action. Actions with the same text that were started at
+// the same time (ie. when the same command was run for many resources at once) are combined into a single row, with
+// the names of all resources in the tags. Running actions end at the end of the time range.
+func actionsToFrame(actions []resourceAction, end time.Time) *data.Frame {
+	type annotation struct {
+		time    time.Time
+		timeEnd time.Time
+		title   string
+		text    string
+		tags    []string
+	}
+
+	var annotations []*annotation
+	byKey := make(map[string]*annotation)
+
+	for _, ra := range actions {
+		text := fmt.Sprintf("%s (%s)", ra.action.Command, ra.action.Status)
+		if ra.action.ErrorCode != "" && ra.action.ErrorMessage != "" {
+			// Not [hcloud.Action.Error], it contains the ID of the action, so failed actions would never be combined
+			text += fmt.Sprintf(": %s (%s)", ra.action.ErrorMessage, ra.action.ErrorCode)
+		}
+
+		key := strconv.FormatInt(ra.action.Started.UnixNano(), 10) + " " + text
+		if existing, ok := byKey[key]; ok {
+			if !slices.Contains(existing.tags, ra.name) {
+				existing.tags = append(existing.tags, ra.name)
+			}
+			continue
+		}
+
+		timeEnd := ra.action.Finished
+		if timeEnd.IsZero() {
+			timeEnd = end
+		}
+
+		a := &annotation{
+			time:    ra.action.Started,
+			timeEnd: timeEnd,
+			title:   ra.action.Command,
+			text:    text,
+			tags:    []string{string(ra.action.Status), ra.name},
+		}
+		byKey[key] = a
+		annotations = append(annotations, a)
+	}
+
+	slices.SortStableFunc(annotations, func(a, b *annotation) int { return a.time.Compare(b.time) })
+
+	times := make([]time.Time, 0, len(annotations))
+	timeEnds := make([]time.Time, 0, len(annotations))
+	titles := make([]string, 0, len(annotations))
+	texts := make([]string, 0, len(annotations))
+	tags := make([]string, 0, len(annotations))
+	for _, a := range annotations {
+		times = append(times, a.time)
+		timeEnds = append(timeEnds, a.timeEnd)
+		titles = append(titles, a.title)
+		texts = append(texts, a.text)
+		tags = append(tags, strings.Join(a.tags, ","))
+	}
+
+	return data.NewFrame("actions",
+		data.NewField("time", nil, times),
+		data.NewField("timeEnd", nil, timeEnds),
+		data.NewField("title", nil, titles),
+		data.NewField("text", nil, texts),
+		data.NewField("tags", nil, tags),
+	)
+}
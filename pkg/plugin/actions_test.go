@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func Test_actionsToFrame(t *testing.T) {
+	t1 := time.Unix(60, 0)
+	t2 := time.Unix(120, 0)
+	end := time.Unix(600, 0)
+
+	actions := []resourceAction{
+		{name: "web-2", action: &hcloud.Action{Command: "reboot_server", Status: hcloud.ActionStatusSuccess, Started: t2, Finished: t2.Add(time.Second)}},
+		{name: "web-1", action: &hcloud.Action{Command: "reboot_server", Status: hcloud.ActionStatusSuccess, Started: t2, Finished: t2.Add(time.Second)}},
+		{name: "web-1", action: &hcloud.Action{Command: "change_server_type", Status: hcloud.ActionStatusRunning, Started: t1}},
+		{name: "web-3", action: &hcloud.Action{ID: 3, Command: "reboot_server", Status: hcloud.ActionStatusError, Started: t2, ErrorCode: "server_error", ErrorMessage: "Server failed"}},
+		{name: "web-4", action: &hcloud.Action{ID: 4, Command: "reboot_server", Status: hcloud.ActionStatusError, Started: t2, ErrorCode: "server_error", ErrorMessage: "Server failed"}},
+	}
+
+	frame := actionsToFrame(actions, end)
+	if frame.Rows() != 3 {
+		t.Fatalf("actionsToFrame() returned %d rows, want 3", frame.Rows())
+	}
+
+	want := []struct {
+		time    time.Time
+		timeEnd time.Time
+		text    string
+		tags    string
+	}{
+		{time: t1, timeEnd: end, text: "change_server_type (running)", tags: "running,web-1"},
+		{time: t2, timeEnd: t2.Add(time.Second), text: "reboot_server (success)", tags: "success,web-2,web-1"},
+		{time: t2, timeEnd: end, text: "reboot_server (error): Server failed (server_error)", tags: "error,web-3,web-4"},
+	}
+	for i, w := range want {
+		if got := frame.Fields[0].At(i).(time.Time); !got.Equal(w.time) {
+			t.Errorf("row %d time = %v, want %v", i, got, w.time)
+		}
+		if got := frame.Fields[3].At(i).(string); got != w.text {
+			t.Errorf("row %d text = %q, want %q", i, got, w.text)
+		}
+		if got := frame.Fields[4].At(i).(string); got != w.tags {
+			t.Errorf("row %d tags = %q, want %q", i, got, w.tags)
+		}
+		if got := frame.Fields[1].At(i).(time.Time); !got.Equal(w.timeEnd) {
+			t.Errorf("row %d timeEnd = %v, want %v", i, got, w.timeEnd)
+		}
+	}
+}
+
+func TestQueryModel_validateActions(t *testing.T) {
+	valid := QueryModel{ResourceType: ResourceTypeServer, SelectBy: SelectByLabel, LabelSelectors: []string{"env=prod"}}
+	if err := valid.validateActions(); err != nil {
+		t.Errorf("validateActions() error = %v, want nil", err)
+	}
+
+	invalid := QueryModel{ResourceType: ResourceTypeNetwork, SelectBy: SelectByLabel}
+	if err := invalid.validateActions(); err == nil {
+		t.Errorf("validateActions() should reject resource types without actions")
+	}
+}
@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type Aggregation string
+
+const (
+	AggregationNone Aggregation = "none"
+	AggregationSum  Aggregation = "sum"
+	AggregationAvg  Aggregation = "avg"
+	AggregationMax  Aggregation = "max"
+)
+
+// Validate returns an error if the aggregation is unknown. An empty aggregation is treated as [AggregationNone].
+func (a Aggregation) Validate() error {
+	switch a {
+	case "", AggregationNone, AggregationSum, AggregationAvg, AggregationMax:
+		return nil
+	default:
+		return fmt.Errorf("unknown aggregation: %q", a)
+	}
+}
+
+// Enabled returns true if the series of multiple resources should be combined.
+func (a Aggregation) Enabled() bool {
+	return a != "" && a != AggregationNone
+}
+
+// combine calculates the aggregated value. values must not be empty.
+func (a Aggregation) combine(values []float64) float64 {
+	switch a {
+	case AggregationSum, AggregationAvg:
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+		if a == AggregationAvg {
+			return sum / float64(len(values))
+		}
+		return sum
+	case AggregationMax:
+		result := math.Inf(-1)
+		for _, value := range values {
+			result = math.Max(result, value)
+		}
+		return result
+	default:
+		return math.NaN()
+	}
+}
+
+// aggregateFrames combines the frames of all resources into a single frame per series name.
+//
+// The API returns the same timestamps for all resources in most cases, but this is not guaranteed. To handle
+// mismatched timestamps, every timestamp is aligned to the common grid of the step size (multiples of step seconds
+// since the unix epoch). All values that fall on the same grid point are combined. Null values are ignored, and grid
+// points where every resource returned null stay null.
+//
+// The returned frames are sorted by series name and carry the aggregation as [LabelName], the [LabelID] is removed.
+func aggregateFrames(frames []*data.Frame, aggregation Aggregation, step int, legendFormat string) []*data.Frame {
+	if step < 1 {
+		step = 1
+	}
+
+	type seriesGroup struct {
+		labels data.Labels
+		// config is taken from the first frame of the series, ie. with the unit and thresholds
+		config data.FieldConfig
+		values map[int64][]float64
+	}
+
+	groups := make(map[string]*seriesGroup)
+
+	for _, frame := range frames {
+		if len(frame.Fields) < 2 {
+			continue
+		}
+		timeField := frame.Fields[0]
+		valuesField := frame.Fields[len(frame.Fields)-1]
+
+		seriesName := valuesField.Labels[LabelSeriesName]
+		group, ok := groups[seriesName]
+		if !ok {
+			labels := make(data.Labels, len(valuesField.Labels))
+			for k, v := range valuesField.Labels {
+				labels[k] = v
+			}
+			delete(labels, LabelID)
+			labels[LabelName] = string(aggregation)
+
+			group = &seriesGroup{labels: labels, values: make(map[int64][]float64)}
+			if valuesField.Config != nil {
+				group.config = *valuesField.Config
+			}
+			groups[seriesName] = group
+		}
+
+		for i := 0; i < valuesField.Len(); i++ {
+			timestamp, ok := timeField.At(i).(time.Time)
+			if !ok {
+				continue
+			}
+			gridPoint := timestamp.Unix() - timestamp.Unix()%int64(step)
+
+			value, _ := valuesField.At(i).(*float64)
+			if value == nil {
+				// Keep the grid point, so it shows up as a gap if no other resource has a value
+				if _, ok := group.values[gridPoint]; !ok {
+					group.values[gridPoint] = nil
+				}
+				continue
+			}
+			group.values[gridPoint] = append(group.values[gridPoint], *value)
+		}
+	}
+
+	seriesNames := make([]string, 0, len(groups))
+	for seriesName := range groups {
+		seriesNames = append(seriesNames, seriesName)
+	}
+	slices.Sort(seriesNames)
+
+	aggregated := make([]*data.Frame, 0, len(groups))
+	for _, seriesName := range seriesNames {
+		group := groups[seriesName]
+
+		gridPoints := make([]int64, 0, len(group.values))
+		for gridPoint := range group.values {
+			gridPoints = append(gridPoints, gridPoint)
+		}
+		slices.Sort(gridPoints)
+
+		timestamps := make([]time.Time, 0, len(gridPoints))
+		values := make([]*float64, 0, len(gridPoints))
+		for _, gridPoint := range gridPoints {
+			timestamps = append(timestamps, time.Unix(gridPoint, 0))
+
+			if len(group.values[gridPoint]) == 0 {
+				values = append(values, nil)
+				continue
+			}
+			value := aggregation.combine(group.values[gridPoint])
+			values = append(values, &value)
+		}
+
+		valuesField := data.NewField(seriesName, group.labels, values)
+		config := group.config
+		config.DisplayNameFromDS = getDisplayName(legendFormat, group.labels)
+		valuesField.Config = &config
+
+		aggregated = append(aggregated, data.NewFrame("",
+			data.NewField("time", nil, timestamps),
+			valuesField,
+		))
+	}
+
+	return aggregated
+}
@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func Test_aggregateFrames(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	frame := func(id string, timestamps []int64, values []*float64) *data.Frame {
+		times := make([]time.Time, 0, len(timestamps))
+		for _, ts := range timestamps {
+			times = append(times, time.Unix(ts, 0))
+		}
+		return data.NewFrame("",
+			data.NewField("time", nil, times),
+			data.NewField("cpu", data.Labels{LabelID: id, LabelName: "server-" + id, LabelSeriesName: "cpu", LabelSeriesDisplayName: "Usage"}, values),
+		)
+	}
+
+	frames := []*data.Frame{
+		frame("1", []int64{60, 120, 180}, []*float64{ptr(1), ptr(2), nil}),
+		// Timestamps are slightly off the grid and should be aligned
+		frame("2", []int64{61, 121, 181}, []*float64{ptr(3), ptr(6), nil}),
+	}
+
+	tests := []struct {
+		aggregation Aggregation
+		want        []*float64
+	}{
+		{aggregation: AggregationSum, want: []*float64{ptr(4), ptr(8), nil}},
+		{aggregation: AggregationAvg, want: []*float64{ptr(2), ptr(4), nil}},
+		{aggregation: AggregationMax, want: []*float64{ptr(3), ptr(6), nil}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.aggregation), func(t *testing.T) {
+			got := aggregateFrames(frames, tt.aggregation, 60, "")
+			if len(got) != 1 {
+				t.Fatalf("aggregateFrames() returned %d frames, want 1", len(got))
+			}
+
+			timeField, valuesField := got[0].Fields[0], got[0].Fields[1]
+			if valuesField.Len() != len(tt.want) {
+				t.Fatalf("aggregateFrames() returned %d values, want %d", valuesField.Len(), len(tt.want))
+			}
+
+			for i, want := range tt.want {
+				if ts := timeField.At(i).(time.Time).Unix(); ts != int64(60*(i+1)) {
+					t.Errorf("timestamp at index %d = %d, want %d", i, ts, 60*(i+1))
+				}
+
+				value := valuesField.At(i).(*float64)
+				switch {
+				case want == nil && value != nil:
+					t.Errorf("value at index %d = %v, want nil", i, *value)
+				case want != nil && (value == nil || *value != *want):
+					t.Errorf("value at index %d = %v, want %v", i, value, *want)
+				}
+			}
+
+			if _, ok := valuesField.Labels[LabelID]; ok {
+				t.Errorf("aggregated series should not have an id label")
+			}
+			if name := valuesField.Labels[LabelName]; name != string(tt.aggregation) {
+				t.Errorf("aggregated series name label = %q, want %q", name, tt.aggregation)
+			}
+		})
+	}
+}
+
+func Test_aggregateFrames_config(t *testing.T) {
+	thresholds := &data.ThresholdsConfig{
+		Mode:  data.ThresholdsModeAbsolute,
+		Steps: []data.Threshold{{Value: 80, Color: "red"}},
+	}
+	frame := func(id string) *data.Frame {
+		valuesField := data.NewField("cpu", data.Labels{LabelID: id, LabelName: "server-" + id, LabelSeriesName: "cpu", LabelSeriesDisplayName: "Usage"}, []*float64{nil})
+		valuesField.Config = &data.FieldConfig{
+			Unit:              "percent",
+			DisplayNameFromDS: "server-" + id,
+			Thresholds:        thresholds,
+		}
+		return data.NewFrame("", data.NewField("time", nil, []time.Time{time.Unix(60, 0)}), valuesField)
+	}
+
+	got := aggregateFrames([]*data.Frame{frame("1"), frame("2")}, AggregationSum, 60, "")
+	if len(got) != 1 {
+		t.Fatalf("aggregateFrames() returned %d frames, want 1", len(got))
+	}
+
+	config := got[0].Fields[1].Config
+	if config == nil {
+		t.Fatalf("aggregated series has no config")
+	}
+	if config.Unit != "percent" {
+		t.Errorf("unit = %q, want %q", config.Unit, "percent")
+	}
+	if config.Thresholds != thresholds {
+		t.Errorf("thresholds = %v, want %v", config.Thresholds, thresholds)
+	}
+	if config.DisplayNameFromDS == "server-1" {
+		t.Errorf("display name should be derived from the aggregated series, got %q", config.DisplayNameFromDS)
+	}
+}
@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// FromAlertHeader is set by Grafana on all requests that are sent to evaluate alert rules.
+const FromAlertHeader = "FromAlert"
+
+// wideFrames converts the time series frames of a query into a single wide frame. It is used for alert rules and for
+// queries with [OutputFormatWide].
+//
+// Dashboards get one frame per series by default, which is the best format for graphs. The reduce and threshold
+// expressions of alert rules and some transformations work more predictably with a single frame that has one numeric
+// field per series. The fields are named after the resource and series (ie. "webserver cpu") and keep their labels,
+// so every series becomes its own alert instance. Every numeric field of a frame is a series, so frames with multiple
+// value fields (ie. [emptyMetricsFrame]) are supported. Timestamps of all series are combined, series without a value
+// for a timestamp are null.
+//
+// Frames without fields (ie. the notice for an empty selection) are dropped, their notices are kept on the combined
+// frame.
+func wideFrames(frames []*data.Frame) []*data.Frame {
+	combined := data.NewFrame("")
+
+	type series struct {
+		field  *data.Field
+		values map[time.Time]*float64
+	}
+	var allSeries []series
+	timestamps := make(map[time.Time]bool)
+
+	for _, frame := range frames {
+		if frame.Meta != nil && len(frame.Meta.Notices) > 0 {
+			combined.AppendNotices(frame.Meta.Notices...)
+		}
+		if len(frame.Fields) < 2 {
+			continue
+		}
+
+		timeField := frame.Fields[0]
+		for _, valuesField := range frame.Fields[1:] {
+			if !valuesField.Type().Numeric() {
+				continue
+			}
+
+			s := series{field: valuesField, values: make(map[time.Time]*float64, valuesField.Len())}
+			for i := 0; i < valuesField.Len(); i++ {
+				timestamp, ok := timeField.At(i).(time.Time)
+				if !ok {
+					continue
+				}
+				value, _ := valuesField.NullableFloatAt(i)
+				s.values[timestamp] = value
+				timestamps[timestamp] = true
+			}
+			allSeries = append(allSeries, s)
+		}
+	}
+
+	if len(allSeries) == 0 {
+		return data.Frames{combined}
+	}
+
+	sortedTimestamps := make([]time.Time, 0, len(timestamps))
+	for timestamp := range timestamps {
+		sortedTimestamps = append(sortedTimestamps, timestamp)
+	}
+	slices.SortFunc(sortedTimestamps, func(a, b time.Time) int { return a.Compare(b) })
+
+	combined.Fields = append(combined.Fields, data.NewField("time", nil, sortedTimestamps))
+	for _, s := range allSeries {
+		values := make([]*float64, 0, len(sortedTimestamps))
+		for _, timestamp := range sortedTimestamps {
+			values = append(values, s.values[timestamp])
+		}
+
+		field := data.NewField(wideFieldName(s.field.Labels), s.field.Labels, values)
+		field.Config = s.field.Config
+		combined.Fields = append(combined.Fields, field)
+	}
+
+	return data.Frames{combined}
+}
+
+// wideFieldName returns the name of the resource, followed by the name of the series and the offset of a previous
+// period if available.
+func wideFieldName(labels data.Labels) string {
+	name := labels[LabelName]
+	if name == "" {
+		name = labels[LabelID]
+	}
+
+	fieldName := strings.TrimSpace(name + " " + labels[LabelSeriesName])
+	if offset := labels[LabelOffset]; offset != "" {
+		fieldName += " offset " + offset
+	}
+	return fieldName
+}
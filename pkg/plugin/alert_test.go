@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func Test_wideFrames(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	newFrame := func(id, name string, timestamps []time.Time, values []*float64) *data.Frame {
+		return data.NewFrame("",
+			data.NewField("time", nil, timestamps),
+			data.NewField("cpu", data.Labels{LabelID: id, LabelName: name, LabelSeriesName: "cpu"}, values),
+		)
+	}
+
+	t1 := time.Unix(60, 0)
+	t2 := time.Unix(120, 0)
+
+	frames := []*data.Frame{
+		newFrame("1", "webserver", []time.Time{t1, t2}, []*float64{ptr(1), ptr(2)}),
+		newFrame("2", "database", []time.Time{t2}, []*float64{ptr(3)}),
+		missingResourceFrame(3, "", ResourceTypeServer, ""),
+	}
+
+	got := wideFrames(frames)
+	if len(got) != 1 {
+		t.Fatalf("wideFrames() returned %d frames, want 1", len(got))
+	}
+
+	frame := got[0]
+	if len(frame.Fields) != 4 {
+		t.Fatalf("wideFrames() returned %d fields, want time and one field per series", len(frame.Fields))
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 {
+		t.Errorf("wideFrames() should keep the notices of all frames, got %v", frame.Meta)
+	}
+
+	wantNames := []string{"time", "webserver cpu", "database cpu", "3"}
+	for i, want := range wantNames {
+		if got := frame.Fields[i].Name; got != want {
+			t.Errorf("field %d name = %q, want %q", i, got, want)
+		}
+	}
+
+	if value := frame.Fields[2].At(0).(*float64); value != nil {
+		t.Errorf("missing value should be null, got %v", *value)
+	}
+	if value := frame.Fields[2].At(1).(*float64); value == nil || *value != 3 {
+		t.Errorf("value of database at t2 = %v, want 3", value)
+	}
+}
+
+func Test_wideFrames_multipleValueFields(t *testing.T) {
+	t1 := time.Unix(60, 0)
+	frame := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{t1}),
+		data.NewField("in", data.Labels{LabelName: "lb", LabelSeriesName: "in"}, []*float64{hcloud.Ptr(1.0)}),
+		data.NewField("state", nil, []string{"ok"}),
+		data.NewField("out", data.Labels{LabelName: "lb", LabelSeriesName: "out"}, []int64{2}),
+	)
+
+	got := wideFrames([]*data.Frame{frame})
+
+	var names []string
+	for _, field := range got[0].Fields {
+		names = append(names, field.Name)
+	}
+	if want := []string{"time", "lb in", "lb out"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("wideFrames() fields = %v, want %v", names, want)
+	}
+	if value := got[0].Fields[2].At(0).(*float64); value == nil || *value != 2 {
+		t.Errorf("value of lb out = %v, want 2", value)
+	}
+}
+
+func Test_wideFrames_noSeries(t *testing.T) {
+	got := wideFrames(emptySelectionResponse().Frames)
+	if len(got) != 1 || len(got[0].Fields) != 0 {
+		t.Errorf("wideFrames() should return a single frame without fields, got %v", got)
+	}
+}
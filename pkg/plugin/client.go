@@ -0,0 +1,31 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// ServerClient is the part of [hcloud.ServerClient] that is used by the data source. Tests replace it with a fake, so
+// the query logic can be tested without sending requests to the API.
+type ServerClient interface {
+	GetByID(ctx context.Context, id int64) (*hcloud.Server, *hcloud.Response, error)
+	List(ctx context.Context, opts hcloud.ServerListOpts) ([]*hcloud.Server, *hcloud.Response, error)
+	All(ctx context.Context) ([]*hcloud.Server, error)
+	AllWithOpts(ctx context.Context, opts hcloud.ServerListOpts) ([]*hcloud.Server, error)
+	GetMetrics(ctx context.Context, server *hcloud.Server, opts hcloud.ServerGetMetricsOpts) (*hcloud.ServerMetrics, *hcloud.Response, error)
+}
+
+// LoadBalancerClient is the part of [hcloud.LoadBalancerClient] that is used by the data source, see [ServerClient].
+type LoadBalancerClient interface {
+	GetByID(ctx context.Context, id int64) (*hcloud.LoadBalancer, *hcloud.Response, error)
+	List(ctx context.Context, opts hcloud.LoadBalancerListOpts) ([]*hcloud.LoadBalancer, *hcloud.Response, error)
+	All(ctx context.Context) ([]*hcloud.LoadBalancer, error)
+	AllWithOpts(ctx context.Context, opts hcloud.LoadBalancerListOpts) ([]*hcloud.LoadBalancer, error)
+	GetMetrics(ctx context.Context, lb *hcloud.LoadBalancer, opts hcloud.LoadBalancerGetMetricsOpts) (*hcloud.LoadBalancerMetrics, *hcloud.Response, error)
+}
+
+var (
+	_ ServerClient       = (*hcloud.ServerClient)(nil)
+	_ LoadBalancerClient = (*hcloud.LoadBalancerClient)(nil)
+)
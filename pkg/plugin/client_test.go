@@ -0,0 +1,798 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// fakeServerClient is a [ServerClient] that serves a fixed list of servers and their metrics.
+type fakeServerClient struct {
+	servers []*hcloud.Server
+	metrics map[int64]*hcloud.ServerMetrics
+	// previousMetrics are returned instead of metrics for time ranges that start before the unix epoch, ie. the
+	// previous period of a compare offset
+	previousMetrics map[int64]*hcloud.ServerMetrics
+
+	// getByIDErr is returned by all calls to GetByID, ie. to simulate rate limiting
+	getByIDErr error
+
+	getByIDCalls    atomic.Int32
+	listCalls       atomic.Int32
+	getMetricsCalls atomic.Int32
+}
+
+func (f *fakeServerClient) GetByID(_ context.Context, id int64) (*hcloud.Server, *hcloud.Response, error) {
+	f.getByIDCalls.Add(1)
+	if f.getByIDErr != nil {
+		return nil, &hcloud.Response{}, f.getByIDErr
+	}
+
+	for _, server := range f.servers {
+		if server.ID == id {
+			return server, &hcloud.Response{}, nil
+		}
+	}
+	// Like the API client, missing resources are not an error
+	return nil, &hcloud.Response{}, nil
+}
+
+func (f *fakeServerClient) List(ctx context.Context, opts hcloud.ServerListOpts) ([]*hcloud.Server, *hcloud.Response, error) {
+	f.listCalls.Add(1)
+
+	servers, err := f.AllWithOpts(ctx, opts)
+	return servers, &hcloud.Response{}, err
+}
+
+func (f *fakeServerClient) All(ctx context.Context) ([]*hcloud.Server, error) {
+	return f.AllWithOpts(ctx, hcloud.ServerListOpts{})
+}
+
+func (f *fakeServerClient) AllWithOpts(_ context.Context, opts hcloud.ServerListOpts) ([]*hcloud.Server, error) {
+	var servers []*hcloud.Server
+	for _, server := range f.servers {
+		if matchesFakeLabelSelector(server.Labels, opts.LabelSelector) && hasStatus(server, opts.Status) {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+func (f *fakeServerClient) GetMetrics(_ context.Context, server *hcloud.Server, opts hcloud.ServerGetMetricsOpts) (*hcloud.ServerMetrics, *hcloud.Response, error) {
+	f.getMetricsCalls.Add(1)
+
+	metrics, ok := f.metrics[server.ID]
+	if f.previousMetrics != nil && opts.Start.Before(time.Unix(0, 0)) {
+		metrics, ok = f.previousMetrics[server.ID]
+	}
+	if !ok {
+		return nil, &hcloud.Response{}, hcloud.Error{Code: hcloud.ErrorCodeNotFound, Message: "server not found"}
+	}
+	return metrics, &hcloud.Response{}, nil
+}
+
+// fakeLoadBalancerClient is a [LoadBalancerClient] that serves a fixed list of load balancers without any metrics.
+type fakeLoadBalancerClient struct {
+	loadBalancers []*hcloud.LoadBalancer
+}
+
+func (f fakeLoadBalancerClient) GetByID(_ context.Context, id int64) (*hcloud.LoadBalancer, *hcloud.Response, error) {
+	for _, loadBalancer := range f.loadBalancers {
+		if loadBalancer.ID == id {
+			return loadBalancer, &hcloud.Response{}, nil
+		}
+	}
+	return nil, &hcloud.Response{}, nil
+}
+
+func (f fakeLoadBalancerClient) List(ctx context.Context, opts hcloud.LoadBalancerListOpts) ([]*hcloud.LoadBalancer, *hcloud.Response, error) {
+	loadBalancers, err := f.AllWithOpts(ctx, opts)
+	return loadBalancers, &hcloud.Response{}, err
+}
+
+func (f fakeLoadBalancerClient) All(ctx context.Context) ([]*hcloud.LoadBalancer, error) {
+	return f.AllWithOpts(ctx, hcloud.LoadBalancerListOpts{})
+}
+
+func (f fakeLoadBalancerClient) AllWithOpts(_ context.Context, opts hcloud.LoadBalancerListOpts) ([]*hcloud.LoadBalancer, error) {
+	var loadBalancers []*hcloud.LoadBalancer
+	for _, loadBalancer := range f.loadBalancers {
+		if matchesFakeLabelSelector(loadBalancer.Labels, opts.LabelSelector) {
+			loadBalancers = append(loadBalancers, loadBalancer)
+		}
+	}
+	return loadBalancers, nil
+}
+
+func (fakeLoadBalancerClient) GetMetrics(context.Context, *hcloud.LoadBalancer, hcloud.LoadBalancerGetMetricsOpts) (*hcloud.LoadBalancerMetrics, *hcloud.Response, error) {
+	return nil, &hcloud.Response{}, hcloud.Error{Code: hcloud.ErrorCodeNotFound, Message: "load balancer not found"}
+}
+
+// matchesFakeLabelSelector only supports comma separated "key=value" expressions, which is enough for the tests.
+func matchesFakeLabelSelector(labels map[string]string, selector string) bool {
+	for _, expression := range strings.Split(selector, ",") {
+		expression = strings.TrimSpace(expression)
+		if expression == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(expression, "=")
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func newFakeDatasource(servers *fakeServerClient) *Datasource {
+	return newDatasource(Options{DisableBuffering: true}, "test", hcloud.NewClient(), servers, fakeLoadBalancerClient{})
+}
+
+func newFakeServers() *fakeServerClient {
+	newServer := func(id int64, name, env string) *hcloud.Server {
+		return &hcloud.Server{
+			ID:         id,
+			Name:       name,
+			Status:     hcloud.ServerStatusRunning,
+			ServerType: &hcloud.ServerType{Name: "cx22"},
+			Labels:     map[string]string{"env": env},
+		}
+	}
+
+	return &fakeServerClient{
+		servers: []*hcloud.Server{
+			newServer(1, "web-1", "prod"),
+			newServer(2, "web-2", "prod"),
+			newServer(3, "web-staging", "staging"),
+		},
+		metrics: map[int64]*hcloud.ServerMetrics{
+			1: {TimeSeries: map[string][]hcloud.ServerMetricsValue{"cpu": {{Timestamp: 60, Value: "10"}, {Timestamp: 120, Value: "20"}}}},
+			2: {TimeSeries: map[string][]hcloud.ServerMetricsValue{"cpu": {{Timestamp: 60, Value: "30"}, {Timestamp: 120, Value: "40"}}}},
+		},
+	}
+}
+
+func newFakeQuery(t *testing.T, queryType string, qm map[string]any) backend.DataQuery {
+	t.Helper()
+
+	queryJSON, err := json.Marshal(qm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return backend.DataQuery{
+		RefID:         "A",
+		QueryType:     queryType,
+		JSON:          queryJSON,
+		TimeRange:     backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(180, 0)},
+		Interval:      time.Minute,
+		MaxDataPoints: 100,
+	}
+}
+
+func TestDatasource_CallResource_servers(t *testing.T) {
+	d := newFakeDatasource(newFakeServers())
+
+	for _, tt := range []struct {
+		url        string
+		wantLabels map[string]string
+	}{
+		{url: "servers?selector=env%3Dstaging"},
+		{url: "servers?selector=env%3Dstaging&withLabels=true", wantLabels: map[string]string{"env": "staging"}},
+	} {
+		t.Run(tt.url, func(t *testing.T) {
+			resp := callResource(t, d, http.MethodGet, tt.url)
+
+			var values []SelectableValue
+			if err := json.Unmarshal(resp.Body, &values); err != nil {
+				t.Fatalf("CallResource() status %d, body %s: %v", resp.Status, resp.Body, err)
+			}
+			want := []SelectableValue{{Value: 3, Label: "web-staging", Labels: tt.wantLabels}}
+			if !reflect.DeepEqual(values, want) {
+				t.Errorf("CallResource() = %+v, want %+v", values, want)
+			}
+		})
+	}
+}
+
+func TestDatasource_CallResource_resolve(t *testing.T) {
+	servers := newFakeServers()
+	d := newFakeDatasource(servers)
+
+	resp := callResource(t, d, http.MethodGet, "resolve?type=server&selector=env%3Dprod")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("CallResource() status = %d, body %s", resp.Status, resp.Body)
+	}
+
+	var values []SelectableValue
+	if err := json.Unmarshal(resp.Body, &values); err != nil {
+		t.Fatal(err)
+	}
+	if want := []SelectableValue{{Value: 1, Label: "web-1"}, {Value: 2, Label: "web-2"}}; !reflect.DeepEqual(values, want) {
+		t.Errorf("CallResource() = %+v, want %+v", values, want)
+	}
+	// The names are taken from the list response
+	if got := servers.getByIDCalls.Load(); got != 0 {
+		t.Errorf("servers were requested %d times by id, want 0", got)
+	}
+
+	// The preview also shows selections with more resources than allowed in queries
+	d.options.MaxResources = 1
+	if resp := callResource(t, d, http.MethodGet, "resolve?type=server&selector=env%3Dprod"); resp.Status != http.StatusOK || json.Unmarshal(resp.Body, &values) != nil || len(values) != 2 {
+		t.Errorf("CallResource() status = %d, body %s, want both servers despite Max Resources", resp.Status, resp.Body)
+	}
+
+	if resp := callResource(t, d, http.MethodGet, "resolve?type=volume&selector=env%3Dprod"); resp.Status != http.StatusBadRequest {
+		t.Errorf("CallResource() status = %d for an unknown resource type, want %d", resp.Status, http.StatusBadRequest)
+	}
+	if resp := callResource(t, d, http.MethodGet, "resolve?type=server&selector=env%3Dprod%2C%2C"); resp.Status != http.StatusBadRequest {
+		t.Errorf("CallResource() status = %d for an invalid label selector, want %d", resp.Status, http.StatusBadRequest)
+	}
+}
+
+func TestDatasource_queryResourceList(t *testing.T) {
+	d := newFakeDatasource(newFakeServers())
+
+	resp := d.queryResourceList(context.Background(), newFakeQuery(t, QueryTypeResourceList, map[string]any{
+		"resourceType":   ResourceTypeServer,
+		"labelSelectors": []string{"env=prod"},
+	}))
+	if resp.Error != nil {
+		t.Fatalf("queryResourceList() error = %v", resp.Error)
+	}
+	if len(resp.Frames) != 1 {
+		t.Fatalf("queryResourceList() returned %d frames, want 1", len(resp.Frames))
+	}
+
+	frame := resp.Frames[0]
+	if rows := frame.Rows(); rows != 2 {
+		t.Fatalf("queryResourceList() returned %d servers, want the 2 servers matching the label selector", rows)
+	}
+	names, _ := frame.FieldByName("name")
+	if names == nil {
+		t.Fatal("queryResourceList() returned no name field")
+	}
+	for i, want := range []string{"web-1", "web-2"} {
+		if got := names.At(i); got != want {
+			t.Errorf("name of row %d = %v, want %q", i, got, want)
+		}
+	}
+}
+
+func TestDatasource_queryResourceList_protection(t *testing.T) {
+	servers := newFakeServers()
+	servers.servers[0].Protection = hcloud.ServerProtection{Delete: true}
+	servers.servers[1].Protection = hcloud.ServerProtection{Rebuild: true}
+	servers.servers[1].Locked = true
+	d := newFakeDatasource(servers)
+
+	resp := d.queryResourceList(context.Background(), newFakeQuery(t, QueryTypeResourceList, map[string]any{
+		"resourceType": ResourceTypeServer,
+	}))
+	if resp.Error != nil {
+		t.Fatalf("queryResourceList() error = %v", resp.Error)
+	}
+
+	for field, want := range map[string][]any{
+		"delete_protection":  {true, false, false},
+		"rebuild_protection": {false, true, false},
+		"locked":             {false, true, false},
+	} {
+		if got := fieldValues(t, resp.Frames[0], field); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+}
+
+func TestDatasource_queryResourceList_location(t *testing.T) {
+	servers := newFakeServers()
+	servers.servers[0].Datacenter = &hcloud.Datacenter{Name: "fsn1-dc14", Location: &hcloud.Location{Name: "fsn1"}}
+	servers.servers[1].Datacenter = &hcloud.Datacenter{Name: "nbg1-dc3"}
+	d := newFakeDatasource(servers)
+
+	resp := d.queryResourceList(context.Background(), newFakeQuery(t, QueryTypeResourceList, map[string]any{
+		"resourceType": ResourceTypeServer,
+	}))
+	if resp.Error != nil {
+		t.Fatalf("queryResourceList() error = %v", resp.Error)
+	}
+
+	for field, want := range map[string][]any{
+		"location":   {"fsn1", "", ""},
+		"datacenter": {"fsn1-dc14", "nbg1-dc3", ""},
+	} {
+		if got := fieldValues(t, resp.Frames[0], field); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+}
+
+func TestDatasource_queryResourceList_iso(t *testing.T) {
+	servers := newFakeServers()
+	servers.servers[0].ISO = &hcloud.ISO{Name: "ubuntu-24.04.iso"}
+	// Private ISOs have no name
+	servers.servers[1].ISO = &hcloud.ISO{Description: "custom installer"}
+	servers.servers[1].RescueEnabled = true
+	d := newFakeDatasource(servers)
+
+	resp := d.queryResourceList(context.Background(), newFakeQuery(t, QueryTypeResourceList, map[string]any{
+		"resourceType": ResourceTypeServer,
+	}))
+	if resp.Error != nil {
+		t.Fatalf("queryResourceList() error = %v", resp.Error)
+	}
+
+	for field, want := range map[string][]any{
+		"iso":            {"ubuntu-24.04.iso", "custom installer", ""},
+		"rescue_enabled": {false, true, false},
+	} {
+		if got := fieldValues(t, resp.Frames[0], field); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+}
+
+func TestDatasource_queryResourceList_image(t *testing.T) {
+	servers := newFakeServers()
+	servers.servers[0].Image = &hcloud.Image{Name: "ubuntu-24.04", Type: hcloud.ImageTypeSystem, OSFlavor: "ubuntu", OSVersion: "24.04"}
+	servers.servers[1].Image = &hcloud.Image{Description: "hardened", Type: hcloud.ImageTypeSnapshot, OSFlavor: "debian"}
+	d := newFakeDatasource(servers)
+
+	resp := d.queryResourceList(context.Background(), newFakeQuery(t, QueryTypeResourceList, map[string]any{
+		"resourceType": ResourceTypeServer,
+	}))
+	if resp.Error != nil {
+		t.Fatalf("queryResourceList() error = %v", resp.Error)
+	}
+
+	for field, want := range map[string][]any{
+		"image_type": {"system", "snapshot", ""},
+		"os_flavor":  {"ubuntu", "debian", ""},
+		"os_version": {"24.04", "", ""},
+	} {
+		if got := fieldValues(t, resp.Frames[0], field); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+}
+
+func TestDatasource_queryResourceList_backup(t *testing.T) {
+	servers := newFakeServers()
+	servers.servers[0].BackupWindow = "22-02"
+	d := newFakeDatasource(servers)
+
+	resp := d.queryResourceList(context.Background(), newFakeQuery(t, QueryTypeResourceList, map[string]any{
+		"resourceType": ResourceTypeServer,
+	}))
+	if resp.Error != nil {
+		t.Fatalf("queryResourceList() error = %v", resp.Error)
+	}
+
+	for field, want := range map[string][]any{
+		"backup_enabled": {true, false, false},
+		"backup_window":  {"22-02", "", ""},
+	} {
+		if got := fieldValues(t, resp.Frames[0], field); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+}
+
+func TestDatasource_queryResourceList_networkID(t *testing.T) {
+	newLoadBalancer := func(id int64, name string, networkIDs ...int64) *hcloud.LoadBalancer {
+		loadBalancer := &hcloud.LoadBalancer{ID: id, Name: name, LoadBalancerType: &hcloud.LoadBalancerType{Name: "lb11"}}
+		for _, networkID := range networkIDs {
+			loadBalancer.PrivateNet = append(loadBalancer.PrivateNet, hcloud.LoadBalancerPrivateNet{Network: &hcloud.Network{ID: networkID}})
+		}
+		return loadBalancer
+	}
+	d := newDatasource(Options{DisableBuffering: true}, "test", hcloud.NewClient(), &fakeServerClient{}, fakeLoadBalancerClient{
+		loadBalancers: []*hcloud.LoadBalancer{
+			newLoadBalancer(1, "lb-public"),
+			newLoadBalancer(2, "lb-private", 10),
+			newLoadBalancer(3, "lb-other", 11),
+		},
+	})
+
+	resp := d.queryResourceList(context.Background(), newFakeQuery(t, QueryTypeResourceList, map[string]any{
+		"resourceType": ResourceTypeLoadBalancer,
+		"networkId":    10,
+	}))
+	if resp.Error != nil {
+		t.Fatalf("queryResourceList() error = %v", resp.Error)
+	}
+
+	if got, want := fieldValues(t, resp.Frames[0], "name"), []any{"lb-private"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("name = %v, want only the load balancer in the network %v", got, want)
+	}
+}
+
+func TestDatasource_queryServerSpecs(t *testing.T) {
+	t.Run("cached servers", func(t *testing.T) {
+		servers := newFakeServers()
+		d := newFakeDatasource(servers)
+		query := newFakeQuery(t, QueryTypeServerSpecs, map[string]any{
+			"resourceType": ResourceTypeServer,
+			"selectBy":     SelectByID,
+			"resourceIds":  []int64{1, 3},
+		})
+
+		for range 2 {
+			resp := d.queryServerSpecs(context.Background(), query)
+			if resp.Error != nil {
+				t.Fatalf("queryServerSpecs() error = %v", resp.Error)
+			}
+			if got, want := fieldValues(t, resp.Frames[0], "name"), []any{"web-1", "web-staging"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("name = %v, want %v", got, want)
+			}
+		}
+
+		// Both servers are fetched once and then served from the cache on the refresh
+		if got := servers.listCalls.Load() + servers.getByIDCalls.Load(); got != 1 {
+			t.Errorf("servers were requested %d times, want 1", got)
+		}
+	})
+
+	t.Run("unknown selectBy", func(t *testing.T) {
+		d := newFakeDatasource(newFakeServers())
+
+		resp := d.queryServerSpecs(context.Background(), newFakeQuery(t, QueryTypeServerSpecs, map[string]any{
+			"resourceType": ResourceTypeServer,
+			"selectBy":     "everything",
+		}))
+		if resp.Error == nil || resp.Status != backend.StatusBadRequest {
+			t.Errorf("queryServerSpecs() = %v (%v), want a bad request", resp.Error, resp.Status)
+		}
+	})
+}
+
+func TestDatasource_queryServerStatus(t *testing.T) {
+	servers := newFakeServers()
+	servers.servers[1].Status = hcloud.ServerStatusOff
+	d := newFakeDatasource(servers)
+
+	resp := d.queryServerStatus(context.Background(), newFakeQuery(t, QueryTypeServerStatus, map[string]any{
+		"resourceType":   ResourceTypeServer,
+		"selectBy":       SelectByLabel,
+		"labelSelectors": []string{"env=prod"},
+	}))
+	if resp.Error != nil {
+		t.Fatalf("queryServerStatus() error = %v", resp.Error)
+	}
+	if len(resp.Frames) != 2 {
+		t.Fatalf("queryServerStatus() returned %d frames, want one per server", len(resp.Frames))
+	}
+
+	for i, want := range []struct {
+		name    string
+		running float64
+	}{
+		{name: "web-1", running: 1},
+		{name: "web-2", running: 0},
+	} {
+		valuesField := resp.Frames[i].Fields[len(resp.Frames[i].Fields)-1]
+		if got := valuesField.Labels[LabelName]; got != want.name {
+			t.Errorf("frame %d is for server %q, want %q", i, got, want.name)
+		}
+		if got := valuesField.At(0).(*float64); got == nil || *got != want.running {
+			t.Errorf("running of server %q = %v, want %v", want.name, got, want.running)
+		}
+	}
+}
+
+func TestDatasource_queryServerTraffic(t *testing.T) {
+	servers := newFakeServers()
+	servers.servers[0].IncludedTraffic = 1000
+	servers.servers[0].OutgoingTraffic = 250
+	d := newFakeDatasource(servers)
+
+	resp := d.queryServerTraffic(context.Background(), newFakeQuery(t, QueryTypeServerTraffic, map[string]any{
+		"resourceType":   ResourceTypeServer,
+		"selectBy":       SelectByLabel,
+		"labelSelectors": []string{"env=prod"},
+	}), serverTrafficPercent)
+	if resp.Error != nil {
+		t.Fatalf("queryServerTraffic() error = %v", resp.Error)
+	}
+	if len(resp.Frames) != 2 {
+		t.Fatalf("queryServerTraffic() returned %d frames, want one per server", len(resp.Frames))
+	}
+
+	if got := fieldValues(t, resp.Frames[0], serverTrafficPercent.name)[0].(*float64); got == nil || *got != 25 {
+		t.Errorf("outgoing traffic of web-1 = %v, want 25%%", got)
+	}
+	// web-2 has no included traffic
+	if got := fieldValues(t, resp.Frames[1], serverTrafficPercent.name)[0].(*float64); got != nil {
+		t.Errorf("outgoing traffic of web-2 = %v, want null", *got)
+	}
+}
+
+func TestDatasource_checkMetricsAccess(t *testing.T) {
+	t.Run("server metrics", func(t *testing.T) {
+		servers := newFakeServers()
+		d := newFakeDatasource(servers)
+
+		if err := d.checkMetricsAccess(context.Background()); err != nil {
+			t.Errorf("checkMetricsAccess() error = %v", err)
+		}
+		if got := servers.getMetricsCalls.Load(); got != 1 {
+			t.Errorf("metrics were requested %d times, want 1", got)
+		}
+	})
+
+	t.Run("server metrics error", func(t *testing.T) {
+		servers := newFakeServers()
+		servers.metrics = nil
+		d := newFakeDatasource(servers)
+
+		if err := d.checkMetricsAccess(context.Background()); err == nil {
+			t.Error("checkMetricsAccess() should return the error of the metrics request")
+		}
+	})
+
+	t.Run("load balancer fallback", func(t *testing.T) {
+		d := newDatasource(Options{DisableBuffering: true}, "test", hcloud.NewClient(), &fakeServerClient{}, fakeLoadBalancerClient{
+			loadBalancers: []*hcloud.LoadBalancer{{ID: 1, Name: "lb-1"}},
+		})
+
+		// The fake has no load balancer metrics, so the check must have requested them
+		if err := d.checkMetricsAccess(context.Background()); err == nil {
+			t.Error("checkMetricsAccess() should request the metrics of a load balancer if there are no servers")
+		}
+	})
+
+	t.Run("no resources", func(t *testing.T) {
+		d := newDatasource(Options{DisableBuffering: true}, "test", hcloud.NewClient(), &fakeServerClient{}, fakeLoadBalancerClient{})
+
+		if err := d.checkMetricsAccess(context.Background()); err != nil {
+			t.Errorf("checkMetricsAccess() error = %v, want nil for an empty project", err)
+		}
+	})
+}
+
+func TestDatasource_queryMetrics(t *testing.T) {
+	t.Run("label selector", func(t *testing.T) {
+		servers := newFakeServers()
+		d := newFakeDatasource(servers)
+
+		resp := d.queryMetrics(context.Background(), newFakeQuery(t, QueryTypeMetrics, map[string]any{
+			"resourceType":   ResourceTypeServer,
+			"metricsType":    MetricsTypeServerCPU,
+			"selectBy":       SelectByLabel,
+			"labelSelectors": []string{"env=prod"},
+			"step":           60,
+		}))
+		if resp.Error != nil {
+			t.Fatalf("queryMetrics() error = %v", resp.Error)
+		}
+		if got := servers.getMetricsCalls.Load(); got != 2 {
+			t.Errorf("metrics were requested %d times, want once per selected server", got)
+		}
+		if len(resp.Frames) != 2 {
+			t.Fatalf("queryMetrics() returned %d frames, want one per server", len(resp.Frames))
+		}
+
+		// Frames are sorted by the resource name
+		for i, want := range []struct {
+			name   string
+			values []float64
+		}{
+			{name: "web-1", values: []float64{10, 20}},
+			{name: "web-2", values: []float64{30, 40}},
+		} {
+			valuesField := resp.Frames[i].Fields[len(resp.Frames[i].Fields)-1]
+			if got := valuesField.Labels[LabelName]; got != want.name {
+				t.Errorf("frame %d is for server %q, want %q", i, got, want.name)
+			}
+			if valuesField.Config == nil || valuesField.Config.Unit != "percent" {
+				t.Errorf("frame %d should have the unit of the cpu series, got %v", i, valuesField.Config)
+			}
+			if valuesField.Len() != len(want.values) {
+				t.Fatalf("frame %d has %d values, want %d", i, valuesField.Len(), len(want.values))
+			}
+			for j, wantValue := range want.values {
+				if got := valuesField.At(j).(*float64); got == nil || *got != wantValue {
+					t.Errorf("value %d of frame %d = %v, want %v", j, i, got, wantValue)
+				}
+			}
+		}
+	})
+
+	t.Run("mixed ids and names", func(t *testing.T) {
+		servers := newFakeServers()
+		d := newFakeDatasource(servers)
+
+		resp := d.queryMetrics(context.Background(), newFakeQuery(t, QueryTypeMetrics, map[string]any{
+			"resourceType": ResourceTypeServer,
+			"metricsType":  MetricsTypeServerCPU,
+			"selectBy":     SelectByResource,
+			"resources":    []string{"web-1, 2", "web-unknown"},
+			"step":         60,
+		}))
+		if resp.Error != nil {
+			t.Fatalf("queryMetrics() error = %v", resp.Error)
+		}
+		if len(resp.Frames) != 2 {
+			t.Fatalf("queryMetrics() returned %d frames, want one per resolved server", len(resp.Frames))
+		}
+
+		var notices []data.Notice
+		for _, frame := range resp.Frames {
+			if frame.Meta != nil {
+				notices = append(notices, frame.Meta.Notices...)
+			}
+		}
+		if !noticesContain(notices, `"web-unknown"`) {
+			t.Errorf("queryMetrics() should inform about the unknown name, got notices %v", notices)
+		}
+	})
+
+	t.Run("compare offset", func(t *testing.T) {
+		servers := newFakeServers()
+		d := newFakeDatasource(servers)
+
+		resp := d.queryMetrics(context.Background(), newFakeQuery(t, QueryTypeMetrics, map[string]any{
+			"resourceType":  ResourceTypeServer,
+			"metricsType":   MetricsTypeServerCPU,
+			"selectBy":      SelectByID,
+			"resourceIds":   []int64{1},
+			"step":          60,
+			"compareOffset": "1m",
+		}))
+		if resp.Error != nil {
+			t.Fatalf("queryMetrics() error = %v", resp.Error)
+		}
+		if got := servers.getMetricsCalls.Load(); got != 2 {
+			t.Errorf("metrics were requested %d times, want once per period", got)
+		}
+		if len(resp.Frames) != 2 {
+			t.Fatalf("queryMetrics() returned %d frames, want one per period", len(resp.Frames))
+		}
+
+		previous := resp.Frames[1]
+		if got := previous.Fields[len(previous.Fields)-1].Labels[LabelOffset]; got != "1m" {
+			t.Errorf("offset label of the previous period = %q, want %q", got, "1m")
+		}
+		// The fake returns the same timestamps for both periods
+		if got := previous.Fields[0].At(0).(time.Time); !got.Equal(time.Unix(120, 0)) {
+			t.Errorf("first time of the previous period = %v, want it to be shifted by the offset", got)
+		}
+		if _, ok := resp.Frames[0].Fields[len(resp.Frames[0].Fields)-1].Labels[LabelOffset]; ok {
+			t.Error("the current period should not have an offset label")
+		}
+	})
+
+	t.Run("compare offset with top n", func(t *testing.T) {
+		servers := newFakeServers()
+		// Server 1 has the highest cpu in the previous period, but server 2 in the current one
+		servers.previousMetrics = map[int64]*hcloud.ServerMetrics{
+			1: {TimeSeries: map[string][]hcloud.ServerMetricsValue{"cpu": {{Timestamp: 60, Value: "90"}}}},
+			2: {TimeSeries: map[string][]hcloud.ServerMetricsValue{"cpu": {{Timestamp: 60, Value: "5"}}}},
+		}
+		d := newFakeDatasource(servers)
+
+		resp := d.queryMetrics(context.Background(), newFakeQuery(t, QueryTypeMetrics, map[string]any{
+			"resourceType":  ResourceTypeServer,
+			"metricsType":   MetricsTypeServerCPU,
+			"selectBy":      SelectByID,
+			"resourceIds":   []int64{1, 2},
+			"step":          60,
+			"topN":          1,
+			"compareOffset": "1m",
+		}))
+		if resp.Error != nil {
+			t.Fatalf("queryMetrics() error = %v", resp.Error)
+		}
+		if len(resp.Frames) != 2 {
+			t.Fatalf("queryMetrics() returned %d frames, want one per period", len(resp.Frames))
+		}
+		for i, frame := range resp.Frames {
+			if got := frame.Fields[len(frame.Fields)-1].Labels[LabelName]; got != "web-2" {
+				t.Errorf("frame %d is for server %q, want the top server of the current period in both periods", i, got)
+			}
+		}
+	})
+
+	t.Run("missing server", func(t *testing.T) {
+		d := newFakeDatasource(newFakeServers())
+
+		resp := d.queryMetrics(context.Background(), newFakeQuery(t, QueryTypeMetrics, map[string]any{
+			"resourceType": ResourceTypeServer,
+			"metricsType":  MetricsTypeServerCPU,
+			"selectBy":     SelectByID,
+			"resourceIds":  []int64{1, 99},
+			"step":         60,
+		}))
+		if resp.Error != nil {
+			t.Fatalf("queryMetrics() should not fail for missing servers, got %v", resp.Error)
+		}
+		if len(resp.Frames) != 2 {
+			t.Fatalf("queryMetrics() returned %d frames, want one for the existing and one for the missing server", len(resp.Frames))
+		}
+
+		var notices []data.Notice
+		for _, frame := range resp.Frames {
+			if frame.Meta != nil {
+				notices = append(notices, frame.Meta.Notices...)
+			}
+		}
+		if !noticesContain(notices, "99") {
+			t.Errorf("queryMetrics() should inform about the missing server, got notices %v", notices)
+		}
+	})
+}
+
+func TestDatasource_queryMetrics_frameMeta(t *testing.T) {
+	d := newFakeDatasource(newFakeServers())
+
+	query := newFakeQuery(t, QueryTypeMetrics, map[string]any{
+		"resourceType":   ResourceTypeServer,
+		"metricsType":    MetricsTypeServerCPU,
+		"selectBy":       SelectByLabel,
+		"labelSelectors": []string{"env=prod"},
+		"step":           60,
+	})
+	resp := d.queryMetrics(context.Background(), query)
+	if resp.Error != nil {
+		t.Fatalf("queryMetrics() error = %v", resp.Error)
+	}
+
+	want := MetricsFrameMeta{Step: 60, MaxDataPoints: query.MaxDataPoints, DataPoints: 2, APICalls: 2}
+	for i, frame := range resp.Frames {
+		if frame.Meta == nil || frame.Meta.Custom != want {
+			t.Errorf("custom meta of frame %d = %+v, want %+v", i, frame.Meta, want)
+		}
+	}
+}
+
+func noticesContain(notices []data.Notice, text string) bool {
+	for _, notice := range notices {
+		if strings.Contains(notice.Text, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// callResource sends a resource request to d and returns the response.
+func callResource(t *testing.T, d *Datasource, method, url string) *backend.CallResourceResponse {
+	t.Helper()
+
+	var resp *backend.CallResourceResponse
+	err := d.CallResource(context.Background(), &backend.CallResourceRequest{
+		Method: method,
+		Path:   strings.Split(url, "?")[0],
+		URL:    url,
+	}, backend.CallResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+		resp = r
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// fieldValues returns all values of the field with the name.
+func fieldValues(t *testing.T, frame *data.Frame, name string) []any {
+	t.Helper()
+
+	field, _ := frame.FieldByName(name)
+	if field == nil {
+		t.Fatalf("frame has no field %q", name)
+	}
+
+	values := make([]any, 0, field.Len())
+	for i := 0; i < field.Len(); i++ {
+		values = append(values, field.At(i))
+	}
+	return values
+}
@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// parseCompareOffset parses the duration of [QueryModel.CompareOffset]. In addition to the units of
+// [time.ParseDuration], it supports days ("7d") and weeks ("1w") like the relative time ranges of Grafana.
+func parseCompareOffset(offset string) (time.Duration, error) {
+	var duration time.Duration
+	switch {
+	case strings.HasSuffix(offset, "d"), strings.HasSuffix(offset, "w"):
+		count, err := strconv.Atoi(offset[:len(offset)-1])
+		if err != nil {
+			return 0, errors.New("days and weeks must be whole numbers, ie. 7d or 1w")
+		}
+		unit := 24 * time.Hour
+		if strings.HasSuffix(offset, "w") {
+			unit *= 7
+		}
+		if count > int(math.MaxInt64/unit) {
+			return 0, errors.New("the offset is too large")
+		}
+		duration = time.Duration(count) * unit
+	default:
+		var err error
+		duration, err = time.ParseDuration(offset)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if duration <= 0 {
+		return 0, errors.New("the offset must be positive")
+	}
+	return duration, nil
+}
+
+// shiftFrames moves the frames of a previous period forward by offset, so they overlay the current period, and adds
+// [LabelOffset]. If the legend format does not contain the offset, it is appended to the display name, so both
+// periods can be told apart.
+func shiftFrames(frames []*data.Frame, offset time.Duration, offsetLabel string, legendFormat string) {
+	for _, frame := range frames {
+		if len(frame.Fields) < 2 {
+			continue
+		}
+
+		timeField := frame.Fields[0]
+		for i := 0; i < timeField.Len(); i++ {
+			if timestamp, ok := timeField.At(i).(time.Time); ok {
+				timeField.Set(i, timestamp.Add(offset))
+			}
+		}
+
+		valuesField := frame.Fields[len(frame.Fields)-1]
+		if valuesField.Labels == nil {
+			valuesField.Labels = data.Labels{}
+		}
+		valuesField.Labels[LabelOffset] = offsetLabel
+
+		if valuesField.Config != nil {
+			displayName := getDisplayName(legendFormat, valuesField.Labels)
+			if displayName == valuesField.Config.DisplayNameFromDS {
+				displayName += " offset " + offsetLabel
+			}
+			valuesField.Config.DisplayNameFromDS = displayName
+		}
+	}
+}
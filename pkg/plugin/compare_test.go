@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func Test_parseCompareOffset(t *testing.T) {
+	tests := []struct {
+		offset  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{offset: "7d", want: 7 * 24 * time.Hour},
+		{offset: "1w", want: 7 * 24 * time.Hour},
+		{offset: "90m", want: 90 * time.Minute},
+		{offset: "1h30m", want: 90 * time.Minute},
+		{offset: "1.5d", wantErr: true},
+		{offset: "0s", wantErr: true},
+		{offset: "-1d", wantErr: true},
+		{offset: "week", wantErr: true},
+		{offset: "1000000d", wantErr: true},
+		{offset: "9223372036854775807w", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.offset, func(t *testing.T) {
+			got, err := parseCompareOffset(tt.offset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCompareOffset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCompareOffset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_shiftFrames(t *testing.T) {
+	value := 1.0
+	newFrame := func() *data.Frame {
+		valuesField := data.NewField("cpu", data.Labels{LabelName: "web-1", LabelSeriesName: "cpu", LabelSeriesDisplayName: "CPU"}, []*float64{&value})
+		valuesField.Config = &data.FieldConfig{DisplayNameFromDS: "CPU web-1"}
+		return data.NewFrame("", data.NewField("time", nil, []time.Time{time.Unix(0, 0)}), valuesField)
+	}
+
+	frame := newFrame()
+	shiftFrames([]*data.Frame{frame}, time.Hour, "1h", "")
+	if got := frame.Fields[0].At(0).(time.Time); !got.Equal(time.Unix(3600, 0)) {
+		t.Errorf("time = %v, want it to be moved forward by the offset", got)
+	}
+	valuesField := frame.Fields[1]
+	if got := valuesField.Labels[LabelOffset]; got != "1h" {
+		t.Errorf("offset label = %q, want %q", got, "1h")
+	}
+	if got := valuesField.Config.DisplayNameFromDS; got != "CPU web-1 offset 1h" {
+		t.Errorf("display name = %q, want the offset appended", got)
+	}
+
+	frame = newFrame()
+	shiftFrames([]*data.Frame{frame}, time.Hour, "1h", "{{ name }} ({{ offset }} ago)")
+	if got := frame.Fields[1].Config.DisplayNameFromDS; got != "web-1 (1h ago)" {
+		t.Errorf("display name = %q, want the legend format with the offset", got)
+	}
+}
@@ -1,20 +1,27 @@
 package plugin
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"net/http"
+	"net/url"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/apricote/grafana-hcloud-datasource/pkg/logutil"
-	"github.com/grafana/grafana-plugin-sdk-go/build"
+	"github.com/apricote/grafana-hcloud-datasource/pkg/set"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/conc/iter"
 	"github.com/sourcegraph/conc/stream"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -26,8 +33,15 @@ import (
 )
 
 const (
-	QueryTypeResourceList = "resource-list"
-	QueryTypeMetrics      = "metrics"
+	QueryTypeResourceList  = "resource-list"
+	QueryTypeMetrics       = "metrics"
+	QueryTypeServerSpecs   = "server-specs"
+	QueryTypeServerStatus  = "server-status"
+	QueryTypeServerTraffic = "server-traffic"
+	QueryTypeActions       = "actions"
+
+	QueryTypeServerTrafficRemaining = "server-traffic-remaining"
+	QueryTypeEvents                 = "events"
 )
 
 type ResourceType string
@@ -35,6 +49,11 @@ type ResourceType string
 const (
 	ResourceTypeServer       ResourceType = "server"
 	ResourceTypeLoadBalancer ResourceType = "load-balancer"
+	// ResourceTypeNetwork is only supported by resource list queries, the API has no metrics for networks.
+	ResourceTypeNetwork ResourceType = "network"
+	// ResourceTypePlacementGroup is only supported by resource list queries, the API has no metrics for placement
+	// groups.
+	ResourceTypePlacementGroup ResourceType = "placement-group"
 )
 
 type MetricsType string
@@ -45,6 +64,10 @@ const (
 	MetricsTypeServerDiskIOPS         MetricsType = "disk-iops"
 	MetricsTypeServerNetworkBandwidth MetricsType = "network-bandwidth"
 	MetricsTypeServerNetworkPPS       MetricsType = "network-pps"
+	// MetricsTypeServerPublicNetwork and MetricsTypeServerPrivateNetwork split the network bandwidth by interface, see
+	// [splitNetworkInterfaces] for the mapping of interfaces.
+	MetricsTypeServerPublicNetwork  MetricsType = "public-network-bandwidth"
+	MetricsTypeServerPrivateNetwork MetricsType = "private-network-bandwidth"
 
 	MetricsTypeLoadBalancerOpenConnections      MetricsType = "open-connections"
 	MetricsTypeLoadBalancerConnectionsPerSecond MetricsType = "connections-per-second"
@@ -57,21 +80,562 @@ type SelectBy string
 const (
 	SelectByLabel SelectBy = "label"
 	SelectByID    SelectBy = "id"
+	// SelectByResourceName selects resources by their name. The frontend uses "name" for selecting by variable, which
+	// is resolved to [SelectByID] before the query is sent to the backend.
+	SelectByResourceName SelectBy = "resource-name"
+	// SelectByResource selects resources by a mixed list of IDs and names, see [QueryModel.Resources].
+	SelectByResource SelectBy = "resource"
 )
 
 type Options struct {
 	Debug bool `json:"debug"`
+
+	// DefaultLegendFormat is used for all queries that do not specify their own legend format.
+	// If it is empty, [AutoLegendFormat] is used.
+	DefaultLegendFormat string `json:"defaultLegendFormat"`
+
+	// VarFormat is the template for the `var` field of resource list queries. It supports the labels `id` and `name`.
+	// If it is empty, [DefaultVarFormat] is used.
+	VarFormat string `json:"varFormat"`
+
+	// NameCacheSize is the maximum number of resource names kept per resource type.
+	// If it is not set, [DefaultNameCacheSize] is used.
+	NameCacheSize int `json:"nameCacheSize"`
+
+	// NameCacheTTLSeconds is the maximum age of cached resource names, so renamed resources show up with their new name
+	// eventually. It can be changed at runtime with the `cache/config` resource. Zero keeps names until they are
+	// evicted or the cache is refreshed.
+	NameCacheTTLSeconds int `json:"nameCacheTTLSeconds"`
+
+	// PreloadNameCache fills the name caches with all servers and load balancers when the data source is created,
+	// instead of looking up every name on first use.
+	PreloadNameCache bool `json:"preloadNameCache"`
+
+	// QueryConcurrency is the maximum number of queries that are processed in parallel. It also limits the number of
+	// metrics API requests that are sent in parallel. If it is not set, [DefaultQueryConcurrency] is used.
+	QueryConcurrency int `json:"queryConcurrency"`
+
+	// DisableBuffering sends metrics requests to the API right away, instead of waiting for the buffer period to
+	// combine requests. This reduces the latency, but increases the number of API requests.
+	DisableBuffering bool `json:"disableBuffering"`
+
+	// SingleResourceFastPath sends metrics requests for a single resource right away if no other requests are
+	// buffered. This reduces the latency of small dashboards, but panels that are loaded at the same time and show the
+	// same resource might each send their own API request.
+	SingleResourceFastPath bool `json:"singleResourceFastPath"`
+
+	// APITimeoutSeconds limits the duration of every metrics request to the API.
+	// If it is not set, [DefaultAPITimeout] is used.
+	APITimeoutSeconds int `json:"apiTimeoutSeconds"`
+
+	// SeriesOverrides replaces the built-in display name and unit of series, keyed by the series name
+	// (ie. "cpu" or "bandwidth.in"). Empty values keep the built-in default.
+	SeriesOverrides map[string]SeriesOverride `json:"seriesOverrides"`
+
+	// EmptySelectionMeansAll makes queries without any selected resources (no label selector, ids or names) return
+	// all resources of the project. By default, these queries return no resources, to avoid accidentally querying
+	// every resource.
+	EmptySelectionMeansAll bool `json:"emptySelectionMeansAll"`
+
+	// MaxPointsPerSeries limits the number of data points requested per series. If a query would return more points,
+	// the step is raised. If it is not set, [DefaultMaxPointsPerSeries] is used. Zero disables the limit.
+	MaxPointsPerSeries *int `json:"maxPointsPerSeries"`
+
+	// HealthCheckCacheSeconds is the duration for which a successful health check is reused, to avoid API requests
+	// for frequent health checks. If it is not set, [DefaultHealthCheckCacheDuration] is used.
+	HealthCheckCacheSeconds int `json:"healthCheckCacheSeconds"`
+
+	// IncludeProjectLabel adds the label [LabelProject] to all metrics, to tell apart the series of multiple data
+	// sources in one panel. The value is ProjectName, or the name of the data source if it is empty.
+	IncludeProjectLabel bool   `json:"includeProjectLabel"`
+	ProjectName         string `json:"projectName"`
+
+	// AppIdentifier is appended to the application version in the user agent of all API requests, so the traffic of
+	// different Grafana instances or forks can be told apart.
+	AppIdentifier string `json:"appIdentifier"`
+
+	// MaxResources is the maximum number of resources a single query can select. Every resource requires one API
+	// request for metrics. If it is not set, [DefaultMaxResources] is used.
+	MaxResources int `json:"maxResources"`
+
+	// Thresholds are the default thresholds for all series of a metrics type (ie. "open-connections"), keyed by the
+	// metrics type. Panels can still override them. Metrics types without thresholds get no thresholds.
+	Thresholds map[MetricsType][]ThresholdStep `json:"thresholds"`
+
+	// TrailingBucketsToDrop is the maximum number of data points at the end of every series that are dropped if their
+	// bucket has not ended yet. The API returns the average of the samples so far for these buckets, which often shows
+	// up as a drop at the right edge of graphs. If it is not set, [DefaultTrailingBucketsToDrop] is used. Zero keeps
+	// incomplete buckets.
+	TrailingBucketsToDrop *int `json:"trailingBucketsToDrop"`
+
+	// Decimals is the number of decimals that Grafana shows for all metrics values. Panels can still override it. If
+	// it is not set, Grafana picks the decimals based on the values.
+	Decimals *uint16 `json:"decimals"`
+
+	// SavedSelectors are label selectors that queries can reference by name with [QueryModel.SavedSelector], so a
+	// selector used by many provisioned panels is only defined once.
+	SavedSelectors map[string]string `json:"savedSelectors"`
+
+	// CustomHeaders are added to all API requests, ie. for an authenticating proxy in front of the API. The values of
+	// headers that look like credentials are redacted in logs, see [redactHeaders].
+	CustomHeaders map[string]string `json:"customHeaders"`
+}
+
+type SeriesOverride struct {
+	DisplayName string `json:"displayName"`
+	Unit        string `json:"unit"`
+}
+
+type ThresholdStep struct {
+	// Value is the lower bound of the step. It should only be empty for the first step, which sets the base color.
+	Value *float64 `json:"value"`
+	Color string   `json:"color"`
+}
+
+// Validate returns an error if any of the options has an invalid value.
+func (o Options) Validate() error {
+	if o.QueryConcurrency < 0 {
+		return fmt.Errorf("query concurrency must not be negative, got %d", o.QueryConcurrency)
+	}
+	if o.APITimeoutSeconds < 0 {
+		return fmt.Errorf("API timeout must not be negative, got %d", o.APITimeoutSeconds)
+	}
+	if o.MaxPointsPerSeries != nil && *o.MaxPointsPerSeries < 0 {
+		return fmt.Errorf("max points per series must not be negative, got %d", *o.MaxPointsPerSeries)
+	}
+	if o.MaxResources < 0 {
+		return fmt.Errorf("max resources must not be negative, got %d", o.MaxResources)
+	}
+	if o.TrailingBucketsToDrop != nil && *o.TrailingBucketsToDrop < 0 {
+		return fmt.Errorf("trailing buckets to drop must not be negative, got %d", *o.TrailingBucketsToDrop)
+	}
+	if o.NameCacheTTLSeconds < 0 {
+		return fmt.Errorf("name cache TTL must not be negative, got %d", o.NameCacheTTLSeconds)
+	}
+	if o.HealthCheckCacheSeconds < 0 {
+		return fmt.Errorf("health check cache duration must not be negative, got %d", o.HealthCheckCacheSeconds)
+	}
+	for seriesName := range o.SeriesOverrides {
+		_, isServerSeries := serverSeriesToUnit[seriesName]
+		_, isLoadBalancerSeries := loadBalancerSeriesToUnit[seriesName]
+		if !isServerSeries && !isLoadBalancerSeries {
+			return fmt.Errorf("series override for unknown series %q", seriesName)
+		}
+	}
+	for metricsType, steps := range o.Thresholds {
+		if err := validateThresholds(metricsType, steps); err != nil {
+			return err
+		}
+	}
+	for name, selector := range o.SavedSelectors {
+		if strings.TrimSpace(selector) == "" {
+			return fmt.Errorf("saved selector %q is empty", name)
+		}
+		if err := validateLabelSelector(selector); err != nil {
+			return fmt.Errorf("saved selector %q: %w", name, err)
+		}
+	}
+	if strings.ContainsFunc(o.AppIdentifier, unicode.IsControl) {
+		return fmt.Errorf("app identifier must not contain control characters, got %q", o.AppIdentifier)
+	}
+	if err := validateCustomHeaders(o.CustomHeaders); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateThresholds(metricsType MetricsType, steps []ThresholdStep) error {
+	_, isServerType := serverMetricsTypeSeries[metricsType]
+	_, isLoadBalancerType := loadBalancerMetricsTypeSeries[metricsType]
+	if !isServerType && !isLoadBalancerType {
+		return fmt.Errorf("thresholds for unknown metrics type %q", metricsType)
+	}
+
+	lowerBound := math.Inf(-1)
+	for i, step := range steps {
+		if step.Color == "" {
+			return fmt.Errorf("threshold %d of metrics type %q has no color", i, metricsType)
+		}
+		if step.Value == nil {
+			if i > 0 {
+				return fmt.Errorf("threshold %d of metrics type %q has no value, only the first threshold may omit it", i, metricsType)
+			}
+			continue
+		}
+		if *step.Value <= lowerBound {
+			return fmt.Errorf("thresholds of metrics type %q must be in ascending order", metricsType)
+		}
+		lowerBound = *step.Value
+	}
+	return nil
+}
+
+// applicationVersion returns the version used in the user agent, including the app identifier if it is set.
+func (o Options) applicationVersion(version string) string {
+	if o.AppIdentifier == "" {
+		return version
+	}
+	return version + " " + o.AppIdentifier
+}
+
+func (o Options) apiTimeout() time.Duration {
+	if o.APITimeoutSeconds <= 0 {
+		return DefaultAPITimeout
+	}
+	return time.Duration(o.APITimeoutSeconds) * time.Second
+}
+
+func (o Options) healthCheckCacheDuration() time.Duration {
+	if o.HealthCheckCacheSeconds <= 0 {
+		return DefaultHealthCheckCacheDuration
+	}
+	return time.Duration(o.HealthCheckCacheSeconds) * time.Second
+}
+
+// maxPointsPerSeries returns the limit for [limitStep], zero disables it.
+func (o Options) maxPointsPerSeries() int {
+	if o.MaxPointsPerSeries == nil {
+		return DefaultMaxPointsPerSeries
+	}
+	return *o.MaxPointsPerSeries
+}
+
+func (o Options) maxResources() int {
+	if o.MaxResources <= 0 {
+		return DefaultMaxResources
+	}
+	return o.MaxResources
+}
+
+func (o Options) trailingBucketsToDrop() int {
+	if o.TrailingBucketsToDrop == nil {
+		return DefaultTrailingBucketsToDrop
+	}
+	return *o.TrailingBucketsToDrop
+}
+
+func (o Options) queryConcurrency() int {
+	if o.QueryConcurrency <= 0 {
+		return DefaultQueryConcurrency
+	}
+	return o.QueryConcurrency
 }
 
 type QueryModel struct {
 	ResourceType ResourceType `json:"resourceType"`
-	MetricsType  MetricsType  `json:"metricsType"`
+	// ResourceTypes lists the resources of multiple types in one resource list query. It takes precedence over
+	// ResourceType and is not supported by other query types.
+	ResourceTypes []ResourceType `json:"resourceTypes"`
+	// MetricsType is kept for backwards compatibility with older queries, use MetricsTypes instead.
+	MetricsType  MetricsType   `json:"metricsType"`
+	MetricsTypes []MetricsType `json:"metricsTypes"`
 
 	SelectBy       SelectBy `json:"selectBy"`
 	LabelSelectors []string `json:"labelSelectors"`
 	ResourceIDs    []int64  `json:"resourceIds"`
+	ResourceNames  []string `json:"resourceNames"`
+	// Resources is a freeform list of resources for [SelectByResource], ie. "web-1, 12345, db-prod". Numeric entries
+	// are used as IDs and must be positive, all other entries are resolved by name. Entries can contain multiple
+	// resources separated by commas or spaces.
+	Resources []string `json:"resources"`
+
+	// SavedSelector is the name of a label selector from [Options.SavedSelectors]. It is combined with LabelSelectors.
+	SavedSelector string `json:"savedSelector"`
+
+	// ExcludeLabelSelectors removes all resources that match any of the selectors from the selection, ie. the canary
+	// servers from all production servers. It works with every [SelectBy] method.
+	ExcludeLabelSelectors []string `json:"excludeLabelSelectors"`
+
+	// StatusSelector restricts the selected servers to those with one of the statuses, ie. only running servers. It
+	// works with every [SelectBy] method, but only for servers.
+	StatusSelector []hcloud.ServerStatus `json:"statusSelector"`
+
+	// NameFilter is a regular expression that further restricts the selected resources to those with a matching
+	// name. It is applied with every [SelectBy] method, ie. to select only some of the resources in a list of IDs.
+	NameFilter string `json:"nameFilter"`
 
 	LegendFormat string `json:"legendFormat"`
+
+	// FrameName is a template for the names of the returned frames, with the same syntax as LegendFormat. The default
+	// is the resource name followed by the series name.
+	FrameName string `json:"frameName"`
+
+	// Debug enables verbose logging for this query only.
+	Debug bool `json:"debug"`
+
+	// Aggregation combines the series of all selected resources into a single series per series name.
+	Aggregation Aggregation `json:"aggregation"`
+
+	// Limit restricts the number of resources returned by resource list queries. Resources are sorted by ID before
+	// the limit is applied, so the result is stable. Zero disables the limit.
+	Limit int `json:"limit"`
+
+	// NetworkID restricts load balancer resource list queries to load balancers attached to this network.
+	// Zero disables the filter.
+	NetworkID int64 `json:"networkId"`
+
+	// IncludeSubnets adds the subnets of every network as JSON to network resource list queries.
+	IncludeSubnets bool `json:"includeSubnets"`
+
+	// IncludePrices adds the hourly and monthly prices of the server type in the location of every server to server
+	// resource list queries.
+	IncludePrices bool `json:"includePrices"`
+
+	// TopN limits the result to the N resources with the highest TopNBy statistic. Zero disables the limit.
+	TopN   int    `json:"topN"`
+	TopNBy TopNBy `json:"topNBy"`
+
+	// SeriesSort orders the returned series by their last value or name, see [sortSeries]. By default, the series are
+	// ordered by resource ID and series name.
+	SeriesSort SeriesSort `json:"seriesSort"`
+
+	// Step is the resolution of metrics in seconds. If it is not set, the step is calculated from the interval of the
+	// query. The step is still raised if the query would return too many data points, see [limitStep].
+	Step int `json:"step"`
+
+	// AlignStepToBoundary extends the time range to multiples of the step, see [alignTimeRange].
+	AlignStepToBoundary bool `json:"alignStepToBoundary"`
+
+	// Instant only returns the last value of every series, ie. for stat panels, see [keepLastValues].
+	Instant bool `json:"instant"`
+
+	// CompareOffset additionally returns the series of the time range shifted back by this duration (ie. "7d"), for
+	// week-over-week comparisons. They are moved forward to overlay the current series and have the label
+	// [LabelOffset], see [parseCompareOffset].
+	CompareOffset string `json:"compareOffset"`
+
+	// OutputFormat selects between one frame per series and a single wide frame for time series queries.
+	OutputFormat OutputFormat `json:"outputFormat"`
+
+	// CheckOtherResourceType looks up explicitly selected IDs that do not exist for ResourceType as the other resource
+	// type, so the notice can tell the user that they selected the ID of a load balancer in a server query or vice
+	// versa. This costs one API request per missing ID.
+	CheckOtherResourceType bool `json:"checkOtherResourceType"`
+
+	// UnitOverrides replaces the unit of series for this query only, keyed by the series name (ie. "cpu"). Series
+	// without an override use the unit from the series overrides in the options or the built-in unit.
+	UnitOverrides map[string]string `json:"unitOverrides"`
+
+	// RawValues removes the units of all series, so Grafana shows the values as returned by the API (ie. bytes per
+	// second instead of a formatted bandwidth).
+	RawValues bool `json:"rawValues"`
+}
+
+type OutputFormat string
+
+const (
+	// OutputFormatLong returns one frame per series. This is the default.
+	OutputFormatLong OutputFormat = "long"
+	// OutputFormatWide returns a single frame with a shared time field and one field per series, see [wideFrames].
+	OutputFormatWide OutputFormat = "wide"
+)
+
+// queryOutputFormat returns the output format of the query. Invalid queries are reported by the query handlers, they
+// use the default format here.
+func queryOutputFormat(query backend.DataQuery) OutputFormat {
+	var qm struct {
+		OutputFormat OutputFormat `json:"outputFormat"`
+	}
+	if err := json.Unmarshal(query.JSON, &qm); err != nil {
+		return OutputFormatLong
+	}
+	return qm.OutputFormat
+}
+
+// RequestedMetricsTypes returns all metrics types requested by the query. If MetricsTypes is empty,
+// it falls back to the single MetricsType.
+func (qm QueryModel) RequestedMetricsTypes() []MetricsType {
+	if len(qm.MetricsTypes) > 0 {
+		return qm.MetricsTypes
+	}
+
+	if qm.MetricsType != "" {
+		return []MetricsType{qm.MetricsType}
+	}
+
+	return nil
+}
+
+// legacySelectByVariable is the select by value of the "Variable" mode in older versions of the query editor. The
+// frontend fills in the IDs of the variable before the query is sent, but queries that are not run through the
+// frontend (ie. alert rules) contain the variable without any IDs.
+const legacySelectByVariable SelectBy = "name"
+
+// errUnresolvedVariable is returned for queries with [legacySelectByVariable] that have no IDs.
+var errUnresolvedVariable = errors.New(`selectBy "name" requires a variable that is resolved by the query editor, which is not available for this query (ie. in alert rules): select the resources by label, id or name instead`)
+
+// UnmarshalJSON decodes the query and migrates fields of older plugin versions, so saved dashboards keep working
+// after an upgrade:
+//
+//   - metricsType (single value) is copied to metricsTypes, if metricsTypes is not set.
+//   - selectBy "name" is replaced with "id" if the IDs were filled in from the variable. Without IDs, the variable was
+//     never resolved and an error is returned, instead of silently selecting nothing.
+//   - aggregation and topNBy default to "none" and "last" for queries saved before these options existed.
+//
+// Older queries use the key "resourceIDs", this is matched by the case-insensitive decoding of encoding/json.
+func (qm *QueryModel) UnmarshalJSON(b []byte) error {
+	// queryModel has the same fields, but not the UnmarshalJSON method, to avoid an infinite recursion.
+	type queryModel QueryModel
+
+	var decoded queryModel
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return err
+	}
+
+	if len(decoded.MetricsTypes) == 0 && decoded.MetricsType != "" {
+		decoded.MetricsTypes = []MetricsType{decoded.MetricsType}
+	}
+	if decoded.SelectBy == legacySelectByVariable {
+		if len(decoded.ResourceIDs) == 0 {
+			return errUnresolvedVariable
+		}
+		decoded.SelectBy = SelectByID
+	}
+	if decoded.Aggregation == "" {
+		decoded.Aggregation = AggregationNone
+	}
+	if decoded.TopNBy == "" {
+		decoded.TopNBy = TopNByLast
+	}
+
+	*qm = QueryModel(decoded)
+	return nil
+}
+
+// requestedResourceTypes returns all resource types requested by the query. If ResourceTypes is empty, it falls back
+// to the single ResourceType.
+func (qm QueryModel) requestedResourceTypes() []ResourceType {
+	if len(qm.ResourceTypes) > 0 {
+		return qm.ResourceTypes
+	}
+
+	if qm.ResourceType != "" {
+		return []ResourceType{qm.ResourceType}
+	}
+
+	return nil
+}
+
+// validate checks the fields shared by all queries against their known values. Without this, invalid values
+// would silently return no data.
+func (qm QueryModel) validate() error {
+	resourceTypes := qm.requestedResourceTypes()
+	if len(resourceTypes) == 0 {
+		return errors.New("resourceType is required")
+	}
+	for _, resourceType := range resourceTypes {
+		switch resourceType {
+		case ResourceTypeServer, ResourceTypeLoadBalancer, ResourceTypeNetwork, ResourceTypePlacementGroup:
+		default:
+			return fmt.Errorf("unknown resourceType %q, valid values are: %s, %s, %s, %s",
+				resourceType, ResourceTypeServer, ResourceTypeLoadBalancer, ResourceTypeNetwork, ResourceTypePlacementGroup)
+		}
+	}
+
+	switch qm.SelectBy {
+	case "", SelectByLabel, SelectByID, SelectByResourceName, SelectByResource:
+	default:
+		return fmt.Errorf("unknown selectBy %q, valid values are: %s, %s, %s, %s", qm.SelectBy, SelectByLabel, SelectByID, SelectByResourceName, SelectByResource)
+	}
+
+	if qm.Limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", qm.Limit)
+	}
+
+	if qm.SelectBy == SelectByResource {
+		for _, token := range resourceTokens(qm.Resources) {
+			if id, err := strconv.ParseInt(token, 10, 64); err == nil && id <= 0 {
+				return fmt.Errorf("invalid resource ID %q in resources, IDs must be positive", token)
+			}
+		}
+	}
+
+	for _, selector := range qm.LabelSelectors {
+		if err := validateLabelSelector(selector); err != nil {
+			return err
+		}
+	}
+	for _, selector := range qm.ExcludeLabelSelectors {
+		if strings.TrimSpace(selector) == "" {
+			return errors.New("excludeLabelSelectors must not contain empty selectors, they would exclude all resources")
+		}
+		if err := validateLabelSelector(selector); err != nil {
+			return fmt.Errorf("excludeLabelSelectors: %w", err)
+		}
+	}
+
+	if len(qm.StatusSelector) > 0 && slices.ContainsFunc(resourceTypes, func(t ResourceType) bool { return t != ResourceTypeServer }) {
+		return errors.New("statusSelector is only supported for servers")
+	}
+	for _, status := range qm.StatusSelector {
+		if !slices.Contains(serverStatuses, status) {
+			return fmt.Errorf("unknown server status %q in statusSelector", status)
+		}
+	}
+
+	if _, err := regexp.Compile(qm.NameFilter); err != nil {
+		return fmt.Errorf("invalid nameFilter: %w", err)
+	}
+
+	switch qm.OutputFormat {
+	case "", OutputFormatLong, OutputFormatWide:
+	default:
+		return fmt.Errorf("unknown outputFormat %q, valid values are: %s, %s", qm.OutputFormat, OutputFormatLong, OutputFormatWide)
+	}
+
+	return nil
+}
+
+// validateMetrics checks all fields used by metrics queries.
+func (qm QueryModel) validateMetrics() error {
+	if err := qm.validate(); err != nil {
+		return err
+	}
+
+	if len(qm.ResourceTypes) > 0 {
+		return errors.New("resourceTypes is only supported by resource list queries, use resourceType instead")
+	}
+	if qm.SelectBy == "" {
+		return errors.New("selectBy is required")
+	}
+	if qm.TopN < 0 {
+		return fmt.Errorf("topN must not be negative, got %d", qm.TopN)
+	}
+	if qm.Step < 0 {
+		return fmt.Errorf("step must not be negative, got %d", qm.Step)
+	}
+	if err := qm.Aggregation.Validate(); err != nil {
+		return err
+	}
+	if err := qm.TopNBy.Validate(); err != nil {
+		return err
+	}
+	if err := qm.SeriesSort.Validate(); err != nil {
+		return err
+	}
+	if qm.CompareOffset != "" {
+		if _, err := parseCompareOffset(qm.CompareOffset); err != nil {
+			return fmt.Errorf("invalid compareOffset: %w", err)
+		}
+	}
+
+	return validateMetricsTypes(qm.ResourceType, qm.RequestedMetricsTypes())
+}
+
+// validateServers checks all fields used by the point-in-time server queries, ie. [QueryTypeServerSpecs].
+func (qm QueryModel) validateServers() error {
+	if err := qm.validate(); err != nil {
+		return err
+	}
+
+	if qm.ResourceType != ResourceTypeServer || len(qm.ResourceTypes) > 0 {
+		return fmt.Errorf("only resourceType %s is supported", ResourceTypeServer)
+	}
+	if qm.SelectBy == "" {
+		return errors.New("selectBy is required")
+	}
+
+	return nil
 }
 
 type Label string
@@ -81,15 +645,58 @@ const (
 	LabelName              = "name"
 	LabelSeriesName        = "series_name"
 	LabelSeriesDisplayName = "series_display_name"
+	LabelUnit              = "unit"
+	LabelDirection         = "direction"
+	// LabelProject is only added if [Options.IncludeProjectLabel] is enabled.
+	LabelProject = "project"
+	// LabelOffset is only added to the previous period of [QueryModel.CompareOffset].
+	LabelOffset = "offset"
 )
 
 const (
 	AutoLegendFormat = "{{ series_display_name }} {{ name }}"
+	DefaultVarFormat = "{{ name }} : {{ id }}"
 
 	// DefaultBufferPeriod is the default buffer period for the QueryRunner.
 	DefaultBufferPeriod = 200 * time.Millisecond
 
+	// ResourceListPerPage is the page size used when listing resources. This is the maximum allowed by the API and
+	// keeps the number of requests low for large projects.
+	ResourceListPerPage = 50
+
+	// DefaultAPITimeout is the default timeout for every metrics request to the API.
+	DefaultAPITimeout = 20 * time.Second
+
+	// DefaultQueryConcurrency is the default maximum number of queries processed in parallel.
+	DefaultQueryConcurrency = 10
+
+	// DefaultNameCacheSize is the default maximum number of entries in each NameCache.
+	DefaultNameCacheSize = 10000
+
+	// DefaultMaxPointsPerSeries is the default maximum number of data points requested per series.
+	DefaultMaxPointsPerSeries = 10000
+
+	// DefaultMaxResources is the default maximum number of resources selected by a single query.
+	DefaultMaxResources = 100
+
+	// DefaultTrailingBucketsToDrop is the default number of incomplete buckets dropped at the end of every series.
+	DefaultTrailingBucketsToDrop = 1
+
+	// DefaultHealthCheckCacheDuration is the default duration for which a successful health check is reused.
+	DefaultHealthCheckCacheDuration = 30 * time.Second
+
+	// ServerCacheMaxAge is the maximum age of the servers that the server specs, status and traffic queries reuse from
+	// the name cache. The panels of a dashboard are refreshed together, so they share the servers that were fetched for
+	// the first panel (or while resolving its label selector) instead of listing them again. In exchange, a status change
+	// or new traffic shows up with a delay of up to this duration, which is short compared to the billing period the
+	// traffic counters add up over.
+	ServerCacheMaxAge = 30 * time.Second
+
 	InvalidAPITokenErrorMessage = "API Token was not configured or does not work, a valid API Token is required for the data source to access the Hetzner Cloud API"
+
+	// TokenScopeUnknownMessage is added to successful health checks. The API does not return the scope of a token, and
+	// the only way to detect write access would be a write request, which the data source should never send.
+	TokenScopeUnknownMessage = "The scope of the API Token (Read or Read & Write) can not be determined, as the Hetzner Cloud API does not expose it. The data source only needs a Read token"
 )
 
 var legendFormatRegexp = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
@@ -105,69 +712,168 @@ var (
 	_ backend.QueryDataHandler    = (*Datasource)(nil)
 	_ backend.CallResourceHandler = (*Datasource)(nil)
 	_ backend.CheckHealthHandler  = (*Datasource)(nil)
+
+	_ instancemgmt.InstanceDisposer = (*Datasource)(nil)
 )
 
 // NewDatasource creates a new datasource instance.
 func NewDatasource(ctx context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
 	ctxLogger := logger.FromContext(ctx)
 
-	version := "unknown"
-	if buildInfo, err := build.GetBuildInfo(); err != nil {
-		ctxLogger.Warn("get build info failed", "error", err)
-	} else {
-		version = buildInfo.Version
-	}
-
 	token := settings.DecryptedSecureJSONData["apiToken"]
 	if token == "" {
 		ctxLogger.Warn(InvalidAPITokenErrorMessage)
 		// Returning an error here will only show "An error occurred within the plugin" in frontend
 	}
 
-	clientOpts := []hcloud.ClientOption{
-		hcloud.WithToken(token),
-		hcloud.WithApplication("apricote-hcloud-datasource", version),
-		hcloud.WithInstrumentation(prometheus.DefaultRegisterer),
-	}
-
 	options := Options{}
 	err := json.Unmarshal(settings.JSONData, &options)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing options: %w", err)
 	}
 
+	clientOpts := []hcloud.ClientOption{
+		hcloud.WithToken(token),
+		hcloud.WithApplication("apricote-hcloud-datasource", options.applicationVersion(pluginVersion(ctx))),
+		hcloud.WithInstrumentation(prometheus.DefaultRegisterer),
+	}
+
 	if options.Debug {
 		ctxLogger.Info("Debug logging enabled")
 		clientOpts = append(clientOpts, hcloud.WithDebugWriter(logutil.NewDebugWriter(logger)))
 	}
 
+	var httpClient *http.Client
+	if len(options.CustomHeaders) > 0 {
+		ctxLogger.Info("Adding custom headers to API requests", "headers", redactHeaders(options.CustomHeaders))
+		httpClient = &http.Client{}
+		clientOpts = append(clientOpts, hcloud.WithHTTPClient(httpClient))
+	}
+
 	client := hcloud.NewClient(
 		clientOpts...,
 	)
 
+	if httpClient != nil {
+		// The instrumentation replaces the transport of the HTTP client in NewClient, so the headers are added
+		// afterwards. This still runs after the debug writer, which therefore never logs the custom headers.
+		httpClient.Transport = newHeaderTransport(options.CustomHeaders, httpClient.Transport)
+	}
+
+	return newDatasource(options, settings.Name, client, &client.Server, &client.LoadBalancer), nil
+}
+
+// newDatasource creates the data source with the given API clients. Queries for servers and load balancers use
+// servers and loadBalancers instead of client, so tests can replace them with fakes.
+func newDatasource(options Options, name string, client *hcloud.Client, servers ServerClient, loadBalancers LoadBalancerClient) *Datasource {
 	d := &Datasource{
-		client: client,
+		client:        client,
+		servers:       servers,
+		loadBalancers: loadBalancers,
+		options:       options,
+
+		name:    name,
+		project: projectLabel(options, name),
+
+		serverSeries: newSeriesMetadata(serverSeriesToDisplayName, serverSeriesToUnit, options.SeriesOverrides).
+			withThresholds(serverMetricsTypeSeries, options.Thresholds),
+		loadBalancerSeries: newSeriesMetadata(loadBalancerSeriesToDisplayName, loadBalancerSeriesToUnit, options.SeriesOverrides).
+			withThresholds(loadBalancerMetricsTypeSeries, options.Thresholds),
+	}
+
+	bufferPeriod := DefaultBufferPeriod
+	if options.DisableBuffering {
+		bufferPeriod = 0
+	}
+
+	// The queries are processed in parallel, so their API requests share the same limit
+	d.queryRunnerServer = NewQueryRunner[hcloud.ServerMetrics](bufferPeriod, options.apiTimeout(), options.queryConcurrency(), d.serverAPIRequestFn, filterServerMetrics)
+	d.queryRunnerLoadBalancer = NewQueryRunner[hcloud.LoadBalancerMetrics](bufferPeriod, options.apiTimeout(), options.queryConcurrency(), d.loadBalancerAPIRequestFn, filterLoadBalancerMetrics)
+	if options.SingleResourceFastPath {
+		d.queryRunnerServer.EnableFastPath()
+		d.queryRunnerLoadBalancer.EnableFastPath()
 	}
 
-	d.queryRunnerServer = NewQueryRunner[hcloud.ServerMetrics](DefaultBufferPeriod, d.serverAPIRequestFn, filterServerMetrics)
-	d.queryRunnerLoadBalancer = NewQueryRunner[hcloud.LoadBalancerMetrics](DefaultBufferPeriod, d.loadBalancerAPIRequestFn, filterLoadBalancerMetrics)
+	d.nameCacheServer = NewNameCache[hcloud.Server](client, d.getServerFn, serverIdentifier, options.NameCacheSize)
+	d.nameCacheLoadBalancer = NewNameCache[hcloud.LoadBalancer](client, d.getLoadBalancerFn, loadBalancerIdentifier, options.NameCacheSize)
+	if options.NameCacheTTLSeconds > 0 {
+		d.nameCacheServer.SetTTL(time.Duration(options.NameCacheTTLSeconds) * time.Second)
+		d.nameCacheLoadBalancer.SetTTL(time.Duration(options.NameCacheTTLSeconds) * time.Second)
+	}
 
-	d.nameCacheServer = NewNameCache[hcloud.Server](client, d.getServerFn, func(server *hcloud.Server) (int64, string) { return server.ID, server.Name })
-	d.nameCacheLoadBalancer = NewNameCache[hcloud.LoadBalancer](client, d.getLoadBalancerFn, func(loadBalancer *hcloud.LoadBalancer) (int64, string) { return loadBalancer.ID, loadBalancer.Name })
+	if options.PreloadNameCache {
+		// Creating the instance should not wait for potentially many paginated API requests
+		go d.warmNameCaches(context.Background())
+	}
 
-	return d, nil
+	return d
 }
 
 // Datasource is an example datasource which can respond to data queries, reports
 // its health and has streaming skills.
 type Datasource struct {
 	client *hcloud.Client
+	// servers and loadBalancers are the facets of client that are used by most queries, see [ServerClient]
+	servers       ServerClient
+	loadBalancers LoadBalancerClient
+	options       Options
 
 	queryRunnerServer       *QueryRunner[hcloud.ServerMetrics]
 	queryRunnerLoadBalancer *QueryRunner[hcloud.LoadBalancerMetrics]
 
 	nameCacheServer       *NameCache[hcloud.Server]
 	nameCacheLoadBalancer *NameCache[hcloud.LoadBalancer]
+
+	serverSeries       seriesMetadata
+	loadBalancerSeries seriesMetadata
+
+	healthCache healthCache
+
+	// project is the value of [LabelProject], or empty if the label is disabled.
+	project string
+	// name is the name of the data source in Grafana
+	name string
+}
+
+// Dispose is called by the instance manager when the data source settings changed and a new instance was created.
+// Queries that are still waiting for the buffer of the old instance fail right away instead of reaching the API
+// with outdated settings (ie. a revoked token).
+func (d *Datasource) Dispose() {
+	d.queryRunnerServer.Close()
+	d.queryRunnerLoadBalancer.Close()
+}
+
+func projectLabel(options Options, datasourceName string) string {
+	if !options.IncludeProjectLabel {
+		return ""
+	}
+	if options.ProjectName != "" {
+		return options.ProjectName
+	}
+	return datasourceName
+}
+
+// addProjectLabel adds [LabelProject] to the values field of all frames and renders their display names again, so the
+// label is available in legend formats. It does nothing if the label is disabled.
+func (d *Datasource) addProjectLabel(frames []*data.Frame, legendFormat string) {
+	if d.project == "" {
+		return
+	}
+
+	for _, frame := range frames {
+		if len(frame.Fields) < 2 {
+			continue
+		}
+		valuesField := frame.Fields[len(frame.Fields)-1]
+		if valuesField.Labels == nil {
+			valuesField.Labels = data.Labels{}
+		}
+		valuesField.Labels[LabelProject] = d.project
+
+		if valuesField.Config != nil {
+			valuesField.Config.DisplayNameFromDS = getDisplayName(legendFormat, valuesField.Labels)
+		}
+	}
 }
 
 // QueryData handles multiple queries and returns multiple responses.
@@ -178,8 +884,10 @@ func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataReques
 	// create response struct
 	resp := backend.NewQueryDataResponse()
 
+	fromAlert := req.Headers[FromAlertHeader] == "true"
+
 	// loop over queries and execute them individually.
-	s := stream.New().WithMaxGoroutines(10)
+	s := stream.New().WithMaxGoroutines(d.options.queryConcurrency())
 	for _, q := range req.Queries {
 		q := q
 		s.Go(func() stream.Callback {
@@ -190,6 +898,23 @@ func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataReques
 				res = d.queryResourceList(ctx, q)
 			case QueryTypeMetrics:
 				res = d.queryMetrics(ctx, q)
+			case QueryTypeServerSpecs:
+				res = d.queryServerSpecs(ctx, q)
+			case QueryTypeServerStatus:
+				res = d.queryServerStatus(ctx, q)
+			case QueryTypeServerTraffic:
+				res = d.queryServerTraffic(ctx, q, serverTrafficPercent)
+			case QueryTypeServerTrafficRemaining:
+				res = d.queryServerTraffic(ctx, q, serverTrafficRemaining)
+			case QueryTypeActions:
+				res = d.queryActions(ctx, q)
+			case QueryTypeEvents:
+				res = d.queryEvents(ctx, q)
+			}
+
+			isTimeSeries := q.QueryType != QueryTypeResourceList && q.QueryType != QueryTypeServerSpecs && q.QueryType != QueryTypeActions && q.QueryType != QueryTypeEvents
+			if res.Error == nil && isTimeSeries && (fromAlert || queryOutputFormat(q) == OutputFormatWide) {
+				res.Frames = wideFrames(res.Frames)
 			}
 
 			// conc makes sure that all callbacks are called in
@@ -211,71 +936,214 @@ func (d *Datasource) queryResourceList(ctx context.Context, query backend.DataQu
 		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("json unmarshal: %v", err.Error()))
 	}
 
+	if err := queryData.validate(); err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("invalid query: %v", err))
+	}
+
+	queryData, err = d.resolveSavedSelector(queryData)
+	if err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("invalid query: %v", err))
+	}
+
+	resourceTypes := queryData.requestedResourceTypes()
+	for _, resourceType := range resourceTypes {
+		queryData.ResourceType = resourceType
+		typeResp := d.queryResourceListOfType(ctx, queryData)
+		if typeResp.Error != nil {
+			return typeResp
+		}
+
+		if len(resourceTypes) > 1 {
+			// Identifies the resource type of every row if the frames are merged into a single table
+			for _, frame := range typeResp.Frames {
+				frame.Fields = slices.Insert(frame.Fields, 0,
+					data.NewField("resource_type", nil, slices.Repeat([]string{string(resourceType)}, frame.Rows())),
+				)
+			}
+		}
+
+		resp.Frames = append(resp.Frames, typeResp.Frames...)
+	}
+
+	return resp
+}
+
+// queryResourceListOfType returns the resource list of [QueryModel.ResourceType].
+func (d *Datasource) queryResourceListOfType(ctx context.Context, queryData QueryModel) backend.DataResponse {
+	var resp backend.DataResponse
+
 	switch queryData.ResourceType {
 	case ResourceTypeServer:
-		servers, err := d.client.Server.AllWithOpts(ctx, hcloud.ServerListOpts{ListOpts: hcloud.ListOpts{LabelSelector: strings.Join(queryData.LabelSelectors, ", ")}})
+		servers, err := d.servers.AllWithOpts(ctx, hcloud.ServerListOpts{
+			ListOpts: hcloud.ListOpts{
+				LabelSelector: strings.Join(queryData.LabelSelectors, ", "),
+				PerPage:       ResourceListPerPage,
+			},
+			Status: queryData.StatusSelector,
+		})
 		if err != nil {
 			err = NicerErrorMessages(err)
 			return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, fmt.Sprintf("error getting servers: %v", err.Error()))
 		}
+		servers = sortAndLimit(servers, queryData.Limit, func(server *hcloud.Server) int64 { return server.ID })
 
 		ids := make([]int64, 0, len(servers))
 		vars := make([]string, 0, len(servers))
 		names := make([]string, 0, len(servers))
 		serverTypes := make([]string, 0, len(servers))
 		status := make([]string, 0, len(servers))
+		deleteProtection := make([]bool, 0, len(servers))
+		rebuildProtection := make([]bool, 0, len(servers))
+		locked := make([]bool, 0, len(servers))
+		locations := make([]string, 0, len(servers))
+		datacenters := make([]string, 0, len(servers))
+		serverNetworkZones := make([]string, 0, len(servers))
+		images := make([]string, 0, len(servers))
+		imageTypes := make([]string, 0, len(servers))
+		osFlavors := make([]string, 0, len(servers))
+		osVersions := make([]string, 0, len(servers))
+		isos := make([]string, 0, len(servers))
+		rescueEnabled := make([]bool, 0, len(servers))
+		backupEnabled := make([]bool, 0, len(servers))
+		backupWindows := make([]string, 0, len(servers))
+		var prices serverPrices
 		labels := make([]json.RawMessage, 0, len(servers))
+		labelStrings := make([]string, 0, len(servers))
 
 		for _, server := range servers {
 			ids = append(ids, server.ID)
-			vars = append(vars, fmt.Sprintf("%s : %d", server.Name, server.ID))
+			vars = append(vars, getVar(d.options.VarFormat, server.ID, server.Name))
 			names = append(names, server.Name)
 			serverTypes = append(serverTypes, server.ServerType.Name)
 			status = append(status, string(server.Status))
+			deleteProtection = append(deleteProtection, server.Protection.Delete)
+			rebuildProtection = append(rebuildProtection, server.Protection.Rebuild)
+			locked = append(locked, server.Locked)
+			datacenter, location, networkZone := "", "", ""
+			if server.Datacenter != nil {
+				datacenter = server.Datacenter.Name
+				if server.Datacenter.Location != nil {
+					location = server.Datacenter.Location.Name
+					networkZone = string(server.Datacenter.Location.NetworkZone)
+				}
+			}
+			datacenters = append(datacenters, datacenter)
+			locations = append(locations, location)
+			serverNetworkZones = append(serverNetworkZones, networkZone)
+			images = append(images, imageName(server.Image))
+			imageType, osFlavor, osVersion := "", "", ""
+			if server.Image != nil {
+				imageType, osFlavor, osVersion = string(server.Image.Type), server.Image.OSFlavor, server.Image.OSVersion
+			}
+			imageTypes = append(imageTypes, imageType)
+			osFlavors = append(osFlavors, osFlavor)
+			osVersions = append(osVersions, osVersion)
+			iso := ""
+			if server.ISO != nil {
+				iso = server.ISO.Name
+				if iso == "" {
+					// Private ISOs have no name
+					iso = server.ISO.Description
+				}
+			}
+			isos = append(isos, iso)
+			rescueEnabled = append(rescueEnabled, server.RescueEnabled)
+			// The API only returns a backup window if backups are enabled
+			backupEnabled = append(backupEnabled, server.BackupWindow != "")
+			backupWindows = append(backupWindows, server.BackupWindow)
+			if queryData.IncludePrices {
+				prices.append(serverPricing(server.ServerType, location))
+			}
 
 			labelBytes, err := json.Marshal(server.Labels)
 			if err != nil {
 				return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourcePlugin, fmt.Sprintf("failed to encode server labels: %v", err.Error()))
 			}
 			labels = append(labels, labelBytes)
+			labelStrings = append(labelStrings, labelsString(server.Labels))
 		}
 
 		frame := data.NewFrame("servers")
+		if len(servers) == 0 && len(queryData.StatusSelector) > 0 {
+			frame.AppendNotices(noMatchingStatusNotice(queryData.StatusSelector))
+		}
 		frame.Fields = append(frame.Fields,
 			data.NewField("id", nil, ids),
 			data.NewField("var", nil, vars),
 			data.NewField("name", nil, names),
 			data.NewField("server_type", nil, serverTypes),
 			data.NewField("status", nil, status),
+			data.NewField("delete_protection", nil, deleteProtection),
+			data.NewField("rebuild_protection", nil, rebuildProtection),
+			data.NewField("locked", nil, locked),
+			data.NewField("location", nil, locations),
+			data.NewField("datacenter", nil, datacenters),
+			data.NewField("network_zone", nil, serverNetworkZones),
+			data.NewField("image", nil, images),
+			data.NewField("image_type", nil, imageTypes),
+			data.NewField("os_flavor", nil, osFlavors),
+			data.NewField("os_version", nil, osVersions),
+			data.NewField("iso", nil, isos),
+			data.NewField("rescue_enabled", nil, rescueEnabled),
+			data.NewField("backup_enabled", nil, backupEnabled),
+			data.NewField("backup_window", nil, backupWindows),
+		)
+		if queryData.IncludePrices {
+			frame.Fields = append(frame.Fields, prices.fields()...)
+		}
+		frame.Fields = append(frame.Fields,
 			data.NewField("labels", nil, labels),
+			data.NewField("labels_string", nil, labelStrings),
 		)
 
 		resp.Frames = append(resp.Frames, frame)
 
 	case ResourceTypeLoadBalancer:
-		loadBalancers, err := d.client.LoadBalancer.AllWithOpts(ctx, hcloud.LoadBalancerListOpts{ListOpts: hcloud.ListOpts{LabelSelector: strings.Join(queryData.LabelSelectors, ", ")}})
+		loadBalancers, err := d.loadBalancers.AllWithOpts(ctx, hcloud.LoadBalancerListOpts{ListOpts: hcloud.ListOpts{
+			LabelSelector: strings.Join(queryData.LabelSelectors, ", "),
+			PerPage:       ResourceListPerPage,
+		}})
 		if err != nil {
 			err = NicerErrorMessages(err)
 			return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, fmt.Sprintf("error getting load balancers: %v", err.Error()))
 		}
+		if queryData.NetworkID != 0 {
+			loadBalancers = slices.DeleteFunc(loadBalancers, func(lb *hcloud.LoadBalancer) bool {
+				return !slices.ContainsFunc(lb.PrivateNet, func(privateNet hcloud.LoadBalancerPrivateNet) bool {
+					return privateNet.Network != nil && privateNet.Network.ID == queryData.NetworkID
+				})
+			})
+		}
+		loadBalancers = sortAndLimit(loadBalancers, queryData.Limit, func(lb *hcloud.LoadBalancer) int64 { return lb.ID })
 
 		ids := make([]int64, 0, len(loadBalancers))
 		vars := make([]string, 0, len(loadBalancers))
 		names := make([]string, 0, len(loadBalancers))
 		loadBalancerTypes := make([]string, 0, len(loadBalancers))
+		locations := make([]string, 0, len(loadBalancers))
+		loadBalancerNetworkZones := make([]string, 0, len(loadBalancers))
 		labels := make([]json.RawMessage, 0, len(loadBalancers))
+		labelStrings := make([]string, 0, len(loadBalancers))
 
 		for _, lb := range loadBalancers {
 			ids = append(ids, lb.ID)
-			vars = append(vars, fmt.Sprintf("%s : %d", lb.Name, lb.ID))
+			vars = append(vars, getVar(d.options.VarFormat, lb.ID, lb.Name))
 			names = append(names, lb.Name)
 			loadBalancerTypes = append(loadBalancerTypes, lb.LoadBalancerType.Name)
+			if lb.Location != nil {
+				locations = append(locations, lb.Location.Name)
+				loadBalancerNetworkZones = append(loadBalancerNetworkZones, string(lb.Location.NetworkZone))
+			} else {
+				locations = append(locations, "")
+				loadBalancerNetworkZones = append(loadBalancerNetworkZones, "")
+			}
 
 			labelBytes, err := json.Marshal(lb.Labels)
 			if err != nil {
 				return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourcePlugin, fmt.Sprintf("failed to encode load balancer labels: %v", err.Error()))
 			}
 			labels = append(labels, labelBytes)
+			labelStrings = append(labelStrings, labelsString(lb.Labels))
 		}
 
 		frame := data.NewFrame("load-balancers")
@@ -284,17 +1152,359 @@ func (d *Datasource) queryResourceList(ctx context.Context, query backend.DataQu
 			data.NewField("var", nil, vars),
 			data.NewField("name", nil, names),
 			data.NewField("load_balancer_type", nil, loadBalancerTypes),
+			data.NewField("location", nil, locations),
+			data.NewField("network_zone", nil, loadBalancerNetworkZones),
 			data.NewField("labels", nil, labels),
+			data.NewField("labels_string", nil, labelStrings),
 		)
 
 		resp.Frames = append(resp.Frames, frame)
-	default:
+
+	case ResourceTypeNetwork:
+		networks, err := d.client.Network.AllWithOpts(ctx, hcloud.NetworkListOpts{ListOpts: hcloud.ListOpts{
+			LabelSelector: strings.Join(queryData.LabelSelectors, ", "),
+			PerPage:       ResourceListPerPage,
+		}})
+		if err != nil {
+			err = NicerErrorMessages(err)
+			return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, fmt.Sprintf("error getting networks: %v", err.Error()))
+		}
+		networks = sortAndLimit(networks, queryData.Limit, func(network *hcloud.Network) int64 { return network.ID })
+
+		ids := make([]int64, 0, len(networks))
+		vars := make([]string, 0, len(networks))
+		names := make([]string, 0, len(networks))
+		ipRanges := make([]string, 0, len(networks))
+		networkZones := make([]string, 0, len(networks))
+		subnetCounts := make([]int64, 0, len(networks))
+		serverCounts := make([]int64, 0, len(networks))
+		subnets := make([]json.RawMessage, 0, len(networks))
+		labels := make([]json.RawMessage, 0, len(networks))
+		labelStrings := make([]string, 0, len(networks))
+
+		for _, network := range networks {
+			ids = append(ids, network.ID)
+			vars = append(vars, getVar(d.options.VarFormat, network.ID, network.Name))
+			names = append(names, network.Name)
+			ipRange := ""
+			if network.IPRange != nil {
+				ipRange = network.IPRange.String()
+			}
+			ipRanges = append(ipRanges, ipRange)
+			networkZones = append(networkZones, strings.Join(subnetNetworkZones(network.Subnets), ", "))
+			subnetCounts = append(subnetCounts, int64(len(network.Subnets)))
+			serverCounts = append(serverCounts, int64(len(network.Servers)))
+
+			if queryData.IncludeSubnets {
+				subnetBytes, err := json.Marshal(networkSubnets(network.Subnets))
+				if err != nil {
+					return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourcePlugin, fmt.Sprintf("failed to encode network subnets: %v", err.Error()))
+				}
+				subnets = append(subnets, subnetBytes)
+			}
+
+			labelBytes, err := json.Marshal(network.Labels)
+			if err != nil {
+				return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourcePlugin, fmt.Sprintf("failed to encode network labels: %v", err.Error()))
+			}
+			labels = append(labels, labelBytes)
+			labelStrings = append(labelStrings, labelsString(network.Labels))
+		}
+
+		frame := data.NewFrame("networks")
+		frame.Fields = append(frame.Fields,
+			data.NewField("id", nil, ids),
+			data.NewField("var", nil, vars),
+			data.NewField("name", nil, names),
+			data.NewField("ip_range", nil, ipRanges),
+			data.NewField("network_zone", nil, networkZones),
+			data.NewField("subnet_count", nil, subnetCounts),
+			data.NewField("server_count", nil, serverCounts),
+		)
+		if queryData.IncludeSubnets {
+			frame.Fields = append(frame.Fields, data.NewField("subnets", nil, subnets))
+		}
+		frame.Fields = append(frame.Fields,
+			data.NewField("labels", nil, labels),
+			data.NewField("labels_string", nil, labelStrings),
+		)
+
+		resp.Frames = append(resp.Frames, frame)
+
+	case ResourceTypePlacementGroup:
+		placementGroups, err := d.client.PlacementGroup.AllWithOpts(ctx, hcloud.PlacementGroupListOpts{ListOpts: hcloud.ListOpts{
+			LabelSelector: strings.Join(queryData.LabelSelectors, ", "),
+			PerPage:       ResourceListPerPage,
+		}})
+		if err != nil {
+			err = NicerErrorMessages(err)
+			return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, fmt.Sprintf("error getting placement groups: %v", err.Error()))
+		}
+		placementGroups = sortAndLimit(placementGroups, queryData.Limit, func(pg *hcloud.PlacementGroup) int64 { return pg.ID })
+
+		ids := make([]int64, 0, len(placementGroups))
+		vars := make([]string, 0, len(placementGroups))
+		names := make([]string, 0, len(placementGroups))
+		types := make([]string, 0, len(placementGroups))
+		serverCounts := make([]int64, 0, len(placementGroups))
+		serverIDs := make([]json.RawMessage, 0, len(placementGroups))
+		labels := make([]json.RawMessage, 0, len(placementGroups))
+		labelStrings := make([]string, 0, len(placementGroups))
+
+		for _, pg := range placementGroups {
+			ids = append(ids, pg.ID)
+			vars = append(vars, getVar(d.options.VarFormat, pg.ID, pg.Name))
+			names = append(names, pg.Name)
+			types = append(types, string(pg.Type))
+			serverCounts = append(serverCounts, int64(len(pg.Servers)))
+
+			servers := pg.Servers
+			if servers == nil {
+				// Encode as an empty list instead of null
+				servers = []int64{}
+			}
+			serverBytes, err := json.Marshal(servers)
+			if err != nil {
+				return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourcePlugin, fmt.Sprintf("failed to encode placement group servers: %v", err.Error()))
+			}
+			serverIDs = append(serverIDs, serverBytes)
+
+			labelBytes, err := json.Marshal(pg.Labels)
+			if err != nil {
+				return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourcePlugin, fmt.Sprintf("failed to encode placement group labels: %v", err.Error()))
+			}
+			labels = append(labels, labelBytes)
+			labelStrings = append(labelStrings, labelsString(pg.Labels))
+		}
+
+		frame := data.NewFrame("placement-groups")
+		frame.Fields = append(frame.Fields,
+			data.NewField("id", nil, ids),
+			data.NewField("var", nil, vars),
+			data.NewField("name", nil, names),
+			data.NewField("type", nil, types),
+			data.NewField("server_count", nil, serverCounts),
+			data.NewField("server_ids", nil, serverIDs),
+			data.NewField("labels", nil, labels),
+			data.NewField("labels_string", nil, labelStrings),
+		)
+
+		resp.Frames = append(resp.Frames, frame)
+	default:
 		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("unknown resource type: %v", queryData.ResourceType))
 	}
 
 	return resp
 }
 
+// validateMetricsTypes returns an error if no metrics type was requested or if any requested metrics type is not
+// available for the resource type. Unknown metrics types would otherwise silently return no data.
+func validateMetricsTypes(resourceType ResourceType, metricsTypes []MetricsType) error {
+	var known map[MetricsType][]string
+	switch resourceType {
+	case ResourceTypeServer:
+		known = serverMetricsTypeSeries
+	case ResourceTypeLoadBalancer:
+		known = loadBalancerMetricsTypeSeries
+	default:
+		return fmt.Errorf("unknown resource type: %q", resourceType)
+	}
+
+	validValues := make([]string, 0, len(known))
+	for metricsType := range known {
+		validValues = append(validValues, string(metricsType))
+	}
+	slices.Sort(validValues)
+
+	if len(metricsTypes) == 0 {
+		return fmt.Errorf("no metrics type selected, valid values for %s are: %s", resourceType, strings.Join(validValues, ", "))
+	}
+
+	for _, metricsType := range metricsTypes {
+		if _, ok := known[metricsType]; !ok {
+			return fmt.Errorf("unknown metrics type %q for %s, valid values are: %s", metricsType, resourceType, strings.Join(validValues, ", "))
+		}
+	}
+
+	return nil
+}
+
+// subnetNetworkZones returns the sorted network zones of the subnets, without duplicates. A network has no zone of its
+// own, only its subnets have one.
+func subnetNetworkZones(subnets []hcloud.NetworkSubnet) []string {
+	zones := make([]string, 0, len(subnets))
+	for _, subnet := range subnets {
+		if subnet.NetworkZone != "" {
+			zones = append(zones, string(subnet.NetworkZone))
+		}
+	}
+	slices.Sort(zones)
+	return slices.Compact(zones)
+}
+
+// NetworkSubnet is the JSON representation of a subnet in network resource list queries.
+type NetworkSubnet struct {
+	Type        string `json:"type"`
+	IPRange     string `json:"ip_range"`
+	NetworkZone string `json:"network_zone"`
+	Gateway     string `json:"gateway"`
+}
+
+func networkSubnets(subnets []hcloud.NetworkSubnet) []NetworkSubnet {
+	result := make([]NetworkSubnet, 0, len(subnets))
+	for _, subnet := range subnets {
+		s := NetworkSubnet{
+			Type:        string(subnet.Type),
+			NetworkZone: string(subnet.NetworkZone),
+		}
+		if subnet.IPRange != nil {
+			s.IPRange = subnet.IPRange.String()
+		}
+		if subnet.Gateway != nil {
+			s.Gateway = subnet.Gateway.String()
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// labelsString renders the labels in the `k=v,k2=v2` form of Hetzner Cloud label selectors, sorted by key. The result
+// can be used as the label selector of another query to select resources with the same labels.
+func labelsString(labels map[string]string) string {
+	keys := slices.Sorted(maps.Keys(labels))
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// serverPricing returns the pricing of the server type in the location, or nil if it is not available.
+func serverPricing(serverType *hcloud.ServerType, location string) *hcloud.ServerTypeLocationPricing {
+	if serverType == nil {
+		return nil
+	}
+
+	for i, pricing := range serverType.Pricings {
+		if pricing.Location != nil && pricing.Location.Name == location {
+			return &serverType.Pricings[i]
+		}
+	}
+	return nil
+}
+
+// serverPrices holds the price fields of the server resource list. The server list response already includes the
+// prices of the server types for all locations, so no additional API requests are necessary.
+type serverPrices struct {
+	hourlyNet    []*float64
+	hourlyGross  []*float64
+	monthlyNet   []*float64
+	monthlyGross []*float64
+	currencies   []string
+}
+
+func (p *serverPrices) append(pricing *hcloud.ServerTypeLocationPricing) {
+	if pricing == nil {
+		pricing = &hcloud.ServerTypeLocationPricing{}
+	}
+
+	p.hourlyNet = append(p.hourlyNet, parsePrice(pricing.Hourly.Net))
+	p.hourlyGross = append(p.hourlyGross, parsePrice(pricing.Hourly.Gross))
+	p.monthlyNet = append(p.monthlyNet, parsePrice(pricing.Monthly.Net))
+	p.monthlyGross = append(p.monthlyGross, parsePrice(pricing.Monthly.Gross))
+	p.currencies = append(p.currencies, pricing.Monthly.Currency)
+}
+
+func (p *serverPrices) fields() []*data.Field {
+	return []*data.Field{
+		data.NewField("price_hourly_net", nil, p.hourlyNet),
+		data.NewField("price_hourly_gross", nil, p.hourlyGross),
+		data.NewField("price_monthly_net", nil, p.monthlyNet),
+		data.NewField("price_monthly_gross", nil, p.monthlyGross),
+		data.NewField("currency", nil, p.currencies),
+	}
+}
+
+// parsePrice parses the decimal string of a price. It returns nil for unknown prices.
+func parsePrice(price string) *float64 {
+	value, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+// imageName returns the name of the image a server was created from. Snapshots and backups have no name, their
+// description is used instead, or the ID if the description is also empty. Servers whose image was deleted have no
+// image and return an empty string.
+func imageName(image *hcloud.Image) string {
+	switch {
+	case image == nil:
+		return ""
+	case image.Name != "":
+		return image.Name
+	case image.Description != "":
+		return image.Description
+	default:
+		return strconv.FormatInt(image.ID, 10)
+	}
+}
+
+// validateLabelSelector catches common syntax errors in label selectors (ie. `env=prod,,tier=web` or
+// `env in (prod,staging`), to return a helpful error instead of the generic invalid input error of the API. The full
+// syntax is only validated by the API.
+func validateLabelSelector(selector string) error {
+	if strings.TrimSpace(selector) == "" {
+		return nil
+	}
+
+	depth := 0
+	term := strings.Builder{}
+
+	checkTerm := func() error {
+		if strings.TrimSpace(term.String()) == "" {
+			return fmt.Errorf("invalid label selector %q: empty expression, expressions are separated by a single comma, e.g. \"env=prod,tier!=db\"", selector)
+		}
+		term.Reset()
+		return nil
+	}
+
+	for _, r := range selector {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("invalid label selector %q: unexpected \")\"", selector)
+			}
+		case r == ',' && depth == 0:
+			if err := checkTerm(); err != nil {
+				return err
+			}
+			continue
+		}
+		term.WriteRune(r)
+	}
+
+	if depth > 0 {
+		return fmt.Errorf("invalid label selector %q: missing \")\"", selector)
+	}
+	return checkTerm()
+}
+
+// sortAndLimit sorts the resources by their ID and returns the first limit resources.
+// If limit is not positive, all resources are returned.
+func sortAndLimit[R any](resources []*R, limit int, idFn func(*R) int64) []*R {
+	slices.SortFunc(resources, func(a, b *R) int { return cmp.Compare(idFn(a), idFn(b)) })
+
+	if limit > 0 && len(resources) > limit {
+		return resources[:limit]
+	}
+	return resources
+}
+
 func (d *Datasource) queryMetrics(ctx context.Context, query backend.DataQuery) backend.DataResponse {
 	ctxLogger := logger.FromContext(ctx)
 	var resp backend.DataResponse
@@ -305,26 +1515,200 @@ func (d *Datasource) queryMetrics(ctx context.Context, query backend.DataQuery)
 		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("json unmarshal: %v", err.Error()))
 	}
 
-	resourceIDs, err := d.GetResourceIDs(ctx, qm)
+	if err := qm.validateMetrics(); err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("invalid query: %v", err))
+	}
+
+	if d.isEmptySelection(qm) {
+		return emptySelectionResponse()
+	}
+
+	resourceIDs, unresolved, err := d.getResourceIDs(ctx, qm)
 	if err != nil {
 		err = NicerErrorMessages(err)
 		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourceDownstream, fmt.Sprintf("failed to resolve resources: %v", err.Error()))
 	}
 
-	step := stepSize(query.TimeRange, query.Interval, query.MaxDataPoints)
+	if len(resourceIDs) == 0 {
+		var frame *data.Frame
+		switch qm.ResourceType {
+		case ResourceTypeServer:
+			frame = emptyMetricsFrame(serverMetricsTypeSeries, qm.RequestedMetricsTypes(), d.serverSeries.forQuery(qm))
+		case ResourceTypeLoadBalancer:
+			frame = emptyMetricsFrame(loadBalancerMetricsTypeSeries, qm.RequestedMetricsTypes(), d.loadBalancerSeries.forQuery(qm))
+		}
+		if len(qm.StatusSelector) > 0 {
+			frame.AppendNotices(noMatchingStatusNotice(qm.StatusSelector))
+		}
+		frame.AppendNotices(unresolvedResourceNotices(qm.ResourceType, unresolved)...)
+		return backend.DataResponse{Frames: data.Frames{frame}}
+	}
+
+	if (qm.SelectBy == SelectByID && len(qm.ResourceIDs) > 0) || qm.SelectBy == SelectByResource {
+		// Label and name selections already insert the names of all listed resources into the cache
+		d.warmNames(ctx, qm.ResourceType, resourceIDs)
+	}
+
+	step := qm.Step
+	if step <= 0 {
+		step = stepSize(query.TimeRange, query.Interval, query.MaxDataPoints)
+	}
+	requestedStep := step
+	step, downsampled := limitStep(query.TimeRange, step, d.options.maxPointsPerSeries())
+
+	timeRange := query.TimeRange
+	if qm.AlignStepToBoundary {
+		timeRange = alignTimeRange(timeRange, step)
+	}
+
+	legendFormat := qm.LegendFormat
+	if legendFormat == "" {
+		legendFormat = d.options.DefaultLegendFormat
+	}
+
+	if qm.Debug {
+		ctxLogger.Info("Debug query: resolved resources", "refID", query.RefID, "resourceIDs", resourceIDs, "step", step, "metricsTypes", qm.RequestedMetricsTypes())
+	}
+
+	// The network interfaces of the servers are the same for both periods
+	var interfaces map[int64]networkInterfaces
+	if qm.ResourceType == ResourceTypeServer && slices.ContainsFunc(qm.RequestedMetricsTypes(), isNetworkInterfaceMetricsType) {
+		interfaces, err = d.getNetworkInterfaces(ctx, resourceIDs)
+		if err != nil {
+			resp.Error = NicerErrorMessages(fmt.Errorf("error getting servers: %w", err))
+			resp.ErrorSource = backend.ErrorSourceDownstream
+			return resp
+		}
+	}
+
+	periods := []metricsPeriod{{timeRange: timeRange}}
+	if qm.CompareOffset != "" {
+		// Validated in validateMetrics
+		offset, _ := parseCompareOffset(qm.CompareOffset)
+		periods = append(periods, metricsPeriod{
+			timeRange: backend.TimeRange{From: timeRange.From.Add(-offset), To: timeRange.To.Add(-offset)},
+			offset:    offset,
+		})
+	}
+
+	// Both periods are requested at the same time, so the query runner can send their requests together. The previous
+	// period is shifted back as a whole, so the same trailing buckets are incomplete in both periods.
+	now := time.Now()
+	results := iter.Map(periods, func(period *metricsPeriod) metricsPeriodResult {
+		return d.requestMetricsPeriod(ctx, qm, resourceIDs, interfaces, period.timeRange, step, legendFormat, now.Add(-period.offset))
+	})
+	for _, result := range results {
+		if result.err != nil {
+			resp.Error = NicerErrorMessages(result.err)
+			resp.ErrorSource = backend.ErrorSourceDownstream
+			return resp
+		}
+	}
+
+	if qm.TopN > 0 {
+		// The top resources are ranked by the current period, the previous period shows the same resources
+		results[0].frames = topNFrames(results[0].frames, qm.TopN, qm.TopNBy)
+		for i := range results[1:] {
+			results[i+1].frames = sameResourceFrames(results[i+1].frames, results[0].frames)
+		}
+	}
+
+	var stats RequestStats
+	// notices are attached to the first frame after all frames are processed
+	notices := unresolvedResourceNotices(qm.ResourceType, unresolved)
+	hasValues := false
+	for i, result := range results {
+		frames, periodHasValues := finishMetricsPeriod(result.frames, qm, step, legendFormat)
+
+		if periods[i].offset > 0 {
+			shiftFrames(frames, periods[i].offset, qm.CompareOffset, legendFormat)
+		}
+		resp.Frames = append(resp.Frames, frames...)
+
+		stats.APICalls += result.stats.APICalls
+		stats.SharedAPICalls += result.stats.SharedAPICalls
+		hasValues = hasValues || periodHasValues
+		for _, notice := range result.notices {
+			// The previous period mostly repeats the notices of the current period
+			if !slices.ContainsFunc(notices, func(n data.Notice) bool { return n.Text == notice.Text }) {
+				notices = append(notices, notice)
+			}
+		}
+	}
+
+	if qm.Debug {
+		ctxLogger.Info("Debug query: received metrics", "refID", query.RefID, "apiCalls", stats.APICalls, "sharedAPICalls", stats.SharedAPICalls, "frames", len(resp.Frames))
+	}
+
+	if qm.Instant && !hasValues {
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     "No data: none of the selected series has a value in the time range.",
+		})
+	}
+
+	setFrameNames(resp.Frames, qm.FrameName)
+	setDecimals(resp.Frames, d.options.Decimals)
+
+	setMetricsFrameMeta(resp.Frames, MetricsFrameMeta{
+		Step:           step,
+		MaxDataPoints:  query.MaxDataPoints,
+		APICalls:       stats.APICalls,
+		SharedAPICalls: stats.SharedAPICalls,
+	})
+
+	if downsampled {
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text: fmt.Sprintf("The time range is too large for a step of %ds, the step was raised to %ds to return at most %d data points per series.",
+				requestedStep, step, d.options.maxPointsPerSeries()),
+		})
+	}
+
+	if len(notices) > 0 {
+		if len(resp.Frames) == 0 {
+			resp.Frames = append(resp.Frames, data.NewFrame(""))
+		}
+		resp.Frames[0].AppendNotices(notices...)
+	}
+
+	return resp
+}
+
+// metricsPeriod is a time range requested by a metrics query. The previous period of [QueryModel.CompareOffset] has
+// the offset to the current period.
+type metricsPeriod struct {
+	timeRange backend.TimeRange
+	offset    time.Duration
+}
+
+type metricsPeriodResult struct {
+	frames  []*data.Frame
+	notices []data.Notice
+	stats   RequestStats
+	err     error
+}
+
+// requestMetricsPeriod requests the metrics of the resources in the time range and returns the sorted frames of the
+// series. Buckets that end after now are incomplete, see [dropIncompleteBuckets]. Top N is applied by the caller,
+// which ranks all periods by the current one, see [finishMetricsPeriod] for the remaining steps.
+func (d *Datasource) requestMetricsPeriod(ctx context.Context, qm QueryModel, resourceIDs []int64, interfaces map[int64]networkInterfaces, timeRange backend.TimeRange, step int, legendFormat string, now time.Time) metricsPeriodResult {
+	ctxLogger := logger.FromContext(ctx)
+	var result metricsPeriodResult
+	// missingIDs are the selected resources that do not exist (anymore)
+	var missingIDs []int64
+	var err error
 
 	switch qm.ResourceType {
 	case ResourceTypeServer:
-		metrics, err := d.queryRunnerServer.RequestMetrics(ctx, resourceIDs, RequestOpts{
-			MetricsTypes: []MetricsType{qm.MetricsType},
-			TimeRange:    query.TimeRange,
+		var metrics map[int64]*hcloud.ServerMetrics
+		metrics, result.stats, err = d.queryRunnerServer.RequestMetrics(ctx, resourceIDs, RequestOpts{
+			MetricsTypes: qm.RequestedMetricsTypes(),
+			TimeRange:    timeRange,
 			Step:         step,
 		})
 		if err != nil {
-			resp.Error = NicerErrorMessages(err)
-			resp.ErrorSource = backend.ErrorSourceDownstream
-
-			return resp
+			return metricsPeriodResult{err: err}
 		}
 
 		for id, serverMetrics := range metrics {
@@ -334,18 +1718,28 @@ func (d *Datasource) queryMetrics(ctx context.Context, query backend.DataQuery)
 				name = ""
 			}
 
-			resp.Frames = append(resp.Frames, serverMetricsToFrames(id, name, qm.LegendFormat, serverMetrics)...)
+			if serverMetrics == nil {
+				result.frames = append(result.frames, missingResourceFrame(id, name, ResourceTypeServer, legendFormat))
+				missingIDs = append(missingIDs, id)
+				continue
+			}
+
+			if interfaces != nil {
+				serverMetrics = splitNetworkInterfaces(serverMetrics, interfaces[id], qm.RequestedMetricsTypes())
+			}
+			result.notices = append(result.notices, missingNetworkNotices(id, name, serverMetrics, qm.RequestedMetricsTypes())...)
+
+			result.frames = append(result.frames, serverMetricsToFrames(id, name, legendFormat, d.serverSeries.forQuery(qm), serverMetrics)...)
 		}
 	case ResourceTypeLoadBalancer:
-		metrics, err := d.queryRunnerLoadBalancer.RequestMetrics(ctx, resourceIDs, RequestOpts{
-			MetricsTypes: []MetricsType{qm.MetricsType},
-			TimeRange:    query.TimeRange,
+		var metrics map[int64]*hcloud.LoadBalancerMetrics
+		metrics, result.stats, err = d.queryRunnerLoadBalancer.RequestMetrics(ctx, resourceIDs, RequestOpts{
+			MetricsTypes: qm.RequestedMetricsTypes(),
+			TimeRange:    timeRange,
 			Step:         step,
 		})
 		if err != nil {
-			resp.Error = NicerErrorMessages(err)
-			resp.ErrorSource = backend.ErrorSourceDownstream
-			return resp
+			return metricsPeriodResult{err: err}
 		}
 
 		for id, lbMetrics := range metrics {
@@ -355,16 +1749,363 @@ func (d *Datasource) queryMetrics(ctx context.Context, query backend.DataQuery)
 				name = ""
 			}
 
-			resp.Frames = append(resp.Frames, loadBalancerMetricsToFrames(id, name, qm.LegendFormat, lbMetrics)...)
+			if lbMetrics == nil {
+				result.frames = append(result.frames, missingResourceFrame(id, name, ResourceTypeLoadBalancer, legendFormat))
+				missingIDs = append(missingIDs, id)
+				continue
+			}
+
+			result.frames = append(result.frames, loadBalancerMetricsToFrames(id, name, legendFormat, d.loadBalancerSeries.forQuery(qm), lbMetrics)...)
+		}
+	}
+
+	if len(missingIDs) > 0 && (qm.SelectBy == SelectByID || qm.SelectBy == SelectByResource) {
+		result.notices = append(result.notices, d.mismatchedResourceTypeNotice(ctx, qm, missingIDs))
+	}
+
+	dropIncompleteBuckets(result.frames, step, now, d.options.trailingBucketsToDrop())
+
+	d.addProjectLabel(result.frames, legendFormat)
+
+	// Keep colors in graph the same
+	sortFrames(result.frames)
+
+	return result
+}
+
+// finishMetricsPeriod aggregates and sorts the frames of a period after top N. For [QueryModel.Instant], it also
+// returns whether any series has a value, see [keepLastValues].
+func finishMetricsPeriod(frames []*data.Frame, qm QueryModel, step int, legendFormat string) ([]*data.Frame, bool) {
+	if qm.Aggregation.Enabled() {
+		frames = aggregateFrames(frames, qm.Aggregation, step, legendFormat)
+	}
+
+	sortSeries(frames, qm.SeriesSort)
+
+	hasValues := false
+	if qm.Instant {
+		hasValues = keepLastValues(frames)
+	}
+
+	return frames, hasValues
+}
+
+// mismatchedResourceTypeNotice explains that explicitly selected IDs did not match any resource of the query's resource
+// type, which often happens when IDs of servers and load balancers are mixed. With
+// [QueryModel.CheckOtherResourceType], the IDs are looked up as the other resource type to point the user at the right
+// one.
+func (d *Datasource) mismatchedResourceTypeNotice(ctx context.Context, qm QueryModel, missingIDs []int64) data.Notice {
+	slices.Sort(missingIDs)
+
+	text := fmt.Sprintf("The IDs %s did not match any %s. They were deleted, or they are the IDs of a different resource type.",
+		joinIDs(missingIDs), qm.ResourceType)
+
+	if qm.CheckOtherResourceType {
+		otherType, lookup := ResourceTypeLoadBalancer, d.nameCacheLoadBalancer.Get
+		if qm.ResourceType == ResourceTypeLoadBalancer {
+			otherType, lookup = ResourceTypeServer, d.nameCacheServer.Get
+		}
+
+		var otherIDs []int64
+		for _, id := range missingIDs {
+			if _, err := lookup(ctx, id); err == nil {
+				otherIDs = append(otherIDs, id)
+			}
+		}
+		if len(otherIDs) > 0 {
+			text += fmt.Sprintf(" The IDs %s are of type %s, query them with a separate query for that resource type.", joinIDs(otherIDs), otherType)
+		}
+	}
+
+	return data.Notice{Severity: data.NoticeSeverityWarning, Text: text}
+}
+
+func joinIDs(ids []int64) string {
+	formatted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		formatted = append(formatted, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// missingNetworkNotices returns an info notice for every requested network metrics type without any series in the
+// response. The API omits the network series of interfaces that do not exist, ie. the public interface of servers
+// that are only attached to private networks.
+func missingNetworkNotices(id int64, name string, metrics *hcloud.ServerMetrics, metricsTypes []MetricsType) []data.Notice {
+	server := name
+	if server == "" {
+		server = strconv.FormatInt(id, 10)
+	}
+
+	var notices []data.Notice
+	for _, metricsType := range metricsTypes {
+		var text string
+		switch metricsType {
+		case MetricsTypeServerNetworkBandwidth, MetricsTypeServerNetworkPPS, MetricsTypeServerPublicNetwork:
+			text = fmt.Sprintf("Server %s has no public network interface, no %s metrics available.", server, metricsType)
+		case MetricsTypeServerPrivateNetwork:
+			text = fmt.Sprintf("Server %s is not attached to a private network, no %s metrics available.", server, metricsType)
+		default:
+			continue
+		}
+
+		if !slices.ContainsFunc(serverMetricsTypeSeries[metricsType], func(series string) bool {
+			_, ok := metrics.TimeSeries[series]
+			return ok
+		}) {
+			notices = append(notices, data.Notice{Severity: data.NoticeSeverityInfo, Text: text})
+		}
+	}
+
+	return notices
+}
+
+// MetricsFrameMeta is attached as custom metadata to every metrics frame. It is visible in the query inspector and
+// helps to understand the resolution of the returned data.
+type MetricsFrameMeta struct {
+	Step           int   `json:"step"`
+	MaxDataPoints  int64 `json:"maxDataPoints"`
+	DataPoints     int   `json:"dataPoints"`
+	APICalls       int   `json:"apiCalls"`
+	SharedAPICalls int   `json:"sharedApiCalls"`
+}
+
+func setMetricsFrameMeta(frames []*data.Frame, meta MetricsFrameMeta) {
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+
+		frameMeta := meta
+		frameMeta.DataPoints = frame.Rows()
+		frame.Meta.Custom = frameMeta
+	}
+}
+
+// queryServerSpecs returns the provisioned resources of the selected servers as point-in-time values at the end of the
+// time range. This can be combined with the metrics to calculate absolute usage.
+func (d *Datasource) queryServerSpecs(ctx context.Context, query backend.DataQuery) backend.DataResponse {
+	var resp backend.DataResponse
+
+	var qm QueryModel
+	err := json.Unmarshal(query.JSON, &qm)
+	if err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("json unmarshal: %v", err.Error()))
+	}
+
+	if err := qm.validateServers(); err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("invalid query: %v", err))
+	}
+
+	if d.isEmptySelection(qm) {
+		return emptySelectionResponse()
+	}
+
+	servers, err := d.getSelectedServers(ctx, qm)
+	if err != nil {
+		err = NicerErrorMessages(err)
+		return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, fmt.Sprintf("error getting servers: %v", err.Error()))
+	}
+
+	timestamps := make([]time.Time, 0, len(servers))
+	ids := make([]int64, 0, len(servers))
+	names := make([]string, 0, len(servers))
+	serverTypes := make([]string, 0, len(servers))
+	cores := make([]int64, 0, len(servers))
+	memory := make([]float64, 0, len(servers))
+	disk := make([]int64, 0, len(servers))
+	storageTypes := make([]string, 0, len(servers))
+
+	for _, server := range servers {
+		timestamps = append(timestamps, query.TimeRange.To)
+		ids = append(ids, server.ID)
+		names = append(names, server.Name)
+		serverTypes = append(serverTypes, server.ServerType.Name)
+		cores = append(cores, int64(server.ServerType.Cores))
+		memory = append(memory, float64(server.ServerType.Memory))
+		// The provisioned size of the local disk, the API does not report how much of it is used
+		disk = append(disk, int64(server.ServerType.Disk))
+		storageTypes = append(storageTypes, string(server.ServerType.StorageType))
+	}
+
+	frame := data.NewFrame("server-specs")
+	frame.Fields = append(frame.Fields,
+		data.NewField("time", nil, timestamps),
+		data.NewField("id", nil, ids),
+		data.NewField("name", nil, names),
+		data.NewField("server_type", nil, serverTypes),
+		data.NewField("cores", nil, cores),
+		data.NewField("memory", nil, memory).SetConfig(&data.FieldConfig{Unit: "decgbytes"}),
+		data.NewField("disk", nil, disk).SetConfig(&data.FieldConfig{Unit: "decgbytes"}),
+		data.NewField("storage_type", nil, storageTypes),
+	)
+
+	resp.Frames = append(resp.Frames, frame)
+
+	return resp
+}
+
+// queryServerStatus returns a "running" gauge (1 if the server is running, 0 otherwise) for every selected server.
+//
+// The Hetzner Cloud API does not expose historical status information, so this is only the current status, returned
+// as a single data point at the end of the time range. It does not tell you whether the server was running during the
+// rest of the time range.
+func (d *Datasource) queryServerStatus(ctx context.Context, query backend.DataQuery) backend.DataResponse {
+	var resp backend.DataResponse
+
+	var qm QueryModel
+	err := json.Unmarshal(query.JSON, &qm)
+	if err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("json unmarshal: %v", err.Error()))
+	}
+
+	if err := qm.validateServers(); err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("invalid query: %v", err))
+	}
+
+	if d.isEmptySelection(qm) {
+		return emptySelectionResponse()
+	}
+
+	servers, err := d.getSelectedServers(ctx, qm)
+	if err != nil {
+		err = NicerErrorMessages(err)
+		return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, fmt.Sprintf("error getting servers: %v", err.Error()))
+	}
+
+	legendFormat := qm.LegendFormat
+	if legendFormat == "" {
+		legendFormat = d.options.DefaultLegendFormat
+	}
+
+	for _, server := range servers {
+		running := 0.0
+		if server.Status == hcloud.ServerStatusRunning {
+			running = 1
 		}
+
+		resp.Frames = append(resp.Frames, pointInTimeFrame(server.ID, server.Name, "running", "Running", "bool_on_off", legendFormat, query.TimeRange.To, &running))
+	}
+
+	d.addProjectLabel(resp.Frames, legendFormat)
+	setFrameNames(resp.Frames, qm.FrameName)
+
+	// Keep colors in graph the same
+	sortFrames(resp.Frames)
+
+	return resp
+}
+
+// serverTrafficSeries is a value that is calculated from the traffic counters of a server.
+type serverTrafficSeries struct {
+	name        string
+	displayName string
+	unit        string
+	// value returns nil for servers without included traffic
+	value func(server *hcloud.Server) *float64
+}
+
+var (
+	serverTrafficPercent = serverTrafficSeries{
+		name:        "outgoing_traffic_percent",
+		displayName: "Outgoing Traffic",
+		unit:        "percent",
+		value: func(server *hcloud.Server) *float64 {
+			if server.IncludedTraffic == 0 {
+				return nil
+			}
+			value := float64(server.OutgoingTraffic) / float64(server.IncludedTraffic) * 100
+			return &value
+		},
+	}
+
+	// serverTrafficRemaining is the traffic that is left before overage is billed. It is zero for servers that
+	// already used more than the included traffic.
+	serverTrafficRemaining = serverTrafficSeries{
+		name:        "included_traffic_remaining",
+		displayName: "Included Traffic Remaining",
+		unit:        "decbytes",
+		value: func(server *hcloud.Server) *float64 {
+			if server.IncludedTraffic == 0 {
+				return nil
+			}
+			value := math.Max(float64(server.IncludedTraffic)-float64(server.OutgoingTraffic), 0)
+			return &value
+		},
+	}
+)
+
+// queryServerTraffic returns a value based on the traffic counters of every selected server, ie. the outgoing traffic
+// in percent of the traffic included in its plan. Like [Datasource.queryServerStatus], this is a single data point at
+// the end of the time range. Servers without included traffic return null.
+//
+// The counters change constantly, so the servers are reused from the name cache for at most [ServerCacheMaxAge]. Servers
+// that are missing or outdated are fetched with a single (paginated) list request, see [Datasource.getSelectedServers].
+func (d *Datasource) queryServerTraffic(ctx context.Context, query backend.DataQuery, series serverTrafficSeries) backend.DataResponse {
+	var resp backend.DataResponse
+
+	var qm QueryModel
+	err := json.Unmarshal(query.JSON, &qm)
+	if err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("json unmarshal: %v", err.Error()))
+	}
+
+	if err := qm.validateServers(); err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("invalid query: %v", err))
+	}
+
+	if d.isEmptySelection(qm) {
+		return emptySelectionResponse()
+	}
+
+	servers, err := d.getSelectedServers(ctx, qm)
+	if err != nil {
+		err = NicerErrorMessages(err)
+		return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, fmt.Sprintf("error getting servers: %v", err.Error()))
+	}
+
+	legendFormat := qm.LegendFormat
+	if legendFormat == "" {
+		legendFormat = d.options.DefaultLegendFormat
+	}
+
+	for _, server := range servers {
+		resp.Frames = append(resp.Frames, pointInTimeFrame(server.ID, server.Name, series.name, series.displayName, series.unit, legendFormat, query.TimeRange.To, series.value(server)))
 	}
 
+	d.addProjectLabel(resp.Frames, legendFormat)
+	setFrameNames(resp.Frames, qm.FrameName)
+	setDecimals(resp.Frames, d.options.Decimals)
+
 	// Keep colors in graph the same
 	sortFrames(resp.Frames)
 
 	return resp
 }
 
+// pointInTimeFrame builds a frame with a single value, labeled like the frames of metrics queries.
+func pointInTimeFrame(id int64, name, seriesName, seriesDisplayName, unit, legendFormat string, timestamp time.Time, value *float64) *data.Frame {
+	labels := data.Labels{
+		LabelID:                strconv.FormatInt(id, 10),
+		LabelName:              name,
+		LabelSeriesName:        seriesName,
+		LabelSeriesDisplayName: seriesDisplayName,
+		LabelUnit:              unit,
+	}
+
+	valuesField := data.NewField(seriesName, labels, []*float64{value})
+	valuesField.Config = &data.FieldConfig{
+		Unit:              unit,
+		DisplayNameFromDS: getDisplayName(legendFormat, labels),
+	}
+
+	return data.NewFrame("",
+		data.NewField("time", nil, []time.Time{timestamp}),
+		// valuesField needs to be last, see [sortFrames].
+		valuesField,
+	)
+}
+
 func stepSize(timeRange backend.TimeRange, interval time.Duration, maxDataPoints int64) int {
 	step := int(math.Floor(interval.Seconds()))
 
@@ -381,15 +2122,129 @@ func stepSize(timeRange backend.TimeRange, interval time.Duration, maxDataPoints
 	return step
 }
 
-func serverMetricsToFrames(id int64, serverName string, legendFormat string, metrics *hcloud.ServerMetrics) []*data.Frame {
+// limitStep raises the step if the time range would return more than maxPoints data points per series. This protects
+// the API and Grafana from huge responses, ie. for a time range of one year with a step of one second. A maxPoints of
+// zero disables the limit.
+func limitStep(timeRange backend.TimeRange, step int, maxPoints int) (int, bool) {
+	if maxPoints <= 0 {
+		return step, false
+	}
+
+	seconds := int(math.Ceil(timeRange.Duration().Seconds()))
+	if step <= 0 || seconds/step <= maxPoints {
+		return step, false
+	}
+
+	return int(math.Ceil(float64(seconds) / float64(maxPoints))), true
+}
+
+// dropIncompleteBuckets removes up to maxBuckets data points from the end of every frame if their bucket (the timestamp
+// plus the step) ends after now. The values of these buckets only include the samples so far. Time ranges in the past
+// are not affected, so this only trades the freshest (partial) data point for graphs without a drop at the right edge.
+func dropIncompleteBuckets(frames []*data.Frame, step int, now time.Time, maxBuckets int) {
+	stepDuration := time.Duration(step) * time.Second
+
+	for _, frame := range frames {
+		if len(frame.Fields) < 2 {
+			continue
+		}
+		timeField := frame.Fields[0]
+
+		for dropped := 0; dropped < maxBuckets && timeField.Len() > 0; dropped++ {
+			last := timeField.Len() - 1
+			timestamp, ok := timeField.At(last).(time.Time)
+			if !ok || !timestamp.Add(stepDuration).After(now) {
+				break
+			}
+
+			for _, field := range frame.Fields {
+				field.Delete(last)
+			}
+		}
+	}
+}
+
+// keepLastValues reduces every frame to the row of the last non-null value, like an instant query in Prometheus. Frames
+// of series without any values are emptied. It returns false if no series has a value.
+func keepLastValues(frames []*data.Frame) bool {
+	anyValue := false
+
+	for _, frame := range frames {
+		if len(frame.Fields) < 2 {
+			continue
+		}
+
+		last := -1
+		valuesField := frame.Fields[len(frame.Fields)-1]
+		for i := valuesField.Len() - 1; i >= 0; i-- {
+			if value, ok := valuesField.At(i).(*float64); ok && value != nil {
+				last = i
+				break
+			}
+		}
+		if last >= 0 {
+			anyValue = true
+		}
+
+		for _, field := range frame.Fields {
+			// Delete from the end, so the indices of the remaining rows do not change
+			for i := field.Len() - 1; i >= 0; i-- {
+				if i != last {
+					field.Delete(i)
+				}
+			}
+		}
+	}
+
+	return anyValue
+}
+
+// alignTimeRange moves the start of the time range down and the end up to the next multiple of the step, counted
+// from the unix epoch. With a step of one hour, all data points are at the top of the hour (in UTC), and repeated
+// refreshes request the same buckets, so the response is the same and can be shared between queries.
+//
+// The first and last data point cover a full step, which may start before and end after the selected time range.
+func alignTimeRange(timeRange backend.TimeRange, step int) backend.TimeRange {
+	if step <= 1 {
+		return timeRange
+	}
+	stepDuration := time.Duration(step) * time.Second
+
+	// [time.Time.Truncate] counts from the zero time instead of the unix epoch, which is not the same grid for steps
+	// that do not divide a day (ie. one week)
+	alignDown := func(t time.Time) time.Time {
+		seconds := t.Unix()
+		offset := seconds % int64(step)
+		if offset < 0 {
+			offset += int64(step)
+		}
+		return time.Unix(seconds-offset, 0).In(t.Location())
+	}
+
+	from := alignDown(timeRange.From)
+	to := alignDown(timeRange.To)
+	if to.Before(timeRange.To) {
+		to = to.Add(stepDuration)
+	}
+
+	return backend.TimeRange{From: from, To: to}
+}
+
+func serverMetricsToFrames(id int64, serverName string, legendFormat string, seriesMeta seriesMetadata, metrics *hcloud.ServerMetrics) []*data.Frame {
 	frames := make([]*data.Frame, 0, len(metrics.TimeSeries))
 
-	// get all keys in map metrics.TimeSeries
-	for name, series := range metrics.TimeSeries {
+	// Iterate in the defined series order instead of the random map order, so the frames are stable
+	for _, name := range slices.SortedFunc(maps.Keys(metrics.TimeSeries), compareSeries) {
+		series := metrics.TimeSeries[name]
 		frame := data.NewFrame("")
 
 		timestamps := make([]time.Time, 0, len(series))
-		values := make([]float64, 0, len(series))
+		// Values are nullable, so unparseable values show up as gaps instead of misleading zeros
+		values := make([]*float64, 0, len(series))
+
+		// Parse failures are aggregated into a single notice per series to avoid flooding the panel
+		parseFailures := 0
+		var firstParseFailure string
 
 		for _, value := range series {
 			// convert float64 to time.Time
@@ -397,25 +2252,36 @@ func serverMetricsToFrames(id int64, serverName string, legendFormat string, met
 
 			parsedValue, err := strconv.ParseFloat(value.Value, 64)
 			if err != nil {
-				frame.AppendNotices(data.Notice{
-					Severity: data.NoticeSeverityWarning,
-					Text:     fmt.Sprintf("Failed to parse value %q at timestamp %f: %v", value.Value, value.Timestamp, err),
-				})
+				if parseFailures == 0 {
+					firstParseFailure = fmt.Sprintf("%q at timestamp %f: %v", value.Value, value.Timestamp, err)
+				}
+				parseFailures++
+				values = append(values, nil)
+				continue
 			}
-			values = append(values, parsedValue)
+			values = append(values, &parsedValue)
+		}
+
+		if parseFailures > 0 {
+			frame.AppendNotices(parseFailureNotice(name, parseFailures, len(series), firstParseFailure))
 		}
 
 		labels := data.Labels{
 			LabelID:                strconv.FormatInt(id, 10),
 			LabelName:              serverName,
 			LabelSeriesName:        name,
-			LabelSeriesDisplayName: serverSeriesToDisplayName[name],
+			LabelSeriesDisplayName: seriesMeta.displayNames[name],
+			LabelUnit:              seriesMeta.units[name],
+		}
+		if direction := seriesDirection(name); direction != "" {
+			labels[LabelDirection] = direction
 		}
 
 		valuesField := data.NewField(name, labels, values)
 		valuesField.Config = &data.FieldConfig{
-			Unit:              serverSeriesToUnit[name],
+			Unit:              seriesMeta.units[name],
 			DisplayNameFromDS: getDisplayName(legendFormat, labels),
+			Thresholds:        seriesMeta.thresholds[name],
 		}
 
 		frame.Fields = append(frame.Fields,
@@ -431,54 +2297,280 @@ func serverMetricsToFrames(id int64, serverName string, legendFormat string, met
 	return frames
 }
 
-func loadBalancerMetricsToFrames(id int64, loadBalancerMetrics string, legendFormat string, metrics *hcloud.LoadBalancerMetrics) []*data.Frame {
+func loadBalancerMetricsToFrames(id int64, loadBalancerName string, legendFormat string, seriesMeta seriesMetadata, metrics *hcloud.LoadBalancerMetrics) []*data.Frame {
 	frames := make([]*data.Frame, 0, len(metrics.TimeSeries))
 
-	// get all keys in map metrics.TimeSeries
-	for name, series := range metrics.TimeSeries {
-		frame := data.NewFrame("")
+	// Iterate in the defined series order instead of the random map order, so the frames are stable
+	for _, name := range slices.SortedFunc(maps.Keys(metrics.TimeSeries), compareSeries) {
+		series := metrics.TimeSeries[name]
+		frame := data.NewFrame("")
+
+		timestamps := make([]time.Time, 0, len(series))
+		// Values are nullable, so unparseable values show up as gaps instead of misleading zeros
+		values := make([]*float64, 0, len(series))
+
+		// Parse failures are aggregated into a single notice per series to avoid flooding the panel
+		parseFailures := 0
+		var firstParseFailure string
+
+		for _, value := range series {
+			// convert float64 to time.Time
+			timestamps = append(timestamps, time.Unix(int64(value.Timestamp), 0))
+
+			parsedValue, err := strconv.ParseFloat(value.Value, 64)
+			if err != nil {
+				if parseFailures == 0 {
+					firstParseFailure = fmt.Sprintf("%q at timestamp %f: %v", value.Value, value.Timestamp, err)
+				}
+				parseFailures++
+				values = append(values, nil)
+				continue
+			}
+			values = append(values, &parsedValue)
+		}
+
+		if parseFailures > 0 {
+			frame.AppendNotices(parseFailureNotice(name, parseFailures, len(series), firstParseFailure))
+		}
+
+		labels := data.Labels{
+			LabelID:                strconv.FormatInt(id, 10),
+			LabelName:              loadBalancerName,
+			LabelSeriesName:        name,
+			LabelSeriesDisplayName: seriesMeta.displayNames[name],
+			LabelUnit:              seriesMeta.units[name],
+		}
+		if direction := seriesDirection(name); direction != "" {
+			labels[LabelDirection] = direction
+		}
+
+		valuesField := data.NewField(name, labels, values)
+		valuesField.Config = &data.FieldConfig{
+			Unit:              seriesMeta.units[name],
+			DisplayNameFromDS: getDisplayName(legendFormat, labels),
+			Thresholds:        seriesMeta.thresholds[name],
+		}
+
+		frame.Fields = append(frame.Fields,
+			data.NewField("time", nil, timestamps),
+			// valuesField needs to be last, if this is changed,
+			// you also need to modify [sortFrames] for the new ordering.
+			valuesField,
+		)
+
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
+
+// missingResourceFrame returns an empty frame with an info notice for a resource that does not exist (anymore). The
+// Hetzner Cloud API deletes the metrics together with the resource, so nothing can be shown for it, but the remaining
+// resources of the query should still be rendered.
+func missingResourceFrame(id int64, name string, resourceType ResourceType, legendFormat string) *data.Frame {
+	labels := data.Labels{
+		LabelID:   strconv.FormatInt(id, 10),
+		LabelName: name,
+	}
+
+	valuesField := data.NewField("value", labels, []*float64{})
+	valuesField.Config = &data.FieldConfig{
+		DisplayNameFromDS: getDisplayName(legendFormat, labels),
+	}
+
+	frame := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{}),
+		// valuesField needs to be last, see [sortFrames]
+		valuesField,
+	)
+	frame.AppendNotices(data.Notice{
+		Severity: data.NoticeSeverityInfo,
+		Text:     fmt.Sprintf("The %s with ID %d was not found, it might have been deleted. Metrics are only available for existing resources.", resourceType, id),
+	})
+
+	return frame
+}
+
+// setFrameNames names every frame after the labels of its values field, so the frames of multiple queries can be
+// told apart in the query inspector. frameName is rendered like the legend format, the default is the resource name
+// followed by the series name (see [wideFieldName]).
+func setFrameNames(frames []*data.Frame, frameName string) {
+	for _, frame := range frames {
+		if len(frame.Fields) < 2 {
+			continue
+		}
+
+		labels := frame.Fields[len(frame.Fields)-1].Labels
+		if frameName == "" {
+			frame.Name = wideFieldName(labels)
+		} else {
+			frame.Name = renderTemplate(frameName, labels)
+		}
+	}
+}
+
+// setDecimals sets the decimals of all value fields of all frames. It does nothing if decimals is nil, so Grafana
+// picks the decimals based on the values.
+func setDecimals(frames []*data.Frame, decimals *uint16) {
+	if decimals == nil {
+		return
+	}
+
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			if field.Type().Time() {
+				continue
+			}
+
+			if field.Config == nil {
+				field.Config = &data.FieldConfig{}
+			}
+			field.Config.Decimals = decimals
+		}
+	}
+}
+
+// emptyMetricsFrame is returned if a query does not match any resources. It has an empty field with the unit of every
+// requested series, so panels keep their field config until resources match again.
+func emptyMetricsFrame(typeSeries map[MetricsType][]string, metricsTypes []MetricsType, seriesMeta seriesMetadata) *data.Frame {
+	frame := data.NewFrame("", data.NewField("time", nil, []time.Time{}))
+
+	for _, metricsType := range metricsTypes {
+		for _, name := range typeSeries[metricsType] {
+			labels := data.Labels{
+				LabelSeriesName:        name,
+				LabelSeriesDisplayName: seriesMeta.displayNames[name],
+				LabelUnit:              seriesMeta.units[name],
+			}
+
+			field := data.NewField(name, labels, []*float64{})
+			field.Config = &data.FieldConfig{
+				Unit:       seriesMeta.units[name],
+				Thresholds: seriesMeta.thresholds[name],
+			}
+			frame.Fields = append(frame.Fields, field)
+		}
+	}
+
+	frame.AppendNotices(data.Notice{
+		Severity: data.NoticeSeverityInfo,
+		Text:     "The query did not match any resources.",
+	})
+
+	return frame
+}
+
+// seriesMetadata holds the display names, units and thresholds of series, keyed by the series name.
+type seriesMetadata struct {
+	displayNames map[string]string
+	units        map[string]string
+	thresholds   map[string]*data.ThresholdsConfig
+}
+
+// newSeriesMetadata merges the overrides over the built-in display names and units. The built-in maps are not
+// modified.
+func newSeriesMetadata(displayNames, units map[string]string, overrides map[string]SeriesOverride) seriesMetadata {
+	meta := seriesMetadata{
+		displayNames: maps.Clone(displayNames),
+		units:        maps.Clone(units),
+	}
+
+	for seriesName, override := range overrides {
+		// Overrides are shared between resource types, only apply the ones for known series
+		if _, ok := units[seriesName]; !ok {
+			continue
+		}
+		if override.DisplayName != "" {
+			meta.displayNames[seriesName] = override.DisplayName
+		}
+		if override.Unit != "" {
+			meta.units[seriesName] = override.Unit
+		}
+	}
+
+	return meta
+}
+
+// forQuery returns the metadata with the unit options of the query applied.
+func (m seriesMetadata) forQuery(qm QueryModel) seriesMetadata {
+	if qm.RawValues {
+		m.units = map[string]string{}
+		return m
+	}
+	return m.withUnits(qm.UnitOverrides)
+}
+
+// withUnits returns a copy of the metadata with the units replaced by the overrides of a query. Overrides for unknown
+// series are ignored, like the series overrides in [newSeriesMetadata].
+func (m seriesMetadata) withUnits(overrides map[string]string) seriesMetadata {
+	if len(overrides) == 0 {
+		return m
+	}
+
+	m.units = maps.Clone(m.units)
+	for seriesName, unit := range overrides {
+		if _, ok := m.units[seriesName]; !ok || unit == "" {
+			continue
+		}
+		m.units[seriesName] = unit
+	}
+
+	return m
+}
 
-		timestamps := make([]time.Time, 0, len(series))
-		values := make([]float64, 0, len(series))
+// withThresholds sets the thresholds of all series of the metrics types in typeSeries. Thresholds of other
+// resource types are ignored.
+func (m seriesMetadata) withThresholds(typeSeries map[MetricsType][]string, thresholds map[MetricsType][]ThresholdStep) seriesMetadata {
+	m.thresholds = make(map[string]*data.ThresholdsConfig)
 
-		for _, value := range series {
-			// convert float64 to time.Time
-			timestamps = append(timestamps, time.Unix(int64(value.Timestamp), 0))
+	for metricsType, steps := range thresholds {
+		if len(steps) == 0 {
+			continue
+		}
 
-			parsedValue, err := strconv.ParseFloat(value.Value, 64)
-			if err != nil {
-				frame.AppendNotices(data.Notice{
-					Severity: data.NoticeSeverityWarning,
-					Text:     fmt.Sprintf("Failed to parse value %q at timestamp %f: %v", value.Value, value.Timestamp, err),
-				})
+		config := &data.ThresholdsConfig{
+			Mode:  data.ThresholdsModeAbsolute,
+			Steps: make([]data.Threshold, 0, len(steps)),
+		}
+		for _, step := range steps {
+			// Grafana uses negative infinity as the value of the base step
+			value := math.Inf(-1)
+			if step.Value != nil {
+				value = *step.Value
 			}
-			values = append(values, parsedValue)
+			config.Steps = append(config.Steps, data.NewThreshold(value, step.Color, ""))
 		}
 
-		labels := data.Labels{
-			LabelID:                strconv.FormatInt(id, 10),
-			LabelName:              loadBalancerMetrics,
-			LabelSeriesName:        name,
-			LabelSeriesDisplayName: loadBalancerSeriesToDisplayName[name],
+		for _, seriesName := range typeSeries[metricsType] {
+			m.thresholds[seriesName] = config
 		}
+	}
 
-		valuesField := data.NewField(name, labels, values)
-		valuesField.Config = &data.FieldConfig{
-			Unit:              loadBalancerSeriesToUnit[name],
-			DisplayNameFromDS: getDisplayName(legendFormat, labels),
-		}
+	return m
+}
 
-		frame.Fields = append(frame.Fields,
-			data.NewField("time", nil, timestamps),
-			// valuesField needs to be last, if this is changed,
-			// you also need to modify [sortFrames] for the new ordering.
-			valuesField,
-		)
+// seriesDirection returns the direction of a series (in, out, read, write) based on the suffix of its name.
+// It returns an empty string for series without a direction, like "cpu".
+func seriesDirection(seriesName string) string {
+	idx := strings.LastIndex(seriesName, ".")
+	if idx == -1 {
+		return ""
+	}
 
-		frames = append(frames, frame)
+	switch suffix := seriesName[idx+1:]; suffix {
+	case "in", "out", "read", "write":
+		return suffix
+	default:
+		return ""
 	}
+}
 
-	return frames
+// parseFailureNotice builds the warning shown to the user when some values of a series could not be parsed.
+func parseFailureNotice(seriesName string, failed, total int, firstFailure string) data.Notice {
+	return data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("Failed to parse %d of %d values for series %s, first invalid value was %s", failed, total, seriesName, firstFailure),
+	}
 }
 
 // getDisplayName was inspired by github.com/grafana/grafana/pkg/tsdb/prometheus/querydata.getName()
@@ -487,7 +2579,22 @@ func getDisplayName(legendFormat string, labels data.Labels) string {
 		legendFormat = AutoLegendFormat
 	}
 
-	return legendFormatRegexp.ReplaceAllStringFunc(legendFormat, func(in string) string {
+	return renderTemplate(legendFormat, labels)
+}
+
+// getVar returns the value of the `var` field of resource list queries.
+func getVar(varFormat string, id int64, name string) string {
+	if varFormat == "" {
+		varFormat = DefaultVarFormat
+	}
+
+	return renderTemplate(varFormat, data.Labels{LabelID: strconv.FormatInt(id, 10), LabelName: name})
+}
+
+// renderTemplate replaces all labels in `{{ }}` brackets with their values. Unknown labels are replaced with an
+// empty string.
+func renderTemplate(format string, labels data.Labels) string {
+	return legendFormatRegexp.ReplaceAllStringFunc(format, func(in string) string {
 		labelName := strings.Replace(in, "{{", "", 1)
 		labelName = strings.Replace(labelName, "}}", "", 1)
 		labelName = strings.TrimSpace(labelName)
@@ -500,124 +2607,614 @@ func getDisplayName(legendFormat string, labels data.Labels) string {
 
 // sortFrames sorts frames by their [LabelID] and [LabelSeriesName]. This helps with the coloring in the
 // Time Series panel, as they depend on the order of the results.
+//
+// IDs are compared numerically, so a server with ID 9 comes before one with ID 10. The sort is stable, frames with an
+// unknown ordering keep their relative position.
 func sortFrames(frames []*data.Frame) {
 
-	slices.SortFunc(frames, func(a, b *data.Frame) int {
+	slices.SortStableFunc(frames, func(a, b *data.Frame) int {
 		idA, okA := a.Fields[len(a.Fields)-1].Labels[LabelID]
 		idB, okB := b.Fields[len(b.Fields)-1].Labels[LabelID]
 
-		switch {
-		case !okA || !okB:
+		if !okA || !okB {
 			// Unknown ordering
 			return 0
-		case idA > idB:
-			return 1
-		case idA < idB:
-			return -1
+		}
+		if c := compareIDs(idA, idB); c != 0 {
+			return c
 		}
 		// If IDs are equal, we compare by series name
 
 		seriesA, okA := a.Fields[len(a.Fields)-1].Labels[LabelSeriesName]
 		seriesB, okB := b.Fields[len(b.Fields)-1].Labels[LabelSeriesName]
 
-		switch {
-		case !okA || !okB:
+		if !okA || !okB {
 			// Unknown ordering
 			return 0
-		case seriesA > seriesB:
-			return 1
-		case seriesA < seriesB:
-			return -1
-		default:
-			return 0
 		}
+		return compareSeries(seriesA, seriesB)
 	})
 }
 
+// seriesOrder holds the position of every known series, see [compareSeries].
+var seriesOrder = func() map[string]seriesPosition {
+	order := make(map[string]seriesPosition)
+	for _, typeSeries := range []map[MetricsType][]string{serverMetricsTypeSeries, loadBalancerMetricsTypeSeries} {
+		for _, series := range typeSeries {
+			for i, name := range series {
+				order[name] = seriesPosition{group: series[0], index: i}
+			}
+		}
+	}
+	return order
+}()
+
+type seriesPosition struct {
+	// group is the first series of the metrics type
+	group string
+	index int
+}
+
+// compareSeries orders series of the same metrics type by their position in [serverMetricsTypeSeries] and
+// [loadBalancerMetricsTypeSeries], so ie. in/out and read/write are always in the same order. Different metrics types
+// and unknown series are ordered by name.
+func compareSeries(a, b string) int {
+	posA, ok := seriesOrder[a]
+	if !ok {
+		posA = seriesPosition{group: a}
+	}
+	posB, ok := seriesOrder[b]
+	if !ok {
+		posB = seriesPosition{group: b}
+	}
+
+	return cmp.Or(
+		cmp.Compare(posA.group, posB.group),
+		cmp.Compare(posA.index, posB.index),
+	)
+}
+
+// warmNames inserts the names of explicitly selected resources into the name cache with as few requests as possible,
+// before the frames look them up one by one. Errors are only logged, the names are then looked up individually.
+func (d *Datasource) warmNames(ctx context.Context, resourceType ResourceType, ids []int64) {
+	var err error
+	switch resourceType {
+	case ResourceTypeServer:
+		err = d.nameCacheServer.Warm(ctx, ids, d.listServers)
+	case ResourceTypeLoadBalancer:
+		err = d.nameCacheLoadBalancer.Warm(ctx, ids, func(ctx context.Context, opts hcloud.ListOpts) ([]*hcloud.LoadBalancer, *hcloud.Response, error) {
+			return d.loadBalancers.List(ctx, hcloud.LoadBalancerListOpts{ListOpts: opts})
+		})
+	}
+	if err != nil {
+		logger.FromContext(ctx).Warn("failed to warm name cache", "resourceType", resourceType, "error", err)
+	}
+}
+
+// warmNameCaches loads all servers and load balancers and inserts them into the name caches.
+func (d *Datasource) warmNameCaches(ctx context.Context) {
+	ctxLogger := logger.FromContext(ctx)
+
+	servers, err := d.servers.All(ctx)
+	if err != nil {
+		ctxLogger.Warn("failed to preload server names", "error", err)
+	} else {
+		d.nameCacheServer.Insert(servers...)
+		ctxLogger.Info("Preloaded server names", "entries", len(servers))
+	}
+
+	loadBalancers, err := d.loadBalancers.All(ctx)
+	if err != nil {
+		ctxLogger.Warn("failed to preload load balancer names", "error", err)
+	} else {
+		d.nameCacheLoadBalancer.Insert(loadBalancers...)
+		ctxLogger.Info("Preloaded load balancer names", "entries", len(loadBalancers))
+	}
+}
+
 // CheckHealth handles health checks sent from Grafana to the plugin.
 // The main use case for these health checks is the test button on the
 // datasource configuration page which allows users to verify that
 // a datasource is working as expected.
 func (d *Datasource) CheckHealth(ctx context.Context, _ *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	now := time.Now()
+	if result := d.healthCache.get(now); result != nil {
+		return result, nil
+	}
+
+	result, err := d.checkHealth(ctx)
+	if err == nil && result.Status == backend.HealthStatusOk {
+		// Errors are never cached, so fixing the settings or token is visible right away
+		d.healthCache.set(result, now.Add(d.options.healthCheckCacheDuration()))
+	}
+
+	return result, err
+}
+
+// healthCache holds the last successful health check result until it expires.
+type healthCache struct {
+	mutex     sync.Mutex
+	result    *backend.CheckHealthResult
+	expiresAt time.Time
+}
+
+// get returns the cached result, or nil if there is none or it is expired.
+func (c *healthCache) get(now time.Time) *backend.CheckHealthResult {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.result == nil || !now.Before(c.expiresAt) {
+		return nil
+	}
+	return c.result
+}
+
+func (c *healthCache) set(result *backend.CheckHealthResult, expiresAt time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.result = result
+	c.expiresAt = expiresAt
+}
+
+// checkHealth checks the settings and the access to the API without using the [healthCache].
+func (d *Datasource) checkHealth(ctx context.Context) (*backend.CheckHealthResult, error) {
+	if err := d.options.Validate(); err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: fmt.Sprintf("Invalid data source settings: %v", err),
+		}, nil
+	}
+
 	_, _, err := d.client.Location.List(ctx, hcloud.LocationListOpts{ListOpts: hcloud.ListOpts{PerPage: 1}})
 	if err != nil {
 		if hcloud.IsError(err, hcloud.ErrorCodeUnauthorized) {
 			return &backend.CheckHealthResult{
 				Status:  backend.HealthStatusError,
-				Message: InvalidAPITokenErrorMessage,
+				Message: d.healthMessage(InvalidAPITokenErrorMessage),
 			}, nil
 		}
 
-		return nil, err
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: d.healthMessage(fmt.Sprintf("Failed to connect to Hetzner Cloud API: %v", err)),
+		}, nil
+	}
+
+	message := d.healthMessage("Successfully connected to Hetzner Cloud API")
+
+	// Listing resources works, but metrics might still be inaccessible. This is only reported as a caveat, as
+	// resource list queries still work without access to metrics.
+	if err := d.checkMetricsAccess(ctx); err != nil {
+		logger.FromContext(ctx).Warn("metrics health check failed", "error", err)
+		message += fmt.Sprintf(", but metrics can not be read: %v", err)
 	}
+	message += ". " + TokenScopeUnknownMessage + "."
 
 	return &backend.CheckHealthResult{
 		Status:  backend.HealthStatusOk,
-		Message: "Successfully connected to Hetzner Cloud API",
+		Message: message,
 	}, nil
 }
 
+// healthMessage prefixes the message with the project of the data source. Every data source has a single API token,
+// so multiple projects are connected through multiple data sources. When many of them are provisioned, the prefix tells
+// which project (and token) a failed health check belongs to. Without a project name, the data source is named instead.
+func (d *Datasource) healthMessage(message string) string {
+	switch {
+	case d.options.ProjectName != "":
+		return fmt.Sprintf("Project %s: %s", d.options.ProjectName, message)
+	case d.name != "":
+		return fmt.Sprintf("Data source %s: %s", d.name, message)
+	default:
+		return message
+	}
+}
+
+// checkMetricsAccess requests a minimal time range of metrics for a single server (or load balancer if there are no
+// servers) to verify that the token can read metrics. If the project has no resources, the check is skipped.
+func (d *Datasource) checkMetricsAccess(ctx context.Context) error {
+	end := time.Now()
+	start := end.Add(-time.Minute)
+
+	servers, _, err := d.servers.List(ctx, hcloud.ServerListOpts{ListOpts: hcloud.ListOpts{PerPage: 1}})
+	if err != nil {
+		return err
+	}
+	if len(servers) > 0 {
+		_, _, err = d.servers.GetMetrics(ctx, servers[0], hcloud.ServerGetMetricsOpts{
+			Types: []hcloud.ServerMetricType{hcloud.ServerMetricCPU},
+			Start: start,
+			End:   end,
+		})
+		return err
+	}
+
+	loadBalancers, _, err := d.loadBalancers.List(ctx, hcloud.LoadBalancerListOpts{ListOpts: hcloud.ListOpts{PerPage: 1}})
+	if err != nil {
+		return err
+	}
+	if len(loadBalancers) > 0 {
+		_, _, err = d.loadBalancers.GetMetrics(ctx, loadBalancers[0], hcloud.LoadBalancerGetMetricsOpts{
+			Types: []hcloud.LoadBalancerMetricType{hcloud.LoadBalancerMetricOpenConnections},
+			Start: start,
+			End:   end,
+		})
+		return err
+	}
+
+	return nil
+}
+
 // CallResource handles additional API calls. These are used to fill the resource dropdowns in the query editor.
 func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
 	ctxLogger := logger.FromContext(ctx).With("path", req.Path, "method", req.Method)
 
-	var returnData any
-	var err error
+	handlers, ok := d.resourceRoutes()[req.Path]
+	if !ok {
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusNotFound,
+		})
+	}
 
-	if req.Method != http.MethodGet {
+	handler, ok := handlers[req.Method]
+	if !ok {
 		ctxLogger.Warn("unsupported method")
 		return sender.Send(&backend.CallResourceResponse{
 			Status: http.StatusMethodNotAllowed,
 		})
 	}
 
-	switch req.Path {
-	case "servers":
-		returnData, err = d.getServers(ctx)
-	case "load-balancers":
-		returnData, err = d.getLoadBalancers(ctx)
-	default:
+	returnData, err := handler(ctx, req)
+	if err != nil {
+		var badRequest badRequestError
+		if errors.As(err, &badRequest) {
+			ctxLogger.Warn("invalid resource call", "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusBadRequest,
+				Body:   []byte(err.Error()),
+			})
+		}
+
+		if hcloud.IsError(err, hcloud.ErrorCodeUnauthorized) {
+			ctxLogger.Warn(InvalidAPITokenErrorMessage, "error", err)
+			return sender.Send(&backend.CallResourceResponse{
+				Status: http.StatusUnauthorized,
+			})
+		}
+
+		ctxLogger.Warn("failed to respond to resource call", "error", err)
 		return sender.Send(&backend.CallResourceResponse{
-			Status: http.StatusNotFound,
+			Status: http.StatusInternalServerError,
+		})
+	}
+
+	body, err := json.Marshal(returnData)
+	if err != nil {
+		ctxLogger.Warn("failed to encode json body in resource call", "error", err)
+		return sender.Send(&backend.CallResourceResponse{
+			Status: http.StatusInternalServerError,
+		})
+	}
+
+	return sender.Send(&backend.CallResourceResponse{
+		Status: http.StatusOK,
+		Body:   body,
+	})
+}
+
+type resourceHandler func(ctx context.Context, req *backend.CallResourceRequest) (any, error)
+
+// resourceRoutes returns the handlers of all paths that are handled by [Datasource.CallResource], keyed by path and
+// method.
+func (d *Datasource) resourceRoutes() map[string]map[string]resourceHandler {
+	return map[string]map[string]resourceHandler{
+		"servers": {http.MethodGet: func(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+			query, err := resourceQuery(req)
+			if err != nil {
+				return nil, err
+			}
+			return d.getServers(ctx, query.Get("withLabels") == "true", query.Get("selector"))
+		}},
+		"servers/search": {http.MethodGet: func(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+			query, err := resourceQuery(req)
+			if err != nil {
+				return nil, err
+			}
+			return d.searchServers(ctx, query.Get("q"))
+		}},
+		"servers/status-counts": {http.MethodGet: func(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+			return d.getServerStatusCounts(ctx)
+		}},
+		"load-balancers": {http.MethodGet: func(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+			query, err := resourceQuery(req)
+			if err != nil {
+				return nil, err
+			}
+			return d.getLoadBalancers(ctx, query.Get("withLabels") == "true")
+		}},
+		"placement-groups": {http.MethodGet: func(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+			query, err := resourceQuery(req)
+			if err != nil {
+				return nil, err
+			}
+			return d.getPlacementGroups(ctx, query.Get("withLabels") == "true")
+		}},
+		"cache/refresh": {http.MethodPost: d.refreshNameCaches},
+		"cache/stats": {http.MethodGet: func(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+			return CacheStats{
+				Servers:       d.nameCacheServer.Stats(),
+				LoadBalancers: d.nameCacheLoadBalancer.Stats(),
+			}, nil
+		}},
+		"cache/config": {
+			http.MethodGet: d.getNameCacheConfig,
+			http.MethodPut: d.setNameCacheConfig,
+		},
+		"query-runner/stats": {http.MethodGet: func(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+			return QueryRunnerStatsResponse{
+				Servers:       d.queryRunnerServer.Stats(),
+				LoadBalancers: d.queryRunnerLoadBalancer.Stats(),
+			}, nil
+		}},
+		"resolve":       {http.MethodGet: d.resolveLabelSelector},
+		"metric-types":  {http.MethodGet: d.getMetricsTypes},
+		"network-zones": {http.MethodGet: d.getNetworkZones},
+	}
+}
+
+// badRequestError is returned by resource handlers for invalid parameters. It is sent to the client with
+// [http.StatusBadRequest] instead of [http.StatusInternalServerError].
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string { return e.err.Error() }
+func (e badRequestError) Unwrap() error { return e.err }
+
+type MetricsTypeInfo struct {
+	Value  MetricsType  `json:"value"`
+	Label  string       `json:"label"`
+	Series []SeriesInfo `json:"series"`
+}
+
+type SeriesInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Unit        string `json:"unit"`
+}
+
+// getMetricsTypes returns all metrics types of the resource type in the query parameter `resourceType`, including
+// the display names and units of their series. Series overrides from the options are applied.
+func (d *Datasource) getMetricsTypes(_ context.Context, req *backend.CallResourceRequest) (any, error) {
+	query, err := resourceQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var typeSeries map[MetricsType][]string
+	var seriesMeta seriesMetadata
+	switch resourceType := ResourceType(query.Get("resourceType")); resourceType {
+	case ResourceTypeServer:
+		typeSeries, seriesMeta = serverMetricsTypeSeries, d.serverSeries
+	case ResourceTypeLoadBalancer:
+		typeSeries, seriesMeta = loadBalancerMetricsTypeSeries, d.loadBalancerSeries
+	default:
+		return nil, badRequestError{fmt.Errorf("unknown resource type: %q", resourceType)}
+	}
+
+	metricsTypes := make([]MetricsTypeInfo, 0, len(typeSeries))
+	for _, metricsType := range slices.Sorted(maps.Keys(typeSeries)) {
+		info := MetricsTypeInfo{
+			Value:  metricsType,
+			Label:  metricsTypeLabels[metricsType],
+			Series: make([]SeriesInfo, 0, len(typeSeries[metricsType])),
+		}
+		for _, name := range typeSeries[metricsType] {
+			info.Series = append(info.Series, SeriesInfo{
+				Name:        name,
+				DisplayName: seriesMeta.displayNames[name],
+				Unit:        seriesMeta.units[name],
+			})
+		}
+		metricsTypes = append(metricsTypes, info)
+	}
+
+	return metricsTypes, nil
+}
+
+// resolveLabelSelector returns all resources of the query parameter `type` that match the label selector in the query
+// parameter `selector`. This uses the same code path as metrics queries, so the result can be used to preview which
+// resources a query would select. Unlike queries, the preview is not limited by [Options.MaxResources].
+func (d *Datasource) resolveLabelSelector(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+	query, err := resourceQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	qm := QueryModel{
+		ResourceType:   ResourceType(query.Get("type")),
+		SelectBy:       SelectByLabel,
+		LabelSelectors: []string{query.Get("selector")},
+	}
+
+	var nameCache interface {
+		Get(ctx context.Context, id int64) (string, error)
+	}
+	switch qm.ResourceType {
+	case ResourceTypeServer:
+		nameCache = d.nameCacheServer
+	case ResourceTypeLoadBalancer:
+		nameCache = d.nameCacheLoadBalancer
+	default:
+		return nil, badRequestError{fmt.Errorf("unknown resource type: %q", qm.ResourceType)}
+	}
+	if err := validateLabelSelector(query.Get("selector")); err != nil {
+		return nil, badRequestError{err}
+	}
+
+	resourceIDs, _, err := d.selectResourceIDs(ctx, qm)
+	if err != nil {
+		return nil, err
+	}
+
+	selectableValues := make([]SelectableValue, 0, len(resourceIDs))
+	for _, id := range resourceIDs {
+		// All resources were just inserted into the cache by selectResourceIDs
+		name, err := nameCache.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		selectableValues = append(selectableValues, SelectableValue{
+			Value: id,
+			Label: name,
 		})
 	}
 
-	if err != nil {
-		if hcloud.IsError(err, hcloud.ErrorCodeUnauthorized) {
-			ctxLogger.Warn(InvalidAPITokenErrorMessage, "error", err)
-			return sender.Send(&backend.CallResourceResponse{
-				Status: http.StatusUnauthorized,
-			})
+	return selectableValues, nil
+}
+
+type CacheStats struct {
+	Servers       NameCacheStats `json:"servers"`
+	LoadBalancers NameCacheStats `json:"loadBalancers"`
+}
+
+type QueryRunnerStatsResponse struct {
+	Servers       QueryRunnerStats `json:"servers"`
+	LoadBalancers QueryRunnerStats `json:"loadBalancers"`
+}
+
+type CacheRefreshResult struct {
+	ClearedServers       int  `json:"clearedServers"`
+	ClearedLoadBalancers int  `json:"clearedLoadBalancers"`
+	Warmed               bool `json:"warmed"`
+}
+
+// refreshNameCaches clears both name caches. If the query parameter `warm=true` is set,
+// the caches are filled again before responding.
+func (d *Datasource) refreshNameCaches(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+	result := CacheRefreshResult{
+		ClearedServers:       d.nameCacheServer.Clear(),
+		ClearedLoadBalancers: d.nameCacheLoadBalancer.Clear(),
+	}
+
+	query, err := resourceQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Get("warm") == "true" {
+		d.warmNameCaches(ctx)
+		result.Warmed = true
+	}
+
+	return result, nil
+}
+
+// NameCacheConfig is the configuration of both name caches. Only TTLSeconds can be changed at runtime.
+type NameCacheConfig struct {
+	TTLSeconds int `json:"ttlSeconds"`
+	Size       int `json:"size"`
+}
+
+// getNameCacheConfig returns the current configuration of the name caches. Both caches share the same configuration.
+func (d *Datasource) getNameCacheConfig(_ context.Context, _ *backend.CallResourceRequest) (any, error) {
+	ttl, size := d.nameCacheServer.Config()
+	return NameCacheConfig{TTLSeconds: int(ttl / time.Second), Size: size}, nil
+}
+
+// setNameCacheConfig changes the TTL of both name caches to the `ttlSeconds` of the JSON body. The change is not
+// persisted, it is lost when Grafana is restarted or the data source settings are saved, which reapplies
+// [Options.NameCacheTTLSeconds].
+func (d *Datasource) setNameCacheConfig(ctx context.Context, req *backend.CallResourceRequest) (any, error) {
+	var config struct {
+		TTLSeconds *int `json:"ttlSeconds"`
+	}
+	if err := json.Unmarshal(req.Body, &config); err != nil {
+		return nil, badRequestError{fmt.Errorf("invalid body: %w", err)}
+	}
+	if config.TTLSeconds == nil {
+		return nil, badRequestError{errors.New("ttlSeconds is required")}
+	}
+	if *config.TTLSeconds < 0 {
+		return nil, badRequestError{fmt.Errorf("ttlSeconds must not be negative, got %d", *config.TTLSeconds)}
+	}
+
+	ttl := time.Duration(*config.TTLSeconds) * time.Second
+	d.nameCacheServer.SetTTL(ttl)
+	d.nameCacheLoadBalancer.SetTTL(ttl)
+	logger.FromContext(ctx).Info("Changed name cache TTL", "ttl", ttl)
+
+	return d.getNameCacheConfig(ctx, req)
+}
+
+type NetworkZoneInfo struct {
+	Value     string   `json:"value"`
+	Label     string   `json:"label"`
+	Locations []string `json:"locations"`
+}
+
+// getNetworkZones returns all network zones with their locations, sorted by name. The zones are derived from the
+// locations, so zones without any resources in the project are included, too.
+func (d *Datasource) getNetworkZones(ctx context.Context, _ *backend.CallResourceRequest) (any, error) {
+	locations, err := d.client.Location.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return networkZoneInfos(locations), nil
+}
+
+func networkZoneInfos(locations []*hcloud.Location) []NetworkZoneInfo {
+	locationsByZone := make(map[string][]string)
+	for _, location := range locations {
+		if location.NetworkZone == "" {
+			continue
 		}
+		zone := string(location.NetworkZone)
+		locationsByZone[zone] = append(locationsByZone[zone], location.Name)
+	}
 
-		ctxLogger.Warn("failed to respond to resource call", "error", err)
-		return sender.Send(&backend.CallResourceResponse{
-			Status: http.StatusInternalServerError,
-		})
+	zones := make([]NetworkZoneInfo, 0, len(locationsByZone))
+	for _, zone := range slices.Sorted(maps.Keys(locationsByZone)) {
+		zoneLocations := locationsByZone[zone]
+		slices.Sort(zoneLocations)
+		zones = append(zones, NetworkZoneInfo{Value: zone, Label: zone, Locations: zoneLocations})
 	}
+	return zones
+}
 
-	body, err := json.Marshal(returnData)
+// resourceQuery returns the parsed query parameters of the resource request.
+func resourceQuery(req *backend.CallResourceRequest) (url.Values, error) {
+	reqURL, err := url.Parse(req.URL)
 	if err != nil {
-		ctxLogger.Warn("failed to encode json body in resource call", "error", err)
-		return sender.Send(&backend.CallResourceResponse{
-			Status: http.StatusInternalServerError,
-		})
+		return nil, fmt.Errorf("parse request url: %w", err)
 	}
-
-	return sender.Send(&backend.CallResourceResponse{
-		Status: http.StatusOK,
-		Body:   body,
-	})
+	return reqURL.Query(), nil
 }
 
 type SelectableValue struct {
 	Value int64  `json:"value"`
 	Label string `json:"label"`
+
+	// Labels are only included if requested with `withLabels=true`, to keep the default response small.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
-func (d *Datasource) getServers(ctx context.Context) ([]SelectableValue, error) {
-	servers, err := d.client.Server.All(ctx)
+// getServers returns all servers matching the label selector, or all servers if it is empty.
+func (d *Datasource) getServers(ctx context.Context, withLabels bool, labelSelector string) ([]SelectableValue, error) {
+	if err := validateLabelSelector(labelSelector); err != nil {
+		return nil, badRequestError{err}
+	}
+
+	servers, err := d.servers.AllWithOpts(ctx, hcloud.ServerListOpts{ListOpts: hcloud.ListOpts{
+		LabelSelector: labelSelector,
+		PerPage:       ResourceListPerPage,
+	}})
 	if err != nil {
+		if labelSelector != "" && hcloud.IsError(err, hcloud.ErrorCodeInvalidInput) {
+			return nil, badRequestError{fmt.Errorf("invalid label selector %q: %w", labelSelector, err)}
+		}
 		return nil, err
 	}
 
@@ -625,17 +3222,65 @@ func (d *Datasource) getServers(ctx context.Context) ([]SelectableValue, error)
 
 	selectableValues := make([]SelectableValue, 0, len(servers))
 	for _, server := range servers {
-		selectableValues = append(selectableValues, SelectableValue{
+		value := SelectableValue{
 			Value: server.ID,
 			Label: server.Name,
-		})
+		}
+		if withLabels {
+			value.Labels = server.Labels
+		}
+		selectableValues = append(selectableValues, value)
 	}
 
 	return selectableValues, nil
 }
 
-func (d *Datasource) getLoadBalancers(ctx context.Context) ([]SelectableValue, error) {
-	loadBalancers, err := d.client.LoadBalancer.All(ctx)
+// searchServers returns all servers whose name contains search, ignoring the case. The API only supports filtering by
+// the exact name, so all servers are loaded and filtered here.
+func (d *Datasource) searchServers(ctx context.Context, search string) ([]SelectableValue, error) {
+	if search == "" {
+		return nil, badRequestError{errors.New("query parameter q is required")}
+	}
+
+	servers, err := d.getServers(ctx, false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return filterByLabel(servers, search), nil
+}
+
+// filterByLabel returns all values whose label contains search, ignoring the case.
+func filterByLabel(values []SelectableValue, search string) []SelectableValue {
+	search = strings.ToLower(search)
+
+	return slices.DeleteFunc(values, func(value SelectableValue) bool {
+		return !strings.Contains(strings.ToLower(value.Label), search)
+	})
+}
+
+// getServerStatusCounts returns the number of servers per status, ie. for a single stat of all running servers.
+func (d *Datasource) getServerStatusCounts(ctx context.Context) (map[hcloud.ServerStatus]int, error) {
+	servers, err := d.servers.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.nameCacheServer.Insert(servers...)
+
+	return countByStatus(servers), nil
+}
+
+func countByStatus(servers []*hcloud.Server) map[hcloud.ServerStatus]int {
+	counts := make(map[hcloud.ServerStatus]int)
+	for _, server := range servers {
+		counts[server.Status]++
+	}
+	return counts
+}
+
+func (d *Datasource) getLoadBalancers(ctx context.Context, withLabels bool) ([]SelectableValue, error) {
+	loadBalancers, err := d.loadBalancers.All(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -644,22 +3289,49 @@ func (d *Datasource) getLoadBalancers(ctx context.Context) ([]SelectableValue, e
 
 	selectableValues := make([]SelectableValue, 0, len(loadBalancers))
 	for _, loadBalancer := range loadBalancers {
-		selectableValues = append(selectableValues, SelectableValue{
+		value := SelectableValue{
 			Value: loadBalancer.ID,
 			Label: loadBalancer.Name,
-		})
+		}
+		if withLabels {
+			value.Labels = loadBalancer.Labels
+		}
+		selectableValues = append(selectableValues, value)
+	}
+
+	return selectableValues, nil
+}
+
+func (d *Datasource) getPlacementGroups(ctx context.Context, withLabels bool) ([]SelectableValue, error) {
+	placementGroups, err := d.client.PlacementGroup.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	selectableValues := make([]SelectableValue, 0, len(placementGroups))
+	for _, placementGroup := range placementGroups {
+		value := SelectableValue{
+			Value: placementGroup.ID,
+			Label: placementGroup.Name,
+		}
+		if withLabels {
+			value.Labels = placementGroup.Labels
+		}
+		selectableValues = append(selectableValues, value)
 	}
 
 	return selectableValues, nil
 }
 
 func (d *Datasource) serverAPIRequestFn(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+	logger.FromContext(ctx).Debug("Requesting server metrics", "id", id, "metricsTypes", opts.MetricsTypes, "step", opts.Step)
+
 	hcloudGoMetricsTypes := make([]hcloud.ServerMetricType, 0, len(opts.MetricsTypes))
 	for _, metricsType := range opts.MetricsTypes {
 		hcloudGoMetricsTypes = append(hcloudGoMetricsTypes, metricTypeToServerMetricType[metricsType])
 	}
 
-	metrics, _, err := d.client.Server.GetMetrics(ctx, &hcloud.Server{ID: id}, hcloud.ServerGetMetricsOpts{
+	metrics, _, err := d.servers.GetMetrics(ctx, &hcloud.Server{ID: id}, hcloud.ServerGetMetricsOpts{
 		Types: hcloudGoMetricsTypes,
 		Start: opts.TimeRange.From,
 		End:   opts.TimeRange.To,
@@ -670,12 +3342,14 @@ func (d *Datasource) serverAPIRequestFn(ctx context.Context, id int64, opts Requ
 }
 
 func (d *Datasource) loadBalancerAPIRequestFn(ctx context.Context, id int64, opts RequestOpts) (*hcloud.LoadBalancerMetrics, error) {
+	logger.FromContext(ctx).Debug("Requesting load balancer metrics", "id", id, "metricsTypes", opts.MetricsTypes, "step", opts.Step)
+
 	hcloudGoMetricsTypes := make([]hcloud.LoadBalancerMetricType, 0, len(opts.MetricsTypes))
 	for _, metricsType := range opts.MetricsTypes {
 		hcloudGoMetricsTypes = append(hcloudGoMetricsTypes, metricTypeToLoadBalancerMetricType[metricsType])
 	}
 
-	metrics, _, err := d.client.LoadBalancer.GetMetrics(ctx, &hcloud.LoadBalancer{ID: id}, hcloud.LoadBalancerGetMetricsOpts{
+	metrics, _, err := d.loadBalancers.GetMetrics(ctx, &hcloud.LoadBalancer{ID: id}, hcloud.LoadBalancerGetMetricsOpts{
 		Types: hcloudGoMetricsTypes,
 		Start: opts.TimeRange.From,
 		End:   opts.TimeRange.To,
@@ -685,29 +3359,367 @@ func (d *Datasource) loadBalancerAPIRequestFn(ctx context.Context, id int64, opt
 	return metrics, err
 }
 
+// listServers requests a single page of servers, see [ListResourcesFn].
+func (d *Datasource) listServers(ctx context.Context, opts hcloud.ListOpts) ([]*hcloud.Server, *hcloud.Response, error) {
+	return d.servers.List(ctx, hcloud.ServerListOpts{ListOpts: opts})
+}
+
 func (d *Datasource) getServerFn(ctx context.Context, id int64) (*hcloud.Server, error) {
-	srv, _, err := d.client.Server.GetByID(ctx, id)
+	srv, _, err := d.servers.GetByID(ctx, id)
 	return srv, err
 }
 
 func (d *Datasource) getLoadBalancerFn(ctx context.Context, id int64) (*hcloud.LoadBalancer, error) {
-	lb, _, err := d.client.LoadBalancer.GetByID(ctx, id)
+	lb, _, err := d.loadBalancers.GetByID(ctx, id)
 	return lb, err
 }
 
+// getSelectedServers returns all servers selected by the query, see [Datasource.GetResourceIDs]. Unlike metrics queries,
+// the number of servers is not limited by [Options.MaxResources]. The servers are taken from the name cache if they
+// were fetched less than [ServerCacheMaxAge] ago (ie. while resolving a label selector), so only servers that are
+// missing or outdated are requested from the API.
+func (d *Datasource) getSelectedServers(ctx context.Context, qm QueryModel) ([]*hcloud.Server, error) {
+	resourceIDs, _, err := d.selectResourceIDs(ctx, qm)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.nameCacheServer.Resources(ctx, resourceIDs, ServerCacheMaxAge, d.listServers)
+}
+
+// excludedResourceIDs returns the resources that match any of the selectors, see [QueryModel.ExcludeLabelSelectors].
+// Every selector is resolved with its own API request.
+func (d *Datasource) excludedResourceIDs(ctx context.Context, resourceType ResourceType, selectors []string) (set.Set[int64], error) {
+	excluded := set.New[int64]()
+
+	for _, selector := range selectors {
+		ids, err := d.resolveResourceIDs(ctx, QueryModel{
+			ResourceType:   resourceType,
+			SelectBy:       SelectByLabel,
+			LabelSelectors: []string{selector},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("exclude selector %q: %w", selector, err)
+		}
+		excluded = excluded.Union(set.From(ids...))
+	}
+
+	return excluded, nil
+}
+
+// getNetworkInterfaces returns the network interfaces of the servers. Like [Datasource.getSelectedServers], the servers
+// are taken from the name cache if they are not older than [ServerCacheMaxAge].
+func (d *Datasource) getNetworkInterfaces(ctx context.Context, ids []int64) (map[int64]networkInterfaces, error) {
+	servers, err := d.nameCacheServer.Resources(ctx, ids, ServerCacheMaxAge, d.listServers)
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces := make(map[int64]networkInterfaces, len(servers))
+	for _, server := range servers {
+		interfaces[server.ID] = serverNetworkInterfaces(server)
+	}
+	return interfaces, nil
+}
+
+// GetResourceIDs returns the IDs of all resources selected by the query. Every resource results in one API request
+// for metrics, so queries that select more than [Options.MaxResources] resources fail instead of exhausting the rate
+// limit.
 func (d *Datasource) GetResourceIDs(ctx context.Context, qm QueryModel) ([]int64, error) {
+	resourceIDs, _, err := d.getResourceIDs(ctx, qm)
+	return resourceIDs, err
+}
+
+// getResourceIDs is [Datasource.GetResourceIDs], but it also returns the entries of [QueryModel.Resources] that did
+// not match any resource, so the caller can inform the user about them.
+func (d *Datasource) getResourceIDs(ctx context.Context, qm QueryModel) ([]int64, []string, error) {
+	resourceIDs, unresolved, err := d.selectResourceIDs(ctx, qm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if maxResources := d.options.maxResources(); len(resourceIDs) > maxResources {
+		return nil, nil, fmt.Errorf("the query selects %d resources, but at most %d are allowed: narrow down the selection (ie. with a more specific label selector) or raise Max Resources in the data source settings",
+			len(resourceIDs), maxResources)
+	}
+
+	return resourceIDs, unresolved, nil
+}
+
+// selectResourceIDs is [Datasource.getResourceIDs] without the limit of [Options.MaxResources].
+func (d *Datasource) selectResourceIDs(ctx context.Context, qm QueryModel) ([]int64, []string, error) {
+	qm, err := d.resolveSavedSelector(qm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var unresolved []string
+	if qm.SelectBy == SelectByResource && !qm.hasEmptySelection() {
+		qm, unresolved, err = d.resolveResources(ctx, qm)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(qm.ResourceIDs) == 0 {
+			return []int64{}, unresolved, nil
+		}
+	}
+
+	resourceIDs, err := d.resolveResourceIDs(ctx, qm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selected := set.From(resourceIDs...)
+
+	if len(qm.StatusSelector) > 0 && qm.SelectBy == SelectByID && len(qm.ResourceIDs) > 0 {
+		// Other selections are already filtered by status in resolveResourceIDs
+		matching, err := d.serverIDsByStatus(ctx, qm.StatusSelector)
+		if err != nil {
+			return nil, nil, err
+		}
+		selected = selected.Intersection(matching)
+	}
+
+	if len(qm.ExcludeLabelSelectors) > 0 && len(selected) > 0 {
+		excluded, err := d.excludedResourceIDs(ctx, qm.ResourceType, qm.ExcludeLabelSelectors)
+		if err != nil {
+			return nil, nil, err
+		}
+		selected = selected.Difference(excluded)
+	}
+
+	// The sets do not keep the order of the resources
+	resourceIDs = slices.DeleteFunc(resourceIDs, func(id int64) bool { return !selected.Has(id) })
+
+	if qm.NameFilter != "" {
+		resourceIDs, err = d.filterByName(ctx, qm.ResourceType, resourceIDs, qm.NameFilter)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return resourceIDs, unresolved, nil
+}
+
+// resolveResources replaces the [QueryModel.Resources] of the query with the IDs of the resources, and returns the
+// entries that did not match any resource. Numeric entries are used as IDs without a lookup, so the names of the
+// resources are only listed (with a single paginated request) if the query contains any names.
+func (d *Datasource) resolveResources(ctx context.Context, qm QueryModel) (QueryModel, []string, error) {
+	tokens := resourceTokens(qm.Resources)
+
+	hasNames := slices.ContainsFunc(tokens, func(token string) bool {
+		_, ok := parseResourceID(token)
+		return !ok
+	})
+
+	var ids []int64
+	var unresolved []string
+	switch {
+	case !hasNames:
+		ids, unresolved = resolveResourceTokens[hcloud.Server](nil, tokens, serverIdentifier)
+	case qm.ResourceType == ResourceTypeServer:
+		servers, err := d.servers.All(ctx)
+		if err != nil {
+			return qm, nil, fmt.Errorf("server lookup by name: %w", err)
+		}
+		d.nameCacheServer.Insert(servers...)
+		ids, unresolved = resolveResourceTokens(servers, tokens, serverIdentifier)
+	case qm.ResourceType == ResourceTypeLoadBalancer:
+		loadBalancers, err := d.loadBalancers.All(ctx)
+		if err != nil {
+			return qm, nil, fmt.Errorf("load balancer lookup by name: %w", err)
+		}
+		d.nameCacheLoadBalancer.Insert(loadBalancers...)
+		ids, unresolved = resolveResourceTokens(loadBalancers, tokens, loadBalancerIdentifier)
+	default:
+		return qm, nil, fmt.Errorf("unknown resource type: %q", qm.ResourceType)
+	}
+
+	qm.SelectBy = SelectByID
+	qm.ResourceIDs = ids
+	qm.Resources = nil
+	return qm, unresolved, nil
+}
+
+// resourceTokens splits the entries of [QueryModel.Resources] at commas and whitespace, so a pasted list like
+// "web-1, 12345, db-prod" can be used as a single entry.
+func resourceTokens(resources []string) []string {
+	var tokens []string
+	for _, resource := range resources {
+		tokens = append(tokens, strings.FieldsFunc(resource, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})...)
+	}
+	return tokens
+}
+
+// parseResourceID returns the ID if the token is a positive number.
+func parseResourceID(token string) (int64, bool) {
+	id, err := strconv.ParseInt(token, 10, 64)
+	return id, err == nil && id > 0
+}
+
+// resolveResourceTokens returns the IDs of the tokens in their order, without duplicates. Numeric tokens are used as
+// IDs, even if they are not in resources, so deleted resources are reported like other missing IDs. All other tokens
+// are matched against the names of the resources, the tokens without a match are returned as unresolved.
+func resolveResourceTokens[R HCloudResource](resources []*R, tokens []string, identifierFn IdentifierFn[R]) (ids []int64, unresolved []string) {
+	byName := nameIndex(resources, identifierFn)
+
+	seen := set.New[int64]()
+	for _, token := range tokens {
+		id, ok := parseResourceID(token)
+		if !ok {
+			id, ok = byName[token]
+		}
+		if !ok {
+			if !slices.Contains(unresolved, token) {
+				unresolved = append(unresolved, token)
+			}
+			continue
+		}
+		if !seen.Has(id) {
+			seen.Insert(id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, unresolved
+}
+
+// unresolvedResourceNotices returns a warning for every entry of [QueryModel.Resources] that did not match any
+// resource.
+func unresolvedResourceNotices(resourceType ResourceType, unresolved []string) []data.Notice {
+	notices := make([]data.Notice, 0, len(unresolved))
+	for _, token := range unresolved {
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("No %s with the name %q was found.", resourceType, token),
+		})
+	}
+	return notices
+}
+
+// serverIDsByStatus returns the servers that have one of the statuses. The servers with these statuses are listed with a
+// single (paginated) API request, explicitly selected servers are not fetched one by one.
+func (d *Datasource) serverIDsByStatus(ctx context.Context, statuses []hcloud.ServerStatus) (set.Set[int64], error) {
+	servers, err := d.servers.AllWithOpts(ctx, hcloud.ServerListOpts{Status: statuses})
+	if err != nil {
+		return nil, fmt.Errorf("server lookup by status: %w", err)
+	}
+
+	d.nameCacheServer.Insert(servers...)
+
+	matching := set.New[int64]()
+	for _, server := range servers {
+		matching.Insert(server.ID)
+	}
+
+	return matching, nil
+}
+
+// serverStatuses are the valid values of [QueryModel.StatusSelector].
+var serverStatuses = []hcloud.ServerStatus{
+	hcloud.ServerStatusInitializing,
+	hcloud.ServerStatusStarting,
+	hcloud.ServerStatusRunning,
+	hcloud.ServerStatusStopping,
+	hcloud.ServerStatusOff,
+	hcloud.ServerStatusDeleting,
+	hcloud.ServerStatusMigrating,
+	hcloud.ServerStatusRebuilding,
+	hcloud.ServerStatusUnknown,
+}
+
+// hasStatus returns true if the server has one of the statuses, or if statuses is empty.
+func hasStatus(server *hcloud.Server, statuses []hcloud.ServerStatus) bool {
+	return len(statuses) == 0 || slices.Contains(statuses, server.Status)
+}
+
+// noMatchingStatusNotice informs the user that the selection is empty because of the [QueryModel.StatusSelector].
+func noMatchingStatusNotice(statuses []hcloud.ServerStatus) data.Notice {
+	names := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		names = append(names, string(status))
+	}
+
+	return data.Notice{
+		Severity: data.NoticeSeverityInfo,
+		Text:     fmt.Sprintf("No selected servers have the status %s.", strings.Join(names, " or ")),
+	}
+}
+
+// filterByName returns the resources whose name matches the regular expression in nameFilter. The names are looked up
+// in the name cache, resources that are not cached yet are fetched from the API (see [Datasource.warmNames]).
+// Resources that do not exist anymore are removed. Other errors fail the query, as the resources could not be checked.
+func (d *Datasource) filterByName(ctx context.Context, resourceType ResourceType, resourceIDs []int64, nameFilter string) ([]int64, error) {
+	nameRegexp, err := regexp.Compile(nameFilter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nameFilter: %w", err)
+	}
+
+	var nameCache interface {
+		Get(ctx context.Context, id int64) (string, error)
+	}
+	switch resourceType {
+	case ResourceTypeServer:
+		nameCache = d.nameCacheServer
+	case ResourceTypeLoadBalancer:
+		nameCache = d.nameCacheLoadBalancer
+	default:
+		return nil, fmt.Errorf("unknown resource type: %q", resourceType)
+	}
+
+	d.warmNames(ctx, resourceType, resourceIDs)
+
+	filtered := make([]int64, 0, len(resourceIDs))
+	for _, id := range resourceIDs {
+		name, err := nameCache.Get(ctx, id)
+		if hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting the name of resource %d for the name filter: %w", id, err)
+		}
+		if nameRegexp.MatchString(name) {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, nil
+}
+
+// resolveSavedSelector adds the label selector referenced by [QueryModel.SavedSelector] to the label selectors of the
+// query. It returns an error if the data source has no saved selector with that name.
+func (d *Datasource) resolveSavedSelector(qm QueryModel) (QueryModel, error) {
+	if qm.SavedSelector == "" {
+		return qm, nil
+	}
+
+	selector, ok := d.options.SavedSelectors[qm.SavedSelector]
+	if !ok {
+		return qm, fmt.Errorf("unknown saved selector %q, valid names are: %s", qm.SavedSelector, strings.Join(slices.Sorted(maps.Keys(d.options.SavedSelectors)), ", "))
+	}
+
+	qm.LabelSelectors = append(slices.Clone(qm.LabelSelectors), selector)
+	return qm, nil
+}
+
+func (d *Datasource) resolveResourceIDs(ctx context.Context, qm QueryModel) ([]int64, error) {
+	if d.isEmptySelection(qm) {
+		return []int64{}, nil
+	}
+
 	// If we have an explicit list of IDs use those
 	if qm.SelectBy == SelectByID && len(qm.ResourceIDs) > 0 {
-		return qm.ResourceIDs, nil
+		// The selection is filtered in place later on, which must not modify the query
+		return slices.Clone(qm.ResourceIDs), nil
 	}
 
-	// If we have a label selector or an empty list of IDs we need to resolve the resources
+	// If we have a label selector, names or an empty list of IDs we need to resolve the resources
 	listOpts := hcloud.ListOpts{}
 
 	switch qm.SelectBy {
 	case SelectByLabel:
 		listOpts.LabelSelector = strings.Join(qm.LabelSelectors, ", ")
-	case SelectByID:
+	case SelectByID, SelectByResourceName, SelectByResource:
 	// Setting no label selector will return all resources
 	default:
 		return nil, fmt.Errorf("unknown select by value: %q", qm.SelectBy)
@@ -715,27 +3727,39 @@ func (d *Datasource) GetResourceIDs(ctx context.Context, qm QueryModel) ([]int64
 
 	switch qm.ResourceType {
 	case ResourceTypeServer:
-		servers, err := d.client.Server.AllWithOpts(ctx, hcloud.ServerListOpts{
-			ListOpts: hcloud.ListOpts{
-				LabelSelector: strings.Join(qm.LabelSelectors, ", "),
-			},
-		})
+		opts := hcloud.ServerListOpts{ListOpts: listOpts}
+		if qm.SelectBy != SelectByResourceName {
+			opts.Status = qm.StatusSelector
+		}
+		servers, err := d.servers.AllWithOpts(ctx, opts)
 		if err != nil {
 			return nil, fmt.Errorf("server lookup by label: %w", err)
 		}
 
 		d.nameCacheServer.Insert(servers...)
 
+		if qm.SelectBy == SelectByResourceName {
+			// All servers are listed, so names of servers with another status are not reported as unknown
+			ids, err := idsByName(servers, qm.ResourceNames, serverIdentifier)
+			if err != nil {
+				return nil, err
+			}
+			servers = slices.DeleteFunc(servers, func(server *hcloud.Server) bool { return !hasStatus(server, qm.StatusSelector) })
+			matching := set.New[int64]()
+			for _, server := range servers {
+				matching.Insert(server.ID)
+			}
+			return slices.DeleteFunc(ids, func(id int64) bool { return !matching.Has(id) }), nil
+		}
+
 		var resourceIDs []int64
 		for _, server := range servers {
 			resourceIDs = append(resourceIDs, server.ID)
 		}
 		return resourceIDs, nil
 	case ResourceTypeLoadBalancer:
-		loadBalancers, err := d.client.LoadBalancer.AllWithOpts(ctx, hcloud.LoadBalancerListOpts{
-			ListOpts: hcloud.ListOpts{
-				LabelSelector: strings.Join(qm.LabelSelectors, ", "),
-			},
+		loadBalancers, err := d.loadBalancers.AllWithOpts(ctx, hcloud.LoadBalancerListOpts{
+			ListOpts: listOpts,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("load balancer lookup by label: %w", err)
@@ -743,6 +3767,10 @@ func (d *Datasource) GetResourceIDs(ctx context.Context, qm QueryModel) ([]int64
 
 		d.nameCacheLoadBalancer.Insert(loadBalancers...)
 
+		if qm.SelectBy == SelectByResourceName {
+			return idsByName(loadBalancers, qm.ResourceNames, loadBalancerIdentifier)
+		}
+
 		var resourceIDs []int64
 		for _, loadBalancer := range loadBalancers {
 			resourceIDs = append(resourceIDs, loadBalancer.ID)
@@ -753,6 +3781,82 @@ func (d *Datasource) GetResourceIDs(ctx context.Context, qm QueryModel) ([]int64
 	}
 }
 
+// hasEmptySelection returns true if the query does not select any resources through its [SelectBy] method.
+func (qm QueryModel) hasEmptySelection() bool {
+	switch qm.SelectBy {
+	case SelectByLabel:
+		return qm.SavedSelector == "" && !slices.ContainsFunc(qm.LabelSelectors, func(selector string) bool { return strings.TrimSpace(selector) != "" })
+	case SelectByID:
+		return len(qm.ResourceIDs) == 0
+	case SelectByResourceName:
+		return len(qm.ResourceNames) == 0
+	case SelectByResource:
+		return len(resourceTokens(qm.Resources)) == 0
+	default:
+		return false
+	}
+}
+
+// isEmptySelection returns true if the query selects no resources and should therefore return no resources,
+// see [Options.EmptySelectionMeansAll].
+func (d *Datasource) isEmptySelection(qm QueryModel) bool {
+	return !d.options.EmptySelectionMeansAll && qm.hasEmptySelection()
+}
+
+// emptySelectionResponse explains why a query with an empty selection returns no data.
+func emptySelectionResponse() backend.DataResponse {
+	frame := data.NewFrame("")
+	frame.AppendNotices(data.Notice{
+		Severity: data.NoticeSeverityInfo,
+		Text:     "No resources selected. Select resources by label, ID or name, or enable \"Empty Selection Means All\" in the data source settings to query all resources.",
+	})
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+func serverIdentifier(server *hcloud.Server) (int64, string) { return server.ID, server.Name }
+
+func loadBalancerIdentifier(loadBalancer *hcloud.LoadBalancer) (int64, string) {
+	return loadBalancer.ID, loadBalancer.Name
+}
+
+// nameIndex maps the names of the resources to their IDs.
+func nameIndex[R HCloudResource](resources []*R, identifierFn IdentifierFn[R]) map[string]int64 {
+	byName := make(map[string]int64, len(resources))
+	for _, resource := range resources {
+		id, name := identifierFn(resource)
+		byName[name] = id
+	}
+	return byName
+}
+
+// idsByName returns the IDs of the resources with the given names, in the order of the names.
+// It returns an error listing all names that did not match any resource.
+func idsByName[R HCloudResource](resources []*R, names []string, identifierFn IdentifierFn[R]) ([]int64, error) {
+	byName := nameIndex(resources, identifierFn)
+
+	resourceIDs := make([]int64, 0, len(names))
+	var unknownNames []string
+	for _, name := range names {
+		id, ok := byName[name]
+		if !ok {
+			unknownNames = append(unknownNames, name)
+			continue
+		}
+		resourceIDs = append(resourceIDs, id)
+	}
+
+	if len(unknownNames) > 0 {
+		return nil, fmt.Errorf("no resources found with names: %s", strings.Join(unknownNames, ", "))
+	}
+
+	return resourceIDs, nil
+}
+
+// All series returned by the Hetzner Cloud API are gauges: cpu is a percentage, and the disk and network series are
+// already rates per second (bytes/s, operations/s, packets/s), averaged over the step. The same is true for the load
+// balancer series. There are no cumulative counters, so no per-second derivative is calculated for any series.
+// The disk series only describe the load of the disk, not its capacity, so there is no disk usage series.
 var (
 	serverMetricsTypeSeries = map[MetricsType][]string{
 		MetricsTypeServerCPU:              {"cpu"},
@@ -760,6 +3864,8 @@ var (
 		MetricsTypeServerDiskIOPS:         {"disk.0.iops.read", "disk.0.iops.write"},
 		MetricsTypeServerNetworkBandwidth: {"network.0.bandwidth.in", "network.0.bandwidth.out"},
 		MetricsTypeServerNetworkPPS:       {"network.0.pps.in", "network.0.pps.out"},
+		MetricsTypeServerPublicNetwork:    {"public_network.bandwidth.in", "public_network.bandwidth.out"},
+		MetricsTypeServerPrivateNetwork:   {"private_network.bandwidth.in", "private_network.bandwidth.out"},
 	}
 
 	serverSeriesToDisplayName = map[string]string{
@@ -777,6 +3883,11 @@ var (
 		"network.0.pps.out":       "Sent",
 		"network.0.bandwidth.in":  "Received",
 		"network.0.bandwidth.out": "Sent",
+
+		"public_network.bandwidth.in":   "Received (Public)",
+		"public_network.bandwidth.out":  "Sent (Public)",
+		"private_network.bandwidth.in":  "Received (Private)",
+		"private_network.bandwidth.out": "Sent (Private)",
 	}
 
 	serverSeriesToUnit = map[string]string{
@@ -794,6 +3905,26 @@ var (
 		"network.0.pps.out":       "pps",
 		"network.0.bandwidth.in":  "binBps",
 		"network.0.bandwidth.out": "binBps",
+
+		"public_network.bandwidth.in":   "binBps",
+		"public_network.bandwidth.out":  "binBps",
+		"private_network.bandwidth.in":  "binBps",
+		"private_network.bandwidth.out": "binBps",
+	}
+
+	metricsTypeLabels = map[MetricsType]string{
+		MetricsTypeServerCPU:              "CPU",
+		MetricsTypeServerDiskBandwidth:    "Disk Bandwidth",
+		MetricsTypeServerDiskIOPS:         "Disk IOPS",
+		MetricsTypeServerNetworkBandwidth: "Network Bandwidth",
+		MetricsTypeServerNetworkPPS:       "Network PPS",
+		MetricsTypeServerPublicNetwork:    "Public Network Bandwidth",
+		MetricsTypeServerPrivateNetwork:   "Private Network Bandwidth",
+
+		MetricsTypeLoadBalancerOpenConnections:      "Open Connections",
+		MetricsTypeLoadBalancerConnectionsPerSecond: "Connections Per Second",
+		MetricsTypeLoadBalancerRequestsPerSecond:    "Requests Per Second",
+		MetricsTypeLoadBalancerBandwidth:            "Bandwidth",
 	}
 
 	metricTypeToServerMetricType = map[MetricsType]hcloud.ServerMetricType{
@@ -802,8 +3933,18 @@ var (
 		MetricsTypeServerDiskIOPS:         hcloud.ServerMetricDisk,
 		MetricsTypeServerNetworkBandwidth: hcloud.ServerMetricNetwork,
 		MetricsTypeServerNetworkPPS:       hcloud.ServerMetricNetwork,
+		MetricsTypeServerPublicNetwork:    hcloud.ServerMetricNetwork,
+		MetricsTypeServerPrivateNetwork:   hcloud.ServerMetricNetwork,
 	}
 
+	// The Hetzner Cloud API only returns aggregated series for the whole Load Balancer. There are no series scoped
+	// to single targets or services, so a per-target breakdown (ie. to analyze load distribution) is not possible.
+	// The same applies to services: connections and requests of all listen ports are summed up, and the API does not
+	// accept a service or port in the metrics request either. requests_per_second is also not split by protocol, it
+	// only counts the requests of HTTP and HTTPS services, TCP services are only visible in the connection series.
+	// If the API ever adds target or service scoped series, they need to be added here, otherwise they are dropped in
+	// [filterLoadBalancerMetrics]. Service scoped series should then get a `listen_port` label, so they can be
+	// told apart in the legend.
 	loadBalancerMetricsTypeSeries = map[MetricsType][]string{
 		MetricsTypeLoadBalancerOpenConnections:      {"open_connections"},
 		MetricsTypeLoadBalancerConnectionsPerSecond: {"connections_per_second"},
@@ -855,8 +3996,21 @@ func filterServerMetrics(metrics *hcloud.ServerMetrics, metricsTypes []MetricsTy
 
 	// For every requested metricsType, copy every series into the copied struct
 	for _, metricsType := range metricsTypes {
+		if isNetworkInterfaceMetricsType(metricsType) {
+			// The series are only renamed after the interfaces of the server are known, see [splitNetworkInterfaces]
+			for name, series := range metrics.TimeSeries {
+				if networkInterfaceBandwidthSeries.MatchString(name) {
+					metricsCopy.TimeSeries[name] = series
+				}
+			}
+			continue
+		}
+
 		for _, series := range serverMetricsTypeSeries[metricsType] {
-			metricsCopy.TimeSeries[series] = metrics.TimeSeries[series]
+			// Missing series are not copied, so they do not show up as empty frames
+			if values, ok := metrics.TimeSeries[series]; ok {
+				metricsCopy.TimeSeries[series] = values
+			}
 		}
 	}
 
@@ -870,7 +4024,10 @@ func filterLoadBalancerMetrics(metrics *hcloud.LoadBalancerMetrics, metricsTypes
 	// For every requested metricsType, copy every series into the copied struct
 	for _, metricsType := range metricsTypes {
 		for _, series := range loadBalancerMetricsTypeSeries[metricsType] {
-			metricsCopy.TimeSeries[series] = metrics.TimeSeries[series]
+			// Missing series are not copied, so they do not show up as empty frames
+			if values, ok := metrics.TimeSeries[series]; ok {
+				metricsCopy.TimeSeries[series] = values
+			}
 		}
 	}
 
@@ -2,11 +2,20 @@ package plugin
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
 func TestQueryData(t *testing.T) {
@@ -29,6 +38,956 @@ func TestQueryData(t *testing.T) {
 	}
 }
 
+func TestCallResource_Routing(t *testing.T) {
+	ds := Datasource{
+		nameCacheServer:       newTestNameCache(10),
+		nameCacheLoadBalancer: NewNameCache[hcloud.LoadBalancer](nil, nil, nil, 10),
+	}
+	ds.nameCacheServer.Insert(&hcloud.Server{ID: 1, Name: "one"})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "unknown path", method: http.MethodGet, path: "unknown", wantStatus: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodGet, path: "cache/refresh", wantStatus: http.StatusMethodNotAllowed},
+		{name: "servers with invalid selector", method: http.MethodGet, path: "servers?selector=env%3Dprod%2C%2C", wantStatus: http.StatusBadRequest},
+		{name: "server search without query", method: http.MethodGet, path: "servers/search", wantStatus: http.StatusBadRequest},
+		{
+			name:       "metric types",
+			method:     http.MethodGet,
+			path:       "metric-types?resourceType=load-balancer",
+			wantStatus: http.StatusOK,
+		},
+		{name: "metric types of unknown resource type", method: http.MethodGet, path: "metric-types?resourceType=volume", wantStatus: http.StatusBadRequest},
+		{
+			name:       "cache refresh",
+			method:     http.MethodPost,
+			path:       "cache/refresh",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"clearedServers":1,"clearedLoadBalancers":0,"warmed":false}`,
+		},
+		{name: "cache config", method: http.MethodGet, path: "cache/config", wantStatus: http.StatusOK, wantBody: `{"ttlSeconds":0,"size":10}`},
+		{name: "set cache config", method: http.MethodPut, path: "cache/config", body: `{"ttlSeconds":300}`, wantStatus: http.StatusOK, wantBody: `{"ttlSeconds":300,"size":10}`},
+		{name: "set cache config without ttl", method: http.MethodPut, path: "cache/config", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "set negative cache ttl", method: http.MethodPut, path: "cache/config", body: `{"ttlSeconds":-1}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *backend.CallResourceResponse
+			err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+				Method: tt.method,
+				Path:   strings.Split(tt.path, "?")[0],
+				URL:    tt.path,
+				Body:   []byte(tt.body),
+			}, backend.CallResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+				resp = r
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Status != tt.wantStatus {
+				t.Errorf("CallResource() status = %d, want %d", resp.Status, tt.wantStatus)
+			}
+			if tt.wantBody != "" && string(resp.Body) != tt.wantBody {
+				t.Errorf("CallResource() body = %s, want %s", resp.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestQueryModel_RequestedMetricsTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		qm   QueryModel
+		want []MetricsType
+	}{
+		{name: "empty", qm: QueryModel{}, want: nil},
+		{name: "legacy single type", qm: QueryModel{MetricsType: MetricsTypeServerCPU}, want: []MetricsType{MetricsTypeServerCPU}},
+		{
+			name: "multiple types",
+			qm:   QueryModel{MetricsTypes: []MetricsType{MetricsTypeServerCPU, MetricsTypeServerNetworkBandwidth}},
+			want: []MetricsType{MetricsTypeServerCPU, MetricsTypeServerNetworkBandwidth},
+		},
+		{
+			name: "multiple types take precedence",
+			qm:   QueryModel{MetricsType: MetricsTypeServerDiskIOPS, MetricsTypes: []MetricsType{MetricsTypeServerCPU}},
+			want: []MetricsType{MetricsTypeServerCPU},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.qm.RequestedMetricsTypes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RequestedMetricsTypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryModel_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want QueryModel
+	}{
+		{
+			name: "v0.1 query",
+			json: `{"queryType":"metrics","resourceType":"server","metricsType":"cpu","selectBy":"id","labelSelectors":[],"resourceIDs":[1,2],"resourceIDsVariable":"","legendFormat":""}`,
+			want: QueryModel{
+				ResourceType:   ResourceTypeServer,
+				MetricsType:    MetricsTypeServerCPU,
+				MetricsTypes:   []MetricsType{MetricsTypeServerCPU},
+				SelectBy:       SelectByID,
+				LabelSelectors: []string{},
+				ResourceIDs:    []int64{1, 2},
+				Aggregation:    AggregationNone,
+				TopNBy:         TopNByLast,
+			},
+		},
+		{
+			name: "variable select by",
+			json: `{"resourceType":"load-balancer","metricsType":"bandwidth","selectBy":"name","resourceIDs":[3]}`,
+			want: QueryModel{
+				ResourceType: ResourceTypeLoadBalancer,
+				MetricsType:  MetricsTypeLoadBalancerBandwidth,
+				MetricsTypes: []MetricsType{MetricsTypeLoadBalancerBandwidth},
+				SelectBy:     SelectByID,
+				ResourceIDs:  []int64{3},
+				Aggregation:  AggregationNone,
+				TopNBy:       TopNByLast,
+			},
+		},
+		{
+			name: "current query",
+			json: `{"resourceType":"server","metricsType":"cpu","metricsTypes":["disk-iops","network-pps"],"selectBy":"label","labelSelectors":["env=prod"],"aggregation":"sum","topN":5,"topNBy":"max"}`,
+			want: QueryModel{
+				ResourceType:   ResourceTypeServer,
+				MetricsType:    MetricsTypeServerCPU,
+				MetricsTypes:   []MetricsType{MetricsTypeServerDiskIOPS, MetricsTypeServerNetworkPPS},
+				SelectBy:       SelectByLabel,
+				LabelSelectors: []string{"env=prod"},
+				Aggregation:    AggregationSum,
+				TopN:           5,
+				TopNBy:         TopNByMax,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got QueryModel
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("UnmarshalJSON() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var qm QueryModel
+	if err := json.Unmarshal([]byte(`{"resourceIds":"1"}`), &qm); err == nil {
+		t.Errorf("UnmarshalJSON() should return an error for invalid JSON")
+	}
+	if err := json.Unmarshal([]byte(`{"resourceType":"server","selectBy":"name","resourceIds":[]}`), &qm); !errors.Is(err, errUnresolvedVariable) {
+		t.Errorf("UnmarshalJSON() error = %v, want an error for a variable without ids", err)
+	}
+}
+
+func Test_filterByLabel(t *testing.T) {
+	values := []SelectableValue{
+		{Value: 1, Label: "webserver-1"},
+		{Value: 2, Label: "database"},
+		{Value: 3, Label: "WebServer-2"},
+	}
+
+	got := filterByLabel(values, "Web")
+	want := []SelectableValue{
+		{Value: 1, Label: "webserver-1"},
+		{Value: 3, Label: "WebServer-2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterByLabel() = %v, want %v", got, want)
+	}
+}
+
+func TestDatasource_resolveSavedSelector(t *testing.T) {
+	d := Datasource{options: Options{SavedSelectors: map[string]string{"prod": "env=prod"}}}
+
+	qm, err := d.resolveSavedSelector(QueryModel{SelectBy: SelectByLabel, LabelSelectors: []string{"role=web"}, SavedSelector: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"role=web", "env=prod"}; !reflect.DeepEqual(qm.LabelSelectors, want) {
+		t.Errorf("LabelSelectors = %v, want %v", qm.LabelSelectors, want)
+	}
+	if (QueryModel{SelectBy: SelectByLabel, SavedSelector: "prod"}).hasEmptySelection() {
+		t.Errorf("query with a saved selector should not be an empty selection")
+	}
+
+	if _, err := d.resolveSavedSelector(QueryModel{SavedSelector: "staging"}); err == nil {
+		t.Errorf("resolveSavedSelector() should fail for unknown names")
+	}
+
+	if err := (Options{SavedSelectors: map[string]string{"broken": "env in (prod"}}).Validate(); err == nil {
+		t.Errorf("Validate() should reject invalid saved selectors")
+	}
+}
+
+func Test_countByStatus(t *testing.T) {
+	servers := []*hcloud.Server{
+		{ID: 1, Status: hcloud.ServerStatusRunning},
+		{ID: 2, Status: hcloud.ServerStatusOff},
+		{ID: 3, Status: hcloud.ServerStatusRunning},
+	}
+
+	got := countByStatus(servers)
+	want := map[hcloud.ServerStatus]int{hcloud.ServerStatusRunning: 2, hcloud.ServerStatusOff: 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("countByStatus() = %v, want %v", got, want)
+	}
+
+	if got := countByStatus(nil); len(got) != 0 {
+		t.Errorf("countByStatus() without servers = %v, want empty map", got)
+	}
+}
+
+func TestOptions_applicationVersion(t *testing.T) {
+	if got := (Options{}).applicationVersion("1.2.3"); got != "1.2.3" {
+		t.Errorf("applicationVersion() = %q, want %q", got, "1.2.3")
+	}
+	if got := (Options{AppIdentifier: "tenant-a"}).applicationVersion("1.2.3"); got != "1.2.3 tenant-a" {
+		t.Errorf("applicationVersion() = %q, want %q", got, "1.2.3 tenant-a")
+	}
+	if err := (Options{AppIdentifier: "tenant-a\r\nX-Injected: 1"}).Validate(); err == nil {
+		t.Errorf("Validate() should reject app identifiers with control characters")
+	}
+}
+
+func TestOptions_DefaultLegendFormat(t *testing.T) {
+	d := newDatasource(Options{DisableBuffering: true, DefaultLegendFormat: "{{ name }} ({{ series_name }})"}, "test", hcloud.NewClient(), newFakeServers(), fakeLoadBalancerClient{})
+
+	tests := []struct {
+		name         string
+		legendFormat string
+		want         string
+	}{
+		{name: "default", want: "web-1 (cpu)"},
+		{name: "query overrides default", legendFormat: "{{ id }}", want: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := d.queryMetrics(context.Background(), newFakeQuery(t, QueryTypeMetrics, map[string]any{
+				"resourceType": ResourceTypeServer,
+				"metricsType":  MetricsTypeServerCPU,
+				"selectBy":     SelectByID,
+				"resourceIds":  []int64{1},
+				"step":         60,
+				"legendFormat": tt.legendFormat,
+			}))
+			if resp.Error != nil {
+				t.Fatalf("queryMetrics() error = %v", resp.Error)
+			}
+			if len(resp.Frames) != 1 {
+				t.Fatalf("queryMetrics() returned %d frames, want 1", len(resp.Frames))
+			}
+
+			valuesField := resp.Frames[0].Fields[len(resp.Frames[0].Fields)-1]
+			if got := valuesField.Config.DisplayNameFromDS; got != tt.want {
+				t.Errorf("display name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_seriesMetadata_withThresholds(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	thresholds := map[MetricsType][]ThresholdStep{
+		MetricsTypeLoadBalancerOpenConnections: {{Color: "green"}, {Value: ptr(1000), Color: "yellow"}, {Value: ptr(5000), Color: "red"}},
+		MetricsTypeServerCPU:                   {{Color: "green"}},
+	}
+
+	meta := newSeriesMetadata(loadBalancerSeriesToDisplayName, loadBalancerSeriesToUnit, nil).
+		withThresholds(loadBalancerMetricsTypeSeries, thresholds)
+
+	frames := loadBalancerMetricsToFrames(1, "lb", "", meta, &hcloud.LoadBalancerMetrics{
+		TimeSeries: map[string][]hcloud.LoadBalancerMetricsValue{
+			"open_connections":       {{Timestamp: 1, Value: "1"}},
+			"connections_per_second": {{Timestamp: 1, Value: "1"}},
+		},
+	})
+
+	thresholdsOf := func(seriesName string) *data.ThresholdsConfig {
+		for _, frame := range frames {
+			if frame.Fields[1].Name == seriesName {
+				return frame.Fields[1].Config.Thresholds
+			}
+		}
+		t.Fatalf("no frame for series %q", seriesName)
+		return nil
+	}
+
+	got := thresholdsOf("open_connections")
+	if got == nil || len(got.Steps) != 3 {
+		t.Fatalf("open_connections thresholds = %v, want 3 steps", got)
+	}
+	if !math.IsInf(float64(got.Steps[0].Value), -1) || got.Steps[2].Value != 5000 || got.Steps[2].Color != "red" {
+		t.Errorf("open_connections thresholds = %v", got.Steps)
+	}
+	if got := thresholdsOf("connections_per_second"); got != nil {
+		t.Errorf("connections_per_second should have no thresholds, got %v", got)
+	}
+	if len(meta.thresholds) != 1 {
+		t.Errorf("thresholds of server metrics types should be ignored, got %v", meta.thresholds)
+	}
+}
+
+func TestOptions_Validate_thresholds(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name    string
+		steps   map[MetricsType][]ThresholdStep
+		wantErr string
+	}{
+		{name: "valid", steps: map[MetricsType][]ThresholdStep{MetricsTypeLoadBalancerOpenConnections: {{Color: "green"}, {Value: ptr(10), Color: "red"}}}},
+		{name: "unknown metrics type", steps: map[MetricsType][]ThresholdStep{"volume": {{Color: "green"}}}, wantErr: `thresholds for unknown metrics type "volume"`},
+		{name: "missing color", steps: map[MetricsType][]ThresholdStep{MetricsTypeServerCPU: {{}}}, wantErr: `threshold 0 of metrics type "cpu" has no color`},
+		{name: "missing value", steps: map[MetricsType][]ThresholdStep{MetricsTypeServerCPU: {{Color: "green"}, {Color: "red"}}}, wantErr: "only the first threshold may omit it"},
+		{name: "descending", steps: map[MetricsType][]ThresholdStep{MetricsTypeServerCPU: {{Value: ptr(80), Color: "green"}, {Value: ptr(50), Color: "red"}}}, wantErr: "ascending order"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Options{Thresholds: tt.steps}.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueryModel_validate_resourceTypes(t *testing.T) {
+	qm := QueryModel{ResourceTypes: []ResourceType{ResourceTypeServer, ResourceTypeLoadBalancer}}
+	if err := qm.validate(); err != nil {
+		t.Errorf("validate() error = %v", err)
+	}
+	if got := qm.requestedResourceTypes(); !reflect.DeepEqual(got, qm.ResourceTypes) {
+		t.Errorf("requestedResourceTypes() = %v, want %v", got, qm.ResourceTypes)
+	}
+
+	qm.ResourceTypes = append(qm.ResourceTypes, "volume")
+	if err := qm.validate(); err == nil || !strings.Contains(err.Error(), `unknown resourceType "volume"`) {
+		t.Errorf("validate() error = %v, want unknown resource type", err)
+	}
+}
+
+func Test_alignTimeRange(t *testing.T) {
+	timeRange := backend.TimeRange{
+		From: time.Date(2024, 1, 1, 10, 17, 3, 0, time.UTC),
+		To:   time.Date(2024, 1, 1, 12, 45, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name string
+		step int
+		want backend.TimeRange
+	}{
+		{
+			name: "hour",
+			step: 3600,
+			want: backend.TimeRange{From: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), To: time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "end already aligned",
+			step: 900,
+			want: backend.TimeRange{From: time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC), To: time.Date(2024, 1, 1, 12, 45, 0, 0, time.UTC)},
+		},
+		{
+			// The unix epoch was a Thursday
+			name: "week",
+			step: 7 * 24 * 3600,
+			want: backend.TimeRange{From: time.Date(2023, 12, 28, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)},
+		},
+		{name: "one second", step: 1, want: timeRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := alignTimeRange(timeRange, tt.step)
+			if !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) {
+				t.Errorf("alignTimeRange() = %v - %v, want %v - %v", got.From, got.To, tt.want.From, tt.want.To)
+			}
+		})
+	}
+}
+
+func Test_imageName(t *testing.T) {
+	tests := []struct {
+		name  string
+		image *hcloud.Image
+		want  string
+	}{
+		{name: "deleted image", image: nil, want: ""},
+		{name: "system image", image: &hcloud.Image{ID: 1, Name: "ubuntu-24.04", Description: "Ubuntu 24.04"}, want: "ubuntu-24.04"},
+		{name: "snapshot", image: &hcloud.Image{ID: 2, Description: "webserver golden image"}, want: "webserver golden image"},
+		{name: "snapshot without description", image: &hcloud.Image{ID: 3}, want: "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imageName(tt.image); got != tt.want {
+				t.Errorf("imageName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateLabelSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		wantErr  string
+	}{
+		{selector: ""},
+		{selector: "env=prod"},
+		{selector: "env=prod, tier!=db, !legacy"},
+		{selector: "env in (prod,staging),tier notin (db)"},
+		{selector: "env=prod,,tier=web", wantErr: "empty expression"},
+		{selector: "env=prod,", wantErr: "empty expression"},
+		{selector: "env in (prod,staging", wantErr: `missing ")"`},
+		{selector: "env=prod)", wantErr: `unexpected ")"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			err := validateLabelSelector(tt.selector)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateLabelSelector() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateLabelSelector() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDatasource_GetResourceIDs_MaxResources(t *testing.T) {
+	d := &Datasource{options: Options{MaxResources: 2}}
+
+	ids, err := d.GetResourceIDs(context.Background(), QueryModel{ResourceType: ResourceTypeServer, SelectBy: SelectByID, ResourceIDs: []int64{1, 2}})
+	if err != nil || len(ids) != 2 {
+		t.Errorf("GetResourceIDs() = %v, %v, want 2 resources", ids, err)
+	}
+
+	_, err = d.GetResourceIDs(context.Background(), QueryModel{ResourceType: ResourceTypeServer, SelectBy: SelectByID, ResourceIDs: []int64{1, 2, 3}})
+	if err == nil || !strings.Contains(err.Error(), "the query selects 3 resources, but at most 2 are allowed") {
+		t.Errorf("GetResourceIDs() error = %v, want error about too many resources", err)
+	}
+}
+
+func Test_serverPrices(t *testing.T) {
+	serverType := &hcloud.ServerType{
+		Pricings: []hcloud.ServerTypeLocationPricing{
+			{
+				Location: &hcloud.Location{Name: "fsn1"},
+				Hourly:   hcloud.Price{Currency: "EUR", Net: "0.0060000000", Gross: "0.0071400000000000"},
+				Monthly:  hcloud.Price{Currency: "EUR", Net: "3.7900000000", Gross: "4.5101000000000000"},
+			},
+			{
+				Location: &hcloud.Location{Name: "ash"},
+				Monthly:  hcloud.Price{Currency: "EUR", Net: "4.5900000000", Gross: "5.4621000000000000"},
+			},
+		},
+	}
+
+	var prices serverPrices
+	prices.append(serverPricing(serverType, "fsn1"))
+	prices.append(serverPricing(serverType, "hel1"))
+	prices.append(serverPricing(nil, "fsn1"))
+
+	if got := prices.monthlyNet[0]; got == nil || *got != 3.79 {
+		t.Errorf("monthly net price in fsn1 = %v, want 3.79", got)
+	}
+	if got := prices.hourlyGross[0]; got == nil || *got != 0.00714 {
+		t.Errorf("hourly gross price in fsn1 = %v, want 0.00714", got)
+	}
+	if prices.currencies[0] != "EUR" {
+		t.Errorf("currency = %q, want EUR", prices.currencies[0])
+	}
+	for i := 1; i < 3; i++ {
+		if prices.monthlyNet[i] != nil || prices.currencies[i] != "" {
+			t.Errorf("prices %d should be empty for unknown pricing, got %v %q", i, prices.monthlyNet[i], prices.currencies[i])
+		}
+	}
+
+	if fields := prices.fields(); len(fields) != 5 || fields[0].Len() != 3 {
+		t.Errorf("fields() should return 5 fields with 3 values")
+	}
+}
+
+func TestDatasource_GetResourceIDs_NameFilter(t *testing.T) {
+	qm := QueryModel{
+		ResourceType: ResourceTypeServer,
+		SelectBy:     SelectByID,
+		ResourceIDs:  []int64{1, 2, 3, 4},
+		NameFilter:   `^web-\d`,
+	}
+
+	t.Run("deleted resources", func(t *testing.T) {
+		servers := newFakeServers()
+		d := newFakeDatasource(servers)
+
+		// Server 4 does not exist, so it is removed
+		ids, err := d.GetResourceIDs(context.Background(), qm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []int64{1, 2}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("GetResourceIDs() = %v, want %v", ids, want)
+		}
+		// The names of all servers are listed at once, only the missing server is requested on its own
+		if got := servers.getByIDCalls.Load(); got != 1 {
+			t.Errorf("servers were requested %d times by id, want 1", got)
+		}
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		servers := &fakeServerClient{getByIDErr: hcloud.Error{Code: hcloud.ErrorCodeRateLimitExceeded, Message: "limit reached"}}
+		d := newFakeDatasource(servers)
+
+		if _, err := d.GetResourceIDs(context.Background(), qm); !hcloud.IsError(err, hcloud.ErrorCodeRateLimitExceeded) {
+			t.Errorf("GetResourceIDs() error = %v, want the error of the name lookup", err)
+		}
+	})
+}
+
+func Test_sortAndLimit(t *testing.T) {
+	servers := func(ids ...int64) []*hcloud.Server {
+		result := make([]*hcloud.Server, 0, len(ids))
+		for _, id := range ids {
+			result = append(result, &hcloud.Server{ID: id})
+		}
+		return result
+	}
+	idFn := func(server *hcloud.Server) int64 { return server.ID }
+
+	tests := []struct {
+		name  string
+		input []*hcloud.Server
+		limit int
+		want  []*hcloud.Server
+	}{
+		{name: "no limit", input: servers(3, 1, 2), limit: 0, want: servers(1, 2, 3)},
+		{name: "limit", input: servers(3, 1, 2), limit: 2, want: servers(1, 2)},
+		{name: "limit larger than input", input: servers(3, 1), limit: 5, want: servers(1, 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sortAndLimit(tt.input, tt.limit, idFn); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortAndLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatasource_healthMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		d    *Datasource
+		want string
+	}{
+		{name: "no name", d: &Datasource{}, want: "connected"},
+		{name: "data source name", d: &Datasource{name: "Hetzner Production"}, want: "Data source Hetzner Production: connected"},
+		{
+			name: "project name",
+			d:    &Datasource{name: "Hetzner Production", options: Options{ProjectName: "production"}},
+			want: "Project production: connected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.d.healthMessage("connected"); got != tt.want {
+				t.Errorf("healthMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatasource_CheckHealth_Cached(t *testing.T) {
+	// The client is not set, so this fails if the API would be called
+	d := &Datasource{}
+	cached := &backend.CheckHealthResult{Status: backend.HealthStatusOk, Message: "cached"}
+	d.healthCache.set(cached, time.Now().Add(time.Minute))
+
+	result, err := d.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != cached {
+		t.Errorf("CheckHealth() = %v, want cached result", result)
+	}
+}
+
+func Test_healthCache(t *testing.T) {
+	now := time.Now()
+	var c healthCache
+
+	if c.get(now) != nil {
+		t.Errorf("get() on empty cache should return nil")
+	}
+
+	result := &backend.CheckHealthResult{Status: backend.HealthStatusOk}
+	c.set(result, now.Add(30*time.Second))
+
+	if got := c.get(now.Add(29 * time.Second)); got != result {
+		t.Errorf("get() before expiry = %v, want %v", got, result)
+	}
+	if got := c.get(now.Add(30 * time.Second)); got != nil {
+		t.Errorf("get() after expiry = %v, want nil", got)
+	}
+}
+
+func TestDatasource_GetResourceIDs_StatusSelector(t *testing.T) {
+	servers := newFakeServers()
+	servers.servers[1].Status = hcloud.ServerStatusOff
+	d := newFakeDatasource(servers)
+
+	running := []hcloud.ServerStatus{hcloud.ServerStatusRunning}
+	tests := []struct {
+		name string
+		qm   QueryModel
+		want []int64
+	}{
+		{
+			name: "label",
+			qm:   QueryModel{SelectBy: SelectByLabel, LabelSelectors: []string{"env=prod"}},
+			want: []int64{1},
+		},
+		{
+			name: "id",
+			qm:   QueryModel{SelectBy: SelectByID, ResourceIDs: []int64{2, 3}},
+			want: []int64{3},
+		},
+		{
+			name: "id keeps the order",
+			qm:   QueryModel{SelectBy: SelectByID, ResourceIDs: []int64{3, 2, 1}},
+			want: []int64{3, 1},
+		},
+		{
+			name: "id with exclude label selectors",
+			qm:   QueryModel{SelectBy: SelectByID, ResourceIDs: []int64{1, 2, 3}, ExcludeLabelSelectors: []string{"env=staging"}},
+			want: []int64{1},
+		},
+		{
+			name: "name",
+			qm:   QueryModel{SelectBy: SelectByResourceName, ResourceNames: []string{"web-1", "web-2"}},
+			want: []int64{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.qm.ResourceType = ResourceTypeServer
+			tt.qm.StatusSelector = running
+
+			selectedIDs := slices.Clone(tt.qm.ResourceIDs)
+
+			ids, err := d.GetResourceIDs(context.Background(), tt.qm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("GetResourceIDs() = %v, want %v", ids, tt.want)
+			}
+			if !reflect.DeepEqual(tt.qm.ResourceIDs, selectedIDs) {
+				t.Errorf("GetResourceIDs() modified the selected ids to %v", tt.qm.ResourceIDs)
+			}
+		})
+	}
+}
+
+func TestQueryModel_validateMetrics(t *testing.T) {
+	valid := QueryModel{
+		ResourceType: ResourceTypeServer,
+		MetricsTypes: []MetricsType{MetricsTypeServerCPU},
+		SelectBy:     SelectByID,
+		ResourceIDs:  []int64{1},
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(qm *QueryModel)
+		wantErr string
+	}{
+		{name: "valid", modify: func(qm *QueryModel) {}},
+		{name: "missing resource type", modify: func(qm *QueryModel) { qm.ResourceType = "" }, wantErr: "resourceType is required"},
+		{name: "unknown resource type", modify: func(qm *QueryModel) { qm.ResourceType = "volume" }, wantErr: `unknown resourceType "volume", valid values are: server, load-balancer, network, placement-group`},
+		{
+			name:    "multiple resource types",
+			modify:  func(qm *QueryModel) { qm.ResourceTypes = []ResourceType{ResourceTypeServer, ResourceTypeLoadBalancer} },
+			wantErr: "resourceTypes is only supported by resource list queries, use resourceType instead",
+		},
+		{name: "missing select by", modify: func(qm *QueryModel) { qm.SelectBy = "" }, wantErr: "selectBy is required"},
+		{name: "unknown select by", modify: func(qm *QueryModel) { qm.SelectBy = "name" }, wantErr: `unknown selectBy "name", valid values are: label, id, resource-name, resource`},
+		{name: "negative limit", modify: func(qm *QueryModel) { qm.Limit = -1 }, wantErr: "limit must not be negative, got -1"},
+		{
+			name: "resource ID zero",
+			modify: func(qm *QueryModel) {
+				qm.SelectBy = SelectByResource
+				qm.Resources = []string{"web-1, 0"}
+			},
+			wantErr: `invalid resource ID "0" in resources, IDs must be positive`,
+		},
+		{
+			name: "negative resource ID",
+			modify: func(qm *QueryModel) {
+				qm.SelectBy = SelectByResource
+				qm.Resources = []string{"-5"}
+			},
+			wantErr: `invalid resource ID "-5" in resources, IDs must be positive`,
+		},
+		{name: "invalid name filter", modify: func(qm *QueryModel) { qm.NameFilter = "web-(" }, wantErr: "invalid nameFilter: error parsing regexp: missing closing ): `web-(`"},
+		{name: "unknown output format", modify: func(qm *QueryModel) { qm.OutputFormat = "table" }, wantErr: `unknown outputFormat "table", valid values are: long, wide`},
+		{
+			name:    "invalid label selector",
+			modify:  func(qm *QueryModel) { qm.LabelSelectors = []string{"env=prod,,"} },
+			wantErr: `invalid label selector "env=prod,,": empty expression, expressions are separated by a single comma, e.g. "env=prod,tier!=db"`,
+		},
+		{
+			name:    "empty exclude label selector",
+			modify:  func(qm *QueryModel) { qm.ExcludeLabelSelectors = []string{""} },
+			wantErr: "excludeLabelSelectors must not contain empty selectors, they would exclude all resources",
+		},
+		{name: "exclude label selector", modify: func(qm *QueryModel) { qm.ExcludeLabelSelectors = []string{"canary"} }},
+		{name: "status selector", modify: func(qm *QueryModel) { qm.StatusSelector = []hcloud.ServerStatus{hcloud.ServerStatusRunning} }},
+		{
+			name:    "unknown status",
+			modify:  func(qm *QueryModel) { qm.StatusSelector = []hcloud.ServerStatus{"active"} },
+			wantErr: `unknown server status "active" in statusSelector`,
+		},
+		{
+			name: "status selector for load balancers",
+			modify: func(qm *QueryModel) {
+				qm.ResourceType = ResourceTypeLoadBalancer
+				qm.MetricsTypes = []MetricsType{MetricsTypeLoadBalancerOpenConnections}
+				qm.StatusSelector = []hcloud.ServerStatus{hcloud.ServerStatusRunning}
+			},
+			wantErr: "statusSelector is only supported for servers",
+		},
+		{name: "negative top n", modify: func(qm *QueryModel) { qm.TopN = -1 }, wantErr: "topN must not be negative, got -1"},
+		{name: "negative step", modify: func(qm *QueryModel) { qm.Step = -1 }, wantErr: "step must not be negative, got -1"},
+		{name: "invalid compare offset", modify: func(qm *QueryModel) { qm.CompareOffset = "-7d" }, wantErr: "invalid compareOffset: the offset must be positive"},
+		{name: "unknown aggregation", modify: func(qm *QueryModel) { qm.Aggregation = "median" }, wantErr: `unknown aggregation: "median"`},
+		{name: "unknown top n statistic", modify: func(qm *QueryModel) { qm.TopNBy = "min" }, wantErr: `unknown top n statistic: "min"`},
+		{
+			name:    "missing metrics type",
+			modify:  func(qm *QueryModel) { qm.MetricsTypes = nil },
+			wantErr: "no metrics type selected, valid values for server are: cpu, disk-bandwidth, disk-iops, network-bandwidth, network-pps, private-network-bandwidth, public-network-bandwidth",
+		},
+		{
+			name:    "metrics type of other resource type",
+			modify:  func(qm *QueryModel) { qm.ResourceType = ResourceTypeLoadBalancer },
+			wantErr: `unknown metrics type "cpu" for load-balancer, valid values are: bandwidth, connections-per-second, open-connections, requests-per-second`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qm := valid
+			tt.modify(&qm)
+
+			err := qm.validateMetrics()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validateMetrics() unexpected error = %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("validateMetrics() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueryModel_hasEmptySelection(t *testing.T) {
+	tests := []struct {
+		name string
+		qm   QueryModel
+		want bool
+	}{
+		{name: "no label selectors", qm: QueryModel{SelectBy: SelectByLabel}, want: true},
+		{name: "blank label selector", qm: QueryModel{SelectBy: SelectByLabel, LabelSelectors: []string{" "}}, want: true},
+		{name: "label selector", qm: QueryModel{SelectBy: SelectByLabel, LabelSelectors: []string{"env=prod"}}, want: false},
+		{name: "no ids", qm: QueryModel{SelectBy: SelectByID}, want: true},
+		{name: "ids", qm: QueryModel{SelectBy: SelectByID, ResourceIDs: []int64{1}}, want: false},
+		{name: "no names", qm: QueryModel{SelectBy: SelectByResourceName}, want: true},
+		{name: "names", qm: QueryModel{SelectBy: SelectByResourceName, ResourceNames: []string{"web"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.qm.hasEmptySelection(); got != tt.want {
+				t.Errorf("hasEmptySelection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatasource_GetResourceIDs_EmptySelection(t *testing.T) {
+	// The client is not set, so this fails if the API would be called
+	d := &Datasource{}
+
+	ids, err := d.GetResourceIDs(context.Background(), QueryModel{ResourceType: ResourceTypeServer, SelectBy: SelectByID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("GetResourceIDs() = %v, want no resources", ids)
+	}
+}
+
+func Test_networkSubnets(t *testing.T) {
+	_, ipRange, _ := net.ParseCIDR("10.0.1.0/24")
+	subnets := []hcloud.NetworkSubnet{
+		{Type: hcloud.NetworkSubnetTypeCloud, IPRange: ipRange, NetworkZone: hcloud.NetworkZoneEUCentral, Gateway: net.ParseIP("10.0.0.1")},
+		{Type: hcloud.NetworkSubnetTypeVSwitch},
+	}
+
+	want := []NetworkSubnet{
+		{Type: "cloud", IPRange: "10.0.1.0/24", NetworkZone: "eu-central", Gateway: "10.0.0.1"},
+		{Type: "vswitch"},
+	}
+	if got := networkSubnets(subnets); !reflect.DeepEqual(got, want) {
+		t.Errorf("networkSubnets() = %v, want %v", got, want)
+	}
+}
+
+func Test_subnetNetworkZones(t *testing.T) {
+	subnets := []hcloud.NetworkSubnet{
+		{NetworkZone: hcloud.NetworkZoneUSEast},
+		{NetworkZone: hcloud.NetworkZoneEUCentral},
+		{NetworkZone: hcloud.NetworkZoneUSEast},
+		{Type: hcloud.NetworkSubnetTypeVSwitch},
+	}
+
+	if got, want := subnetNetworkZones(subnets), []string{"eu-central", "us-east"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subnetNetworkZones() = %v, want %v", got, want)
+	}
+}
+
+func Test_networkZoneInfos(t *testing.T) {
+	locations := []*hcloud.Location{
+		{Name: "nbg1", NetworkZone: hcloud.NetworkZoneEUCentral},
+		{Name: "ash", NetworkZone: hcloud.NetworkZoneUSEast},
+		{Name: "fsn1", NetworkZone: hcloud.NetworkZoneEUCentral},
+	}
+
+	want := []NetworkZoneInfo{
+		{Value: "eu-central", Label: "eu-central", Locations: []string{"fsn1", "nbg1"}},
+		{Value: "us-east", Label: "us-east", Locations: []string{"ash"}},
+	}
+	if got := networkZoneInfos(locations); !reflect.DeepEqual(got, want) {
+		t.Errorf("networkZoneInfos() = %v, want %v", got, want)
+	}
+}
+
+func Test_labelsString(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+	}{
+		{name: "empty", labels: nil, want: ""},
+		{name: "single", labels: map[string]string{"env": "prod"}, want: "env=prod"},
+		{name: "sorted", labels: map[string]string{"role": "web", "env": "prod", "empty": ""}, want: "empty=,env=prod,role=web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := labelsString(tt.labels); got != tt.want {
+				t.Errorf("labelsString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_idsByName(t *testing.T) {
+	servers := []*hcloud.Server{{ID: 1, Name: "web-1"}, {ID: 2, Name: "web-2"}, {ID: 3, Name: "db"}}
+
+	got, err := idsByName(servers, []string{"db", "web-1"}, serverIdentifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{3, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("idsByName() = %v, want %v", got, want)
+	}
+
+	_, err = idsByName(servers, []string{"web-1", "web-3", "cache"}, serverIdentifier)
+	if want := "no resources found with names: web-3, cache"; err == nil || err.Error() != want {
+		t.Errorf("idsByName() error = %v, want %v", err, want)
+	}
+}
+
+func Test_resolveResourceTokens(t *testing.T) {
+	servers := []*hcloud.Server{{ID: 1, Name: "web-1"}, {ID: 2, Name: "web-2"}, {ID: 3, Name: "db-prod"}}
+
+	tokens := resourceTokens([]string{"web-1, 12345  db-prod", "cache,web-1", " 0 "})
+	if want := []string{"web-1", "12345", "db-prod", "cache", "web-1", "0"}; !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("resourceTokens() = %v, want %v", tokens, want)
+	}
+
+	ids, unresolved := resolveResourceTokens(servers, tokens, serverIdentifier)
+	if want := []int64{1, 12345, 3}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("resolveResourceTokens() ids = %v, want %v", ids, want)
+	}
+	if want := []string{"cache", "0"}; !reflect.DeepEqual(unresolved, want) {
+		t.Errorf("resolveResourceTokens() unresolved = %v, want %v", unresolved, want)
+	}
+}
+
+func Test_validateMetricsTypes(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType ResourceType
+		metricsTypes []MetricsType
+		wantErr      string
+	}{
+		{name: "valid server", resourceType: ResourceTypeServer, metricsTypes: []MetricsType{MetricsTypeServerCPU, MetricsTypeServerDiskIOPS}},
+		{name: "valid load balancer", resourceType: ResourceTypeLoadBalancer, metricsTypes: []MetricsType{MetricsTypeLoadBalancerBandwidth}},
+		{
+			name:         "unknown",
+			resourceType: ResourceTypeServer,
+			metricsTypes: []MetricsType{"cpus"},
+			wantErr:      `unknown metrics type "cpus" for server, valid values are: cpu, disk-bandwidth, disk-iops, network-bandwidth, network-pps, private-network-bandwidth, public-network-bandwidth`,
+		},
+		{
+			name:         "wrong resource type",
+			resourceType: ResourceTypeLoadBalancer,
+			metricsTypes: []MetricsType{MetricsTypeServerCPU},
+			wantErr:      `unknown metrics type "cpu" for load-balancer, valid values are: bandwidth, connections-per-second, open-connections, requests-per-second`,
+		},
+		{
+			name:         "empty",
+			resourceType: ResourceTypeServer,
+			wantErr:      `no metrics type selected, valid values for server are: cpu, disk-bandwidth, disk-iops, network-bandwidth, network-pps, private-network-bandwidth, public-network-bandwidth`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMetricsTypes(tt.resourceType, tt.metricsTypes)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validateMetricsTypes() unexpected error = %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("validateMetricsTypes() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func Test_getDisplayName(t *testing.T) {
 	type args struct {
 		legendFormat string
@@ -71,6 +1030,14 @@ func Test_getDisplayName(t *testing.T) {
 			},
 			want: "1 x Foobar - requests > Requests",
 		},
+		{
+			name: "Unit and Direction",
+			args: args{
+				legendFormat: "{{ name }} {{ direction }} ({{ unit }})",
+				labels:       data.Labels{LabelName: "Foobar", LabelUnit: "binBps", LabelDirection: "in"},
+			},
+			want: "Foobar in (binBps)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -82,6 +1049,396 @@ func Test_getDisplayName(t *testing.T) {
 	}
 }
 
+func Test_getVar(t *testing.T) {
+	tests := []struct {
+		varFormat string
+		want      string
+	}{
+		{varFormat: "", want: "webserver : 42"},
+		{varFormat: "{{ name }}|{{ id }}", want: "webserver|42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.varFormat, func(t *testing.T) {
+			if got := getVar(tt.varFormat, 42, "webserver"); got != tt.want {
+				t.Errorf("getVar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatasource_warmNameCaches(t *testing.T) {
+	servers := newFakeServers()
+	loadBalancers := fakeLoadBalancerClient{loadBalancers: []*hcloud.LoadBalancer{{ID: 10, Name: "lb-1"}}}
+	d := newDatasource(Options{}, "test", hcloud.NewClient(), servers, loadBalancers)
+
+	d.warmNameCaches(context.Background())
+
+	if got := d.nameCacheServer.Stats().Entries; got != len(servers.servers) {
+		t.Errorf("server name cache has %d entries, want all %d servers", got, len(servers.servers))
+	}
+	if got := d.nameCacheLoadBalancer.Stats().Entries; got != 1 {
+		t.Errorf("load balancer name cache has %d entries, want 1", got)
+	}
+
+	for _, server := range servers.servers {
+		name, err := d.nameCacheServer.Get(context.Background(), server.ID)
+		if err != nil || name != server.Name {
+			t.Errorf("Get(%d) = %q, %v, want %q", server.ID, name, err, server.Name)
+		}
+	}
+	if got := servers.getByIDCalls.Load(); got != 0 {
+		t.Errorf("names of preloaded servers were looked up %d times, want 0", got)
+	}
+}
+
+func TestDatasource_mismatchedResourceTypeNotice(t *testing.T) {
+	ds := Datasource{
+		nameCacheServer:       newTestNameCache(10),
+		nameCacheLoadBalancer: NewNameCache[hcloud.LoadBalancer](nil, func(ctx context.Context, id int64) (*hcloud.LoadBalancer, error) { return nil, nil }, loadBalancerIdentifier, 10),
+	}
+	ds.nameCacheLoadBalancer.Insert(&hcloud.LoadBalancer{ID: 5, Name: "lb"})
+
+	qm := QueryModel{ResourceType: ResourceTypeServer, SelectBy: SelectByID}
+	notice := ds.mismatchedResourceTypeNotice(context.Background(), qm, []int64{7, 5})
+	if want := "The IDs 5, 7 did not match any server. They were deleted, or they are the IDs of a different resource type."; notice.Text != want {
+		t.Errorf("notice = %q, want %q", notice.Text, want)
+	}
+
+	qm.CheckOtherResourceType = true
+	notice = ds.mismatchedResourceTypeNotice(context.Background(), qm, []int64{7, 5})
+	if want := "The IDs 5 are of type load-balancer"; !strings.Contains(notice.Text, want) {
+		t.Errorf("notice = %q, want it to contain %q", notice.Text, want)
+	}
+}
+
+func Test_emptyMetricsFrame(t *testing.T) {
+	meta := newSeriesMetadata(serverSeriesToDisplayName, serverSeriesToUnit, nil)
+	frame := emptyMetricsFrame(serverMetricsTypeSeries, []MetricsType{MetricsTypeServerCPU, MetricsTypeServerDiskIOPS}, meta)
+
+	if frame.Rows() != 0 {
+		t.Errorf("emptyMetricsFrame() returned %d rows, want 0", frame.Rows())
+	}
+
+	wantUnits := []struct{ name, unit string }{{"time", ""}, {"cpu", "percent"}, {"disk.0.iops.read", "iops"}, {"disk.0.iops.write", "iops"}}
+	if len(frame.Fields) != len(wantUnits) {
+		t.Fatalf("emptyMetricsFrame() returned %d fields, want %d", len(frame.Fields), len(wantUnits))
+	}
+	for i, want := range wantUnits {
+		field := frame.Fields[i]
+		if field.Name != want.name {
+			t.Errorf("field %d name = %q, want %q", i, field.Name, want.name)
+		}
+		if want.unit != "" && (field.Config == nil || field.Config.Unit != want.unit) {
+			t.Errorf("field %q config = %v, want unit %q", field.Name, field.Config, want.unit)
+		}
+	}
+
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 {
+		t.Errorf("emptyMetricsFrame() should explain that no resources matched, got %v", frame.Meta)
+	}
+}
+
+func Test_keepLastValues(t *testing.T) {
+	newFrame := func(values ...*float64) *data.Frame {
+		times := make([]time.Time, 0, len(values))
+		for i := range values {
+			times = append(times, time.Unix(int64(i)*60, 0))
+		}
+		return data.NewFrame("", data.NewField("time", nil, times), data.NewField("cpu", nil, values))
+	}
+
+	withTrailingNull := newFrame(hcloud.Ptr(1.0), hcloud.Ptr(2.0), nil)
+	allNull := newFrame(nil, nil)
+	if !keepLastValues([]*data.Frame{withTrailingNull, allNull}) {
+		t.Error("keepLastValues() = false, want true if any series has a value")
+	}
+	if rows := withTrailingNull.Rows(); rows != 1 {
+		t.Fatalf("keepLastValues() kept %d rows, want 1", rows)
+	}
+	if got := withTrailingNull.Fields[0].At(0).(time.Time); !got.Equal(time.Unix(60, 0)) {
+		t.Errorf("time = %v, want the time of the last non-null value", got)
+	}
+	if got := withTrailingNull.Fields[1].At(0).(*float64); got == nil || *got != 2 {
+		t.Errorf("value = %v, want 2", got)
+	}
+	if rows := allNull.Rows(); rows != 0 {
+		t.Errorf("keepLastValues() kept %d rows of a series without values, want 0", rows)
+	}
+
+	if keepLastValues([]*data.Frame{newFrame(nil)}) {
+		t.Error("keepLastValues() = true, want false if no series has a value")
+	}
+}
+
+func Test_dropIncompleteBuckets(t *testing.T) {
+	newFrame := func() *data.Frame {
+		return data.NewFrame("",
+			data.NewField("time", nil, []time.Time{time.Unix(0, 0), time.Unix(60, 0), time.Unix(120, 0)}),
+			data.NewField("cpu", nil, []*float64{hcloud.Ptr(1.0), hcloud.Ptr(2.0), hcloud.Ptr(0.5)}),
+		)
+	}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		maxBuckets int
+		wantRows   int
+	}{
+		{name: "last bucket incomplete", now: time.Unix(150, 0), maxBuckets: 1, wantRows: 2},
+		{name: "last bucket complete", now: time.Unix(180, 0), maxBuckets: 1, wantRows: 3},
+		{name: "only incomplete buckets are dropped", now: time.Unix(150, 0), maxBuckets: 2, wantRows: 2},
+		{name: "two incomplete buckets", now: time.Unix(90, 0), maxBuckets: 2, wantRows: 1},
+		{name: "disabled", now: time.Unix(150, 0), maxBuckets: 0, wantRows: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := newFrame()
+			dropIncompleteBuckets([]*data.Frame{frame}, 60, tt.now, tt.maxBuckets)
+
+			for _, field := range frame.Fields {
+				if field.Len() != tt.wantRows {
+					t.Errorf("field %q has %d rows, want %d", field.Name, field.Len(), tt.wantRows)
+				}
+			}
+		})
+	}
+
+	if got := (Options{TrailingBucketsToDrop: hcloud.Ptr(2)}).trailingBucketsToDrop(); got != 2 {
+		t.Errorf("trailingBucketsToDrop() = %d, want 2", got)
+	}
+	if got := (Options{}).trailingBucketsToDrop(); got != DefaultTrailingBucketsToDrop {
+		t.Errorf("trailingBucketsToDrop() = %d, want default %d", got, DefaultTrailingBucketsToDrop)
+	}
+}
+
+func Test_setFrameNames(t *testing.T) {
+	newFrame := func() *data.Frame {
+		return data.NewFrame("",
+			data.NewField("time", nil, []time.Time{}),
+			data.NewField("cpu", data.Labels{LabelID: "1", LabelName: "webserver", LabelSeriesName: "cpu"}, []*float64{}),
+		)
+	}
+
+	frames := []*data.Frame{newFrame(), data.NewFrame("")}
+	setFrameNames(frames, "")
+	if got := frames[0].Name; got != "webserver cpu" {
+		t.Errorf("default frame name = %q, want %q", got, "webserver cpu")
+	}
+	if got := frames[1].Name; got != "" {
+		t.Errorf("frame without fields should keep its name, got %q", got)
+	}
+
+	frames = []*data.Frame{newFrame()}
+	setFrameNames(frames, "{{ id }}/{{ series_name }}")
+	if got := frames[0].Name; got != "1/cpu" {
+		t.Errorf("frame name from template = %q, want %q", got, "1/cpu")
+	}
+}
+
+func Test_serverTrafficSeries(t *testing.T) {
+	tests := []struct {
+		name          string
+		server        *hcloud.Server
+		wantPercent   *float64
+		wantRemaining *float64
+	}{
+		{
+			name:          "within included traffic",
+			server:        &hcloud.Server{IncludedTraffic: 1000, OutgoingTraffic: 250},
+			wantPercent:   hcloud.Ptr(25.0),
+			wantRemaining: hcloud.Ptr(750.0),
+		},
+		{
+			name:          "overage",
+			server:        &hcloud.Server{IncludedTraffic: 1000, OutgoingTraffic: 1500},
+			wantPercent:   hcloud.Ptr(150.0),
+			wantRemaining: hcloud.Ptr(0.0),
+		},
+		{
+			name:   "no included traffic",
+			server: &hcloud.Server{OutgoingTraffic: 1500},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serverTrafficPercent.value(tt.server); !reflect.DeepEqual(got, tt.wantPercent) {
+				t.Errorf("percent = %v, want %v", got, tt.wantPercent)
+			}
+			if got := serverTrafficRemaining.value(tt.server); !reflect.DeepEqual(got, tt.wantRemaining) {
+				t.Errorf("remaining = %v, want %v", got, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func Test_setDecimals(t *testing.T) {
+	newFrame := func() *data.Frame {
+		return data.NewFrame("",
+			data.NewField("time", nil, []time.Time{}),
+			data.NewField("cpu", nil, []*float64{}).SetConfig(&data.FieldConfig{Unit: "percent"}),
+		)
+	}
+
+	frames := []*data.Frame{newFrame()}
+	setDecimals(frames, nil)
+	if got := frames[0].Fields[1].Config.Decimals; got != nil {
+		t.Errorf("decimals should be left to Grafana if unset, got %d", *got)
+	}
+
+	decimals := uint16(2)
+	frames = []*data.Frame{newFrame(), data.NewFrame("")}
+	setDecimals(frames, &decimals)
+	config := frames[0].Fields[1].Config
+	if config.Decimals == nil || *config.Decimals != 2 {
+		t.Errorf("decimals = %v, want 2", config.Decimals)
+	}
+	if config.Unit != "percent" {
+		t.Errorf("setDecimals() should keep the unit, got %q", config.Unit)
+	}
+
+	// Wide frames have a value field per series
+	wide := data.NewFrame("",
+		data.NewField("time", nil, []time.Time{}),
+		data.NewField("in", nil, []*float64{}),
+		data.NewField("out", nil, []*float64{}),
+	)
+	setDecimals([]*data.Frame{wide}, &decimals)
+	if got := wide.Fields[0].Config; got != nil {
+		t.Errorf("setDecimals() should not configure the time field, got %+v", got)
+	}
+	for _, field := range wide.Fields[1:] {
+		if field.Config == nil || field.Config.Decimals == nil || *field.Config.Decimals != 2 {
+			t.Errorf("decimals of field %q = %v, want 2", field.Name, field.Config)
+		}
+	}
+}
+
+func Test_newSeriesMetadata(t *testing.T) {
+	meta := newSeriesMetadata(serverSeriesToDisplayName, serverSeriesToUnit, map[string]SeriesOverride{
+		"cpu":                    {DisplayName: "CPU %"},
+		"network.0.bandwidth.in": {Unit: "bps"},
+		"bandwidth.in":           {DisplayName: "Load Balancer only"},
+	})
+
+	if got := meta.displayNames["cpu"]; got != "CPU %" {
+		t.Errorf("display name of cpu = %q, want %q", got, "CPU %")
+	}
+	if got := meta.units["cpu"]; got != "percent" {
+		t.Errorf("unit of cpu = %q, want built-in default %q", got, "percent")
+	}
+	if got := meta.units["network.0.bandwidth.in"]; got != "bps" {
+		t.Errorf("unit of network.0.bandwidth.in = %q, want %q", got, "bps")
+	}
+	if _, ok := meta.displayNames["bandwidth.in"]; ok {
+		t.Errorf("override for load balancer series should not be added to server series")
+	}
+	if got := serverSeriesToDisplayName["cpu"]; got != "Usage" {
+		t.Errorf("built-in display name of cpu was modified to %q", got)
+	}
+}
+
+func Test_seriesMetadata_withUnits(t *testing.T) {
+	meta := newSeriesMetadata(serverSeriesToDisplayName, serverSeriesToUnit, map[string]SeriesOverride{
+		"disk.0.iops.read": {Unit: "iops"},
+	})
+
+	got := meta.withUnits(map[string]string{"cpu": "short", "bandwidth.in": "bps"})
+
+	if unit := got.units["cpu"]; unit != "short" {
+		t.Errorf("unit of cpu = %q, want %q", unit, "short")
+	}
+	if unit := got.units["disk.0.iops.read"]; unit != "iops" {
+		t.Errorf("unit of disk.0.iops.read = %q, want series override %q", unit, "iops")
+	}
+	if _, ok := got.units["bandwidth.in"]; ok {
+		t.Errorf("override for load balancer series should not be added to server series")
+	}
+	if unit := meta.units["cpu"]; unit != "percent" {
+		t.Errorf("withUnits() modified the units of the data source to %q", unit)
+	}
+}
+
+func Test_seriesMetadata_forQuery(t *testing.T) {
+	meta := newSeriesMetadata(serverSeriesToDisplayName, serverSeriesToUnit, nil)
+
+	got := meta.forQuery(QueryModel{RawValues: true, UnitOverrides: map[string]string{"cpu": "short"}})
+	if unit := got.units["network.0.bandwidth.in"]; unit != "" {
+		t.Errorf("unit with rawValues = %q, want no unit", unit)
+	}
+	if unit := got.units["cpu"]; unit != "" {
+		t.Errorf("rawValues should take precedence over unitOverrides, got unit %q", unit)
+	}
+	if unit := meta.units["network.0.bandwidth.in"]; unit != "binBps" {
+		t.Errorf("forQuery() modified the units of the data source to %q", unit)
+	}
+
+	if got := meta.forQuery(QueryModel{}); got.units["cpu"] != "percent" {
+		t.Errorf("unit without options = %q, want %q", got.units["cpu"], "percent")
+	}
+}
+
+func Test_limitStep(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		duration        time.Duration
+		step            int
+		maxPoints       int
+		want            int
+		wantDownsampled bool
+	}{
+		{name: "below limit", duration: time.Hour, step: 1, maxPoints: 10000, want: 1},
+		{name: "exactly at limit", duration: 10000 * time.Second, step: 1, maxPoints: 10000, want: 1},
+		{name: "above limit", duration: 365 * 24 * time.Hour, step: 1, maxPoints: 10000, want: 3154, wantDownsampled: true},
+		{name: "disabled", duration: 365 * 24 * time.Hour, step: 1, maxPoints: 0, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeRange := backend.TimeRange{From: from, To: from.Add(tt.duration)}
+			got, downsampled := limitStep(timeRange, tt.step, tt.maxPoints)
+			if got != tt.want || downsampled != tt.wantDownsampled {
+				t.Errorf("limitStep() = %d, %v, want %d, %v", got, downsampled, tt.want, tt.wantDownsampled)
+			}
+		})
+	}
+}
+
+func TestOptions_maxPointsPerSeries(t *testing.T) {
+	if got := (Options{}).maxPointsPerSeries(); got != DefaultMaxPointsPerSeries {
+		t.Errorf("maxPointsPerSeries() without the option = %d, want the default %d", got, DefaultMaxPointsPerSeries)
+	}
+	if got := (Options{MaxPointsPerSeries: hcloud.Ptr(0)}).maxPointsPerSeries(); got != 0 {
+		t.Errorf("maxPointsPerSeries() = %d, want 0 to disable the limit", got)
+	}
+	if err := (Options{MaxPointsPerSeries: hcloud.Ptr(-1)}).Validate(); err == nil {
+		t.Errorf("Validate() should reject a negative max points per series")
+	}
+}
+
+func Test_seriesDirection(t *testing.T) {
+	tests := []struct {
+		seriesName string
+		want       string
+	}{
+		{seriesName: "cpu", want: ""},
+		{seriesName: "open_connections", want: ""},
+		{seriesName: "network.0.bandwidth.in", want: "in"},
+		{seriesName: "network.0.pps.out", want: "out"},
+		{seriesName: "disk.0.iops.read", want: "read"},
+		{seriesName: "disk.0.bandwidth.write", want: "write"},
+		{seriesName: "bandwidth.in", want: "in"},
+		{seriesName: "network.0.bandwidth", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.seriesName, func(t *testing.T) {
+			if got := seriesDirection(tt.seriesName); got != tt.want {
+				t.Errorf("seriesDirection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_sortFrames(t *testing.T) {
 	frame := func(id string, seriesName string) *data.Frame {
 		return &data.Frame{Fields: []*data.Field{{Labels: data.Labels{
@@ -120,6 +1477,11 @@ func Test_sortFrames(t *testing.T) {
 			input:    []*data.Frame{frame("Foo", "B"), frame("Foo", "A")},
 			expected: []*data.Frame{frame("Foo", "A"), frame("Foo", "B")},
 		},
+		{
+			name:     "Numeric IDs",
+			input:    []*data.Frame{frame("10", "A"), frame("9", "B"), frame("9", "A")},
+			expected: []*data.Frame{frame("9", "A"), frame("9", "B"), frame("10", "A")},
+		},
 		{
 			name:     "Two Frames by Series Name Equal",
 			input:    []*data.Frame{frame("Foo", "A"), frame("Foo", "A")},
@@ -136,3 +1498,175 @@ func Test_sortFrames(t *testing.T) {
 		})
 	}
 }
+
+func Test_serverMetricsToFrames_order(t *testing.T) {
+	value := []hcloud.ServerMetricsValue{{Timestamp: 1, Value: "1"}}
+	metrics := &hcloud.ServerMetrics{
+		TimeSeries: map[string][]hcloud.ServerMetricsValue{
+			"network.0.bandwidth.out": value,
+			"disk.0.iops.write":       value,
+			"network.0.bandwidth.in":  value,
+			"cpu":                     value,
+			"disk.0.iops.read":        value,
+		},
+	}
+	want := []string{"cpu", "disk.0.iops.read", "disk.0.iops.write", "network.0.bandwidth.in", "network.0.bandwidth.out"}
+
+	// Map iteration order is random, repeat to make an unstable order visible
+	for range 10 {
+		frames := serverMetricsToFrames(1, "webserver", "", newSeriesMetadata(serverSeriesToDisplayName, serverSeriesToUnit, nil), metrics)
+
+		got := make([]string, 0, len(frames))
+		for _, frame := range frames {
+			got = append(got, frame.Fields[1].Labels[LabelSeriesName])
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("serverMetricsToFrames() series order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDatasource_addProjectLabel(t *testing.T) {
+	d := &Datasource{project: projectLabel(Options{IncludeProjectLabel: true}, "Production")}
+	frames := serverMetricsToFrames(1, "webserver", "{{ project }}/{{ name }}", newSeriesMetadata(serverSeriesToDisplayName, serverSeriesToUnit, nil), &hcloud.ServerMetrics{
+		TimeSeries: map[string][]hcloud.ServerMetricsValue{"cpu": {{Timestamp: 1, Value: "1"}}},
+	})
+
+	d.addProjectLabel(frames, "{{ project }}/{{ name }}")
+
+	valuesField := frames[0].Fields[1]
+	if got := valuesField.Labels[LabelProject]; got != "Production" {
+		t.Errorf("project label = %q, want %q", got, "Production")
+	}
+	if got := valuesField.Config.DisplayNameFromDS; got != "Production/webserver" {
+		t.Errorf("display name = %q, want %q", got, "Production/webserver")
+	}
+
+	if got := projectLabel(Options{IncludeProjectLabel: true, ProjectName: "prod"}, "Production"); got != "prod" {
+		t.Errorf("projectLabel() = %q, want the configured project name", got)
+	}
+	if got := projectLabel(Options{}, "Production"); got != "" {
+		t.Errorf("projectLabel() = %q, want empty if disabled", got)
+	}
+}
+
+func Test_filterLoadBalancerMetrics(t *testing.T) {
+	metrics := filterLoadBalancerMetrics(&hcloud.LoadBalancerMetrics{
+		TimeSeries: map[string][]hcloud.LoadBalancerMetricsValue{
+			"open_connections":       {{Timestamp: 1, Value: "1"}},
+			"connections_per_second": {{Timestamp: 1, Value: "1"}},
+		},
+	}, []MetricsType{MetricsTypeLoadBalancerOpenConnections, MetricsTypeLoadBalancerRequestsPerSecond})
+
+	if _, ok := metrics.TimeSeries["open_connections"]; !ok {
+		t.Errorf("filterLoadBalancerMetrics() should keep the requested series")
+	}
+	if _, ok := metrics.TimeSeries["connections_per_second"]; ok {
+		t.Errorf("filterLoadBalancerMetrics() should remove series that were not requested")
+	}
+	if _, ok := metrics.TimeSeries["requests_per_second"]; ok {
+		t.Errorf("filterLoadBalancerMetrics() should not add missing series")
+	}
+}
+
+func Test_missingNetworkNotices(t *testing.T) {
+	metrics := filterServerMetrics(&hcloud.ServerMetrics{
+		TimeSeries: map[string][]hcloud.ServerMetricsValue{
+			"cpu": {{Timestamp: 1, Value: "1"}},
+		},
+	}, []MetricsType{MetricsTypeServerCPU, MetricsTypeServerNetworkBandwidth})
+
+	if _, ok := metrics.TimeSeries["network.0.bandwidth.in"]; ok {
+		t.Errorf("filterServerMetrics() should not add missing series")
+	}
+
+	notices := missingNetworkNotices(1, "private-only", metrics, []MetricsType{MetricsTypeServerCPU, MetricsTypeServerNetworkBandwidth})
+	if len(notices) != 1 {
+		t.Fatalf("missingNetworkNotices() returned %d notices, want 1", len(notices))
+	}
+	if want := "Server private-only has no public network interface, no network-bandwidth metrics available."; notices[0].Text != want {
+		t.Errorf("missingNetworkNotices() text = %q, want %q", notices[0].Text, want)
+	}
+}
+
+func Test_compareSeries(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "network.0.bandwidth.in", b: "network.0.bandwidth.out", want: -1},
+		{a: "disk.0.bandwidth.write", b: "disk.0.bandwidth.read", want: 1},
+		{a: "cpu", b: "disk.0.iops.read", want: -1},
+		{a: "bandwidth.out", b: "bandwidth.in", want: 1},
+		{a: "unknown", b: "cpu", want: 1},
+	}
+	for _, tt := range tests {
+		if got := compareSeries(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareSeries(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func Test_serverMetricsToFrames_parseFailures(t *testing.T) {
+	metrics := &hcloud.ServerMetrics{
+		TimeSeries: map[string][]hcloud.ServerMetricsValue{
+			"cpu": {
+				{Timestamp: 1, Value: "1.5"},
+				{Timestamp: 2, Value: "invalid"},
+				{Timestamp: 3, Value: "also invalid"},
+			},
+		},
+	}
+
+	frames := serverMetricsToFrames(1, "webserver", "", newSeriesMetadata(serverSeriesToDisplayName, serverSeriesToUnit, nil), metrics)
+	if len(frames) != 1 {
+		t.Fatalf("serverMetricsToFrames() returned %d frames, want 1", len(frames))
+	}
+
+	if frames[0].Meta == nil || len(frames[0].Meta.Notices) != 1 {
+		t.Fatalf("serverMetricsToFrames() should return exactly one notice, got %v", frames[0].Meta)
+	}
+
+	values := frames[0].Fields[1]
+	if v, ok := values.At(0).(*float64); !ok || v == nil || *v != 1.5 {
+		t.Errorf("value at index 0 = %v, want 1.5", values.At(0))
+	}
+	if v, ok := values.At(1).(*float64); !ok || v != nil {
+		t.Errorf("value at index 1 = %v, want nil", values.At(1))
+	}
+
+	want := `Failed to parse 2 of 3 values for series cpu, first invalid value was "invalid" at timestamp 2.000000: strconv.ParseFloat: parsing "invalid": invalid syntax`
+	if got := frames[0].Meta.Notices[0].Text; got != want {
+		t.Errorf("notice text = %q, want %q", got, want)
+	}
+}
+
+func Test_metricsToFrames_legendParity(t *testing.T) {
+	serverFrames := serverMetricsToFrames(1, "webserver", "", newSeriesMetadata(serverSeriesToDisplayName, serverSeriesToUnit, nil), &hcloud.ServerMetrics{
+		TimeSeries: map[string][]hcloud.ServerMetricsValue{
+			"network.0.bandwidth.in": {{Timestamp: 1, Value: "1"}},
+		},
+	})
+	loadBalancerFrames := loadBalancerMetricsToFrames(1, "webserver", "", newSeriesMetadata(loadBalancerSeriesToDisplayName, loadBalancerSeriesToUnit, nil), &hcloud.LoadBalancerMetrics{
+		TimeSeries: map[string][]hcloud.LoadBalancerMetricsValue{
+			"bandwidth.in": {{Timestamp: 1, Value: "1"}},
+		},
+	})
+
+	if len(serverFrames) != 1 || len(loadBalancerFrames) != 1 {
+		t.Fatalf("expected exactly one frame each, got %d and %d", len(serverFrames), len(loadBalancerFrames))
+	}
+
+	serverField := serverFrames[0].Fields[1]
+	loadBalancerField := loadBalancerFrames[0].Fields[1]
+
+	if serverField.Config.DisplayNameFromDS != loadBalancerField.Config.DisplayNameFromDS {
+		t.Errorf("legend differs between server (%q) and load balancer (%q)", serverField.Config.DisplayNameFromDS, loadBalancerField.Config.DisplayNameFromDS)
+	}
+
+	for _, label := range []string{LabelID, LabelName, LabelSeriesDisplayName} {
+		if serverField.Labels[label] != loadBalancerField.Labels[label] {
+			t.Errorf("label %q differs between server (%q) and load balancer (%q)", label, serverField.Labels[label], loadBalancerField.Labels[label])
+		}
+	}
+}
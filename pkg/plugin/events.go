@@ -0,0 +1,217 @@
+package plugin
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apricote/grafana-hcloud-datasource/pkg/set"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/sourcegraph/conc/iter"
+)
+
+func (qm QueryModel) validateEvents() error {
+	if err := qm.validate(); err != nil {
+		return err
+	}
+
+	if len(qm.ResourceTypes) > 0 {
+		return errors.New("resourceTypes is only supported by resource list queries, use resourceType instead")
+	}
+	if _, ok := resourceActionsPath[qm.ResourceType]; !ok {
+		return fmt.Errorf("events are only supported for resourceType %s and %s, got %q", ResourceTypeServer, ResourceTypeLoadBalancer, qm.ResourceType)
+	}
+
+	return nil
+}
+
+// queryEvents returns the actions that were started in the time range as a table, newest first. Unlike
+// [Datasource.queryActions], which marks graphs, every action is its own row with its progress and all of its
+// resources.
+//
+// Without a selection, the actions of all resources of the type are listed with a single (paginated) request. This
+// is cheap, so it does not depend on [Options.EmptySelectionMeansAll]. The filters of the query still apply, see
+// [Datasource.filterActions]. Otherwise, the resources are selected like in metrics queries, and every selected resource
+// requires its own request.
+func (d *Datasource) queryEvents(ctx context.Context, query backend.DataQuery) backend.DataResponse {
+	var qm QueryModel
+	err := json.Unmarshal(query.JSON, &qm)
+	if err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("json unmarshal: %v", err.Error()))
+	}
+
+	if err := qm.validateEvents(); err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("invalid query: %v", err))
+	}
+
+	var actions []*hcloud.Action
+	if qm.SelectBy == "" || qm.hasEmptySelection() {
+		actions, err = d.listActions(ctx, "/"+resourceActionsPath[qm.ResourceType]+"/actions", query.TimeRange)
+		if err == nil {
+			actions, err = d.filterActions(ctx, qm, actions)
+		}
+	} else {
+		actions, err = d.getSelectedResourcesActions(ctx, qm, query.TimeRange)
+	}
+	if err != nil {
+		err = NicerErrorMessages(err)
+		return backend.ErrDataResponseWithSource(backend.StatusInternal, backend.ErrorSourceDownstream, fmt.Sprintf("error getting actions: %v", err.Error()))
+	}
+
+	// Actions of multiple selected resources are returned once per resource
+	slices.SortStableFunc(actions, func(a, b *hcloud.Action) int { return cmp.Compare(a.ID, b.ID) })
+	actions = slices.CompactFunc(actions, func(a, b *hcloud.Action) bool { return a.ID == b.ID })
+
+	var ids []int64
+	for _, action := range actions {
+		for _, resource := range action.Resources {
+			if resource.Type == actionResourceType(qm.ResourceType) {
+				ids = append(ids, resource.ID)
+			}
+		}
+	}
+	d.warmNames(ctx, qm.ResourceType, ids)
+
+	return backend.DataResponse{Frames: data.Frames{eventsToFrame(actions, func(resource *hcloud.ActionResource) string {
+		return d.actionResourceName(ctx, qm.ResourceType, resource)
+	})}}
+}
+
+// getSelectedResourcesActions returns the actions of all resources selected by the query.
+func (d *Datasource) getSelectedResourcesActions(ctx context.Context, qm QueryModel, timeRange backend.TimeRange) ([]*hcloud.Action, error) {
+	resourceIDs, err := d.GetResourceIDs(ctx, qm)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		actions []*hcloud.Action
+		err     error
+	}
+	results := iter.Map(resourceIDs, func(id *int64) result {
+		actions, err := d.getResourceActions(ctx, qm.ResourceType, *id, timeRange)
+		return result{actions: actions, err: err}
+	})
+
+	var actions []*hcloud.Action
+	for _, r := range results {
+		if r.err != nil {
+			return nil, r.err
+		}
+		actions = append(actions, r.actions...)
+	}
+	return actions, nil
+}
+
+// filterActions applies the filters of the query ([QueryModel.StatusSelector], [QueryModel.ExcludeLabelSelectors] and
+// [QueryModel.NameFilter]) to actions that were listed without a selection. An action is kept if any of its resources
+// of the queried type passes the filters, so the filters only require the resources that actually have actions.
+func (d *Datasource) filterActions(ctx context.Context, qm QueryModel, actions []*hcloud.Action) ([]*hcloud.Action, error) {
+	if len(qm.StatusSelector) == 0 && len(qm.ExcludeLabelSelectors) == 0 && qm.NameFilter == "" {
+		return actions, nil
+	}
+
+	resourceType := actionResourceType(qm.ResourceType)
+	ids := set.New[int64]()
+	for _, action := range actions {
+		for _, resource := range action.Resources {
+			if resource.Type == resourceType {
+				ids.Insert(resource.ID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return actions[:0], nil
+	}
+
+	// The resources of the actions are the selection, so they are filtered exactly like explicitly selected resources
+	qm.SelectBy = SelectByID
+	qm.ResourceIDs = slices.Sorted(maps.Keys(ids))
+	qm.SavedSelector = ""
+	resourceIDs, _, err := d.selectResourceIDs(ctx, qm)
+	if err != nil {
+		return nil, err
+	}
+	remaining := set.From(resourceIDs...)
+
+	return slices.DeleteFunc(actions, func(action *hcloud.Action) bool {
+		return !slices.ContainsFunc(action.Resources, func(resource *hcloud.ActionResource) bool {
+			return resource.Type == resourceType && remaining.Has(resource.ID)
+		})
+	}), nil
+}
+
+// actionResourceType returns the type of the resource in [hcloud.ActionResource], which differs from [ResourceType].
+func actionResourceType(resourceType ResourceType) hcloud.ActionResourceType {
+	if resourceType == ResourceTypeLoadBalancer {
+		return hcloud.ActionResourceType("load_balancer")
+	}
+	return hcloud.ActionResourceTypeServer
+}
+
+// actionResourceName describes a resource of an action, ie. "server web-1". Names are only looked up for resources of
+// the queried type, other resources (ie. the image of a rebuild) are shown with their ID.
+func (d *Datasource) actionResourceName(ctx context.Context, resourceType ResourceType, resource *hcloud.ActionResource) string {
+	if resource.Type == actionResourceType(resourceType) {
+		if name, err := d.resourceName(ctx, resourceType, resource.ID); err == nil && name != "" {
+			return fmt.Sprintf("%s %s", resource.Type, name)
+		}
+	}
+	return fmt.Sprintf("%s %d", resource.Type, resource.ID)
+}
+
+// eventsToFrame returns a table with one row per action, newest first. Running actions have no finished time.
+func eventsToFrame(actions []*hcloud.Action, resourceName func(resource *hcloud.ActionResource) string) *data.Frame {
+	actions = slices.Clone(actions)
+	slices.SortStableFunc(actions, func(a, b *hcloud.Action) int { return b.Started.Compare(a.Started) })
+
+	started := make([]time.Time, 0, len(actions))
+	finished := make([]*time.Time, 0, len(actions))
+	commands := make([]string, 0, len(actions))
+	statuses := make([]string, 0, len(actions))
+	progress := make([]int64, 0, len(actions))
+	resources := make([]string, 0, len(actions))
+	errorMessages := make([]string, 0, len(actions))
+
+	for _, action := range actions {
+		started = append(started, action.Started)
+		if action.Finished.IsZero() {
+			finished = append(finished, nil)
+		} else {
+			finished = append(finished, &action.Finished)
+		}
+		commands = append(commands, action.Command)
+		statuses = append(statuses, string(action.Status))
+		progress = append(progress, int64(action.Progress))
+
+		names := make([]string, 0, len(action.Resources))
+		for _, resource := range action.Resources {
+			names = append(names, resourceName(resource))
+		}
+		resources = append(resources, strings.Join(names, ", "))
+
+		errorMessage := ""
+		if err := action.Error(); err != nil {
+			errorMessage = err.Error()
+		}
+		errorMessages = append(errorMessages, errorMessage)
+	}
+
+	return data.NewFrame("events",
+		data.NewField("time", nil, started),
+		data.NewField("finished", nil, finished),
+		data.NewField("command", nil, commands),
+		data.NewField("status", nil, statuses),
+		data.NewField("progress", nil, progress).SetConfig(&data.FieldConfig{Unit: "percent"}),
+		data.NewField("resources", nil, resources),
+		data.NewField("error", nil, errorMessages),
+	)
+}
@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func Test_eventsToFrame(t *testing.T) {
+	t1 := time.Unix(60, 0)
+	t2 := time.Unix(120, 0)
+
+	actions := []*hcloud.Action{
+		{
+			ID: 1, Command: "create_server", Status: hcloud.ActionStatusSuccess, Progress: 100, Started: t1, Finished: t1.Add(time.Minute),
+			Resources: []*hcloud.ActionResource{{ID: 10, Type: hcloud.ActionResourceTypeServer}, {ID: 42, Type: hcloud.ActionResourceTypeImage}},
+		},
+		{
+			ID: 2, Command: "reboot_server", Status: hcloud.ActionStatusRunning, Progress: 50, Started: t2,
+			Resources: []*hcloud.ActionResource{{ID: 10, Type: hcloud.ActionResourceTypeServer}},
+		},
+	}
+	resourceName := func(resource *hcloud.ActionResource) string {
+		return fmt.Sprintf("%s %d", resource.Type, resource.ID)
+	}
+
+	frame := eventsToFrame(actions, resourceName)
+	if frame.Rows() != 2 {
+		t.Fatalf("eventsToFrame() returned %d rows, want 2", frame.Rows())
+	}
+
+	// Newest first
+	if got := frame.Fields[0].At(0).(time.Time); !got.Equal(t2) {
+		t.Errorf("first row time = %v, want %v", got, t2)
+	}
+	if got := frame.Fields[1].At(0).(*time.Time); got != nil {
+		t.Errorf("running action should have no finished time, got %v", got)
+	}
+	if got := frame.Fields[4].At(0).(int64); got != 50 {
+		t.Errorf("progress = %d, want 50", got)
+	}
+	if got := frame.Fields[5].At(1).(string); got != "server 10, image 42" {
+		t.Errorf("resources = %q, want %q", got, "server 10, image 42")
+	}
+	if got := frame.Fields[1].At(1).(*time.Time); got == nil || !got.Equal(t1.Add(time.Minute)) {
+		t.Errorf("finished = %v, want %v", got, t1.Add(time.Minute))
+	}
+}
+
+func TestQueryModel_validateEvents(t *testing.T) {
+	// The selection is optional
+	valid := QueryModel{ResourceType: ResourceTypeLoadBalancer}
+	if err := valid.validateEvents(); err != nil {
+		t.Errorf("validateEvents() error = %v, want nil", err)
+	}
+
+	invalid := QueryModel{ResourceType: ResourceTypePlacementGroup}
+	if err := invalid.validateEvents(); err == nil {
+		t.Error("validateEvents() should fail for resource types without actions")
+	}
+}
+
+func TestDatasource_filterActions(t *testing.T) {
+	newAction := func(id int64, resources ...*hcloud.ActionResource) *hcloud.Action {
+		return &hcloud.Action{ID: id, Resources: resources}
+	}
+	server := func(id int64) *hcloud.ActionResource {
+		return &hcloud.ActionResource{ID: id, Type: hcloud.ActionResourceTypeServer}
+	}
+	actionIDs := func(actions []*hcloud.Action) []int64 {
+		ids := make([]int64, 0, len(actions))
+		for _, action := range actions {
+			ids = append(ids, action.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name string
+		qm   QueryModel
+		want []int64
+	}{
+		{name: "no filters", qm: QueryModel{}, want: []int64{1, 2, 3, 4}},
+		{name: "name filter", qm: QueryModel{NameFilter: `^web-\d`}, want: []int64{1}},
+		// Deleted servers have no labels, so they are not excluded
+		{name: "exclude label selectors", qm: QueryModel{ExcludeLabelSelectors: []string{"env=prod"}}, want: []int64{2, 3}},
+		{name: "status selector", qm: QueryModel{StatusSelector: []hcloud.ServerStatus{hcloud.ServerStatusOff}}, want: []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDatasource(newFakeServers())
+			actions := []*hcloud.Action{
+				newAction(1, server(1), &hcloud.ActionResource{ID: 42, Type: hcloud.ActionResourceTypeImage}),
+				newAction(2, server(3)),
+				// Server 4 was deleted
+				newAction(3, server(4)),
+				newAction(4, &hcloud.ActionResource{ID: 42, Type: hcloud.ActionResourceTypeImage}),
+			}
+
+			tt.qm.ResourceType = ResourceTypeServer
+			got, err := d.filterActions(context.Background(), tt.qm, actions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := actionIDs(got); !slices.Equal(ids, tt.want) {
+				t.Errorf("filterActions() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
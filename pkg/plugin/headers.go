@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// reservedHeaders are set by the API client and must not be replaced by [Options.CustomHeaders]. The API token is
+// sent in the Authorization header, and the user agent can be extended with [Options.AppIdentifier].
+var reservedHeaders = []string{"Authorization", "User-Agent"}
+
+// validateCustomHeaders returns an error if a header name or value of [Options.CustomHeaders] is invalid.
+func validateCustomHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if name == "" || strings.ContainsFunc(name, func(r rune) bool { return r == ':' || unicode.IsSpace(r) || unicode.IsControl(r) }) {
+			return fmt.Errorf("invalid custom header name %q", name)
+		}
+		for _, reserved := range reservedHeaders {
+			if http.CanonicalHeaderKey(name) == reserved {
+				return fmt.Errorf("custom header %q is set by the data source and can not be replaced", name)
+			}
+		}
+		if strings.ContainsFunc(value, unicode.IsControl) {
+			return fmt.Errorf("value of custom header %q must not contain control characters", name)
+		}
+	}
+	return nil
+}
+
+// headerTransport adds headers to every request before it is passed to next.
+type headerTransport struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func newHeaderTransport(headers map[string]string, next http.RoundTripper) http.RoundTripper {
+	h := make(http.Header, len(headers))
+	for name, value := range headers {
+		h.Set(name, value)
+	}
+	return &headerTransport{headers: h, next: next}
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(req)
+}
+
+// sensitiveHeaderParts are parts of header names whose values are credentials, ie. "Proxy-Authorization" or
+// "X-Api-Key".
+var sensitiveHeaderParts = []string{"auth", "cookie", "key", "secret", "token", "password", "session"}
+
+// redactHeaders returns a copy of the headers for logging, with the values of sensitive headers replaced.
+func redactHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		lowerName := strings.ToLower(name)
+		for _, part := range sensitiveHeaderParts {
+			if strings.Contains(lowerName, part) {
+				value = "REDACTED"
+				break
+			}
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_headerTransport(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newHeaderTransport(map[string]string{"x-request-source": "grafana"}, nil)}
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := received.Get("X-Request-Source"); got != "grafana" {
+		t.Errorf("X-Request-Source = %q, want %q", got, "grafana")
+	}
+	if req.Header.Get("X-Request-Source") != "" {
+		t.Error("headerTransport modified the original request")
+	}
+}
+
+func Test_validateCustomHeaders(t *testing.T) {
+	tests := []struct {
+		headers map[string]string
+		wantErr bool
+	}{
+		{headers: map[string]string{"Proxy-Authorization": "Basic abc"}},
+		{headers: map[string]string{"authorization": "Bearer abc"}, wantErr: true},
+		{headers: map[string]string{"X Trace": "1"}, wantErr: true},
+		{headers: map[string]string{"X-Trace": "1\r\nX-Other: 2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateCustomHeaders(tt.headers); (err != nil) != tt.wantErr {
+			t.Errorf("validateCustomHeaders(%v) error = %v, wantErr %v", tt.headers, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_redactHeaders(t *testing.T) {
+	got := redactHeaders(map[string]string{"Proxy-Authorization": "Basic abc", "X-Api-Key": "secret", "X-Trace": "1"})
+	want := map[string]string{"Proxy-Authorization": "REDACTED", "X-Api-Key": "REDACTED", "X-Trace": "1"}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("redactHeaders()[%q] = %q, want %q", name, got[name], value)
+		}
+	}
+}
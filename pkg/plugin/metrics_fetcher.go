@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sourcegraph/conc/iter"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// MetricsFetcher sends the API requests for the [QueryRunner].
+//
+// The Hetzner Cloud API only has per-resource metrics endpoints, so [singleResourceFetcher] sends one request per
+// resource. If the API ever gets an endpoint for the metrics of multiple resources, [bulkFetcher] can be used
+// instead (see [NewBulkQueryRunner]), without changing the buffering in the QueryRunner.
+type MetricsFetcher[M HCloudMetrics] interface {
+	// FetchMetrics requests the metrics of all ids with the same options. The result has an entry for every id, with
+	// either the metrics or the error of that resource.
+	FetchMetrics(ctx context.Context, ids []int64, opts RequestOpts) map[int64]FetchResult[M]
+}
+
+type FetchResult[M HCloudMetrics] struct {
+	Metrics *M
+	Err     error
+
+	// Call identifies the API request within one call to [MetricsFetcher.FetchMetrics]. Results that were returned
+	// by the same API request have the same Call.
+	Call int
+}
+
+// BulkAPIRequestFn requests the metrics of multiple resources in a single API request. Resources that are missing in
+// the returned map are reported as not found.
+type BulkAPIRequestFn[M HCloudMetrics] func(ctx context.Context, ids []int64, opts RequestOpts) (map[int64]*M, error)
+
+// singleResourceFetcher adapts an [APIRequestFn] to [MetricsFetcher] by sending one request per resource in parallel.
+type singleResourceFetcher[M HCloudMetrics] struct {
+	requestFn APIRequestFn[M]
+
+	// timeout limits the duration of every single API request, so one slow request does not stall all
+	// requests that are waiting for the same buffer flush.
+	timeout time.Duration
+	limiter requestLimiter
+}
+
+func (f singleResourceFetcher[M]) FetchMetrics(ctx context.Context, ids []int64, opts RequestOpts) map[int64]FetchResult[M] {
+	results := iter.Map(ids, func(id *int64) FetchResult[M] {
+		if err := f.limiter.acquire(ctx); err != nil {
+			return FetchResult[M]{Err: err}
+		}
+		defer f.limiter.release()
+
+		ctx, cancel := context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+
+		metrics, err := f.requestFn(ctx, *id, opts)
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("request for metrics of resource %d timed out after %s: %w", *id, f.timeout, err)
+		}
+		return FetchResult[M]{Metrics: metrics, Err: err}
+	})
+
+	resultsByID := make(map[int64]FetchResult[M], len(ids))
+	for i, id := range ids {
+		result := results[i]
+		result.Call = i
+		resultsByID[id] = result
+	}
+	return resultsByID
+}
+
+// bulkFetcher adapts a [BulkAPIRequestFn] to [MetricsFetcher]. All resources share the result of the single request.
+type bulkFetcher[M HCloudMetrics] struct {
+	requestFn BulkAPIRequestFn[M]
+	timeout   time.Duration
+	limiter   requestLimiter
+}
+
+func (f bulkFetcher[M]) FetchMetrics(ctx context.Context, ids []int64, opts RequestOpts) map[int64]FetchResult[M] {
+	metrics, err := f.request(ctx, ids, opts)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("request for metrics of %d resources timed out after %s: %w", len(ids), f.timeout, err)
+	}
+
+	resultsByID := make(map[int64]FetchResult[M], len(ids))
+	for _, id := range ids {
+		switch resourceMetrics, ok := metrics[id]; {
+		case err != nil:
+			resultsByID[id] = FetchResult[M]{Err: err}
+		case !ok || resourceMetrics == nil:
+			resultsByID[id] = FetchResult[M]{Err: hcloud.Error{
+				Code:    hcloud.ErrorCodeNotFound,
+				Message: fmt.Sprintf("resource %d not found", id),
+			}}
+		default:
+			resultsByID[id] = FetchResult[M]{Metrics: resourceMetrics}
+		}
+	}
+	return resultsByID
+}
+
+func (f bulkFetcher[M]) request(ctx context.Context, ids []int64, opts RequestOpts) (map[int64]*M, error) {
+	if err := f.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer f.limiter.release()
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	return f.requestFn(ctx, ids, opts)
+}
+
+// requestLimiter limits the number of API requests that are sent in parallel by all fetches of a [QueryRunner]. A nil
+// requestLimiter does not limit the requests.
+type requestLimiter chan struct{}
+
+func newRequestLimiter(maxConcurrentRequests int) requestLimiter {
+	if maxConcurrentRequests <= 0 {
+		return nil
+	}
+	return make(requestLimiter, maxConcurrentRequests)
+}
+
+// acquire blocks until another request may be sent or ctx is done.
+func (l requestLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release must be called once for every successful [requestLimiter.acquire] after the request is done.
+func (l requestLimiter) release() {
+	if l != nil {
+		<-l
+	}
+}
@@ -1,11 +1,19 @@
 package plugin
 
 import (
+	"container/list"
 	"context"
+	"fmt"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// EmptyNameRetryBackoff is the duration after which an empty cached name is looked up again. Resources can have an
+// empty name for a short time, ie. while they are being created, this should not stick forever.
+const EmptyNameRetryBackoff = 10 * time.Second
+
 type HCloudResource interface {
 	hcloud.Server | hcloud.LoadBalancer
 }
@@ -13,43 +21,137 @@ type HCloudResource interface {
 type GetResourceFn[R HCloudResource] func(ctx context.Context, id int64) (*R, error)
 type IdentifierFn[R HCloudResource] func(resource *R) (int64, string)
 
-func NewNameCache[R HCloudResource](client *hcloud.Client, getFn GetResourceFn[R], identifierFn IdentifierFn[R]) *NameCache[R] {
+// NewNameCache creates a new NameCache that holds at most maxEntries names. If maxEntries is not positive,
+// [DefaultNameCacheSize] is used.
+func NewNameCache[R HCloudResource](client *hcloud.Client, getFn GetResourceFn[R], identifierFn IdentifierFn[R], maxEntries int) *NameCache[R] {
+	if maxEntries <= 0 {
+		maxEntries = DefaultNameCacheSize
+	}
+
 	return &NameCache[R]{
 		client:       client,
 		getFn:        getFn,
 		identifierFn: identifierFn,
 
-		cache: map[int64]string{},
+		maxEntries: maxEntries,
+		cache:      map[int64]*list.Element{},
+		recency:    list.New(),
+
+		now: time.Now,
 	}
 }
 
-// NameCache is a cache for resource names. It is used to avoid sending unnecessary API requests. Right now there is no
-// expiry for entries, so if names are changed this is not reflected in queries.
+// NameCache is a cache for resource names. It is used to avoid sending unnecessary API requests. By default, entries do
+// not expire, so if names are changed this is not reflected in queries until the cache is refreshed. With
+// [NameCache.SetTTL], names are looked up again once they are older than the TTL.
+//
+// The cache also keeps the last fetched resource of every entry, so queries that need more than the name (ie. the
+// server type) can reuse recent API responses with [NameCache.Resources].
+//
+// The cache holds at most maxEntries names. When it is full, the least recently used entry is evicted.
 type NameCache[R HCloudResource] struct {
 	client       *hcloud.Client
 	getFn        GetResourceFn[R]
 	identifierFn IdentifierFn[R]
 
-	cache map[int64]string
+	maxEntries int
+	// ttl is the maximum age of cached names, zero disables the expiry
+	ttl   time.Duration
+	cache map[int64]*list.Element
+	// recency holds the cached entries, with the most recently used entry at the front
+	recency *list.List
 	sync.Mutex
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+
+	// hits, misses and errors count the calls to [NameCache.Get]. They are read without holding the mutex.
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+// NameCacheStats describes how effective the cache is. Every miss results in an API request.
+type NameCacheStats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Errors  uint64 `json:"errors"`
 }
 
-// Get will retrieve the name from the cache or query the API in case it is unknown.
+type nameCacheEntry[R HCloudResource] struct {
+	id       int64
+	name     string
+	resource *R
+
+	// retryAfter is only set for empty names, after this time the name is looked up again
+	retryAfter time.Time
+	// updatedAt is the time the name was last inserted or looked up, see [NameCache.SetTTL]
+	updatedAt time.Time
+}
+
+// valid returns true if the entry can be used without looking up the name again. Caller must hold the mutex.
+func (c *NameCache[R]) valid(entry *nameCacheEntry[R]) bool {
+	now := c.now()
+	if c.ttl > 0 && !now.Before(entry.updatedAt.Add(c.ttl)) {
+		return false
+	}
+	return entry.name != "" || now.Before(entry.retryAfter)
+}
+
+// Get will retrieve the name from the cache or query the API in case it is unknown. If the lookup of a name that expired
+// after the TTL fails, the expired name is returned, so a short API outage does not remove the names from all panels.
 func (c *NameCache[R]) Get(ctx context.Context, id int64) (string, error) {
 	c.Lock()
 	defer c.Unlock()
-	name, ok := c.cache[id]
-	if ok {
-		return name, nil
+	var stale string
+	if elem, ok := c.cache[id]; ok {
+		entry := elem.Value.(*nameCacheEntry[R])
+		if c.valid(entry) {
+			c.recency.MoveToFront(elem)
+			c.hits.Add(1)
+			return entry.name, nil
+		}
+		stale = entry.name
 	}
 
+	c.misses.Add(1)
 	resource, err := c.getFn(ctx, id)
 	if err != nil {
+		c.errors.Add(1)
+		if stale != "" {
+			return stale, nil
+		}
 		return "", err
 	}
-	_, c.cache[id] = c.identifierFn(resource)
+	if resource == nil {
+		// The API client returns no error for missing resources
+		c.errors.Add(1)
+		return "", hcloud.Error{Code: hcloud.ErrorCodeNotFound, Message: fmt.Sprintf("resource %d not found", id)}
+	}
+	c.set(resource)
 
-	return c.cache[id], nil
+	_, name := c.identifierFn(resource)
+	return name, nil
+}
+
+// Missing returns the ids whose names are not cached, so [NameCache.Get] would look them up.
+func (c *NameCache[R]) Missing(ids []int64) []int64 {
+	c.Lock()
+	defer c.Unlock()
+
+	var missing []int64
+	for _, id := range ids {
+		elem, ok := c.cache[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		if !c.valid(elem.Value.(*nameCacheEntry[R])) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
 }
 
 // Insert will insert the given resources into the cache, updating any existing entries.
@@ -59,7 +161,176 @@ func (c *NameCache[R]) Insert(resources ...*R) {
 	defer c.Unlock()
 
 	for _, resource := range resources {
-		id, name := c.identifierFn(resource)
-		c.cache[id] = name
+		c.set(resource)
+	}
+}
+
+// set adds or updates the entry of the resource and marks it as most recently used. If the cache is full, the least
+// recently used entry is evicted. Empty names are looked up again after [EmptyNameRetryBackoff]. Caller must hold the
+// mutex.
+func (c *NameCache[R]) set(resource *R) {
+	id, name := c.identifierFn(resource)
+	now := c.now()
+	var retryAfter time.Time
+	if name == "" {
+		retryAfter = now.Add(EmptyNameRetryBackoff)
+	}
+
+	if elem, ok := c.cache[id]; ok {
+		entry := elem.Value.(*nameCacheEntry[R])
+		entry.name = name
+		entry.resource = resource
+		entry.retryAfter = retryAfter
+		entry.updatedAt = now
+		c.recency.MoveToFront(elem)
+		return
+	}
+
+	c.cache[id] = c.recency.PushFront(&nameCacheEntry[R]{id: id, name: name, resource: resource, retryAfter: retryAfter, updatedAt: now})
+
+	for c.recency.Len() > c.maxEntries {
+		oldest := c.recency.Back()
+		c.recency.Remove(oldest)
+		delete(c.cache, oldest.Value.(*nameCacheEntry[R]).id)
+	}
+}
+
+// ListResourcesFn requests a single page of resources.
+type ListResourcesFn[R HCloudResource] func(ctx context.Context, opts hcloud.ListOpts) ([]*R, *hcloud.Response, error)
+
+// Warm inserts the names of the resources with the given ids into the cache. The API can not filter by multiple
+// ids, so the resources are listed, with [ResourceListPerPage] names per API request instead of one per request in
+// [NameCache.Get]. Pages are only requested while there are more missing names than remaining pages, so this never
+// needs more requests than looking up the names one by one.
+func (c *NameCache[R]) Warm(ctx context.Context, ids []int64, listFn ListResourcesFn[R]) error {
+	return c.warm(ctx, ids, listFn, c.Missing)
+}
+
+// warm lists the resources like [NameCache.Warm], while missing returns at least two ids.
+func (c *NameCache[R]) warm(ctx context.Context, ids []int64, listFn ListResourcesFn[R], missing func(ids []int64) []int64) error {
+	for page := 1; page > 0; {
+		if len(missing(ids)) < 2 {
+			// A single name is looked up with a single request by Get
+			return nil
+		}
+
+		resources, resp, err := listFn(ctx, hcloud.ListOpts{Page: page, PerPage: ResourceListPerPage})
+		if err != nil {
+			return err
+		}
+		c.Insert(resources...)
+
+		if resp == nil || resp.Meta.Pagination == nil {
+			return nil
+		}
+		pagination := resp.Meta.Pagination
+		if pagination.LastPage-pagination.Page >= len(missing(ids)) {
+			return nil
+		}
+		page = pagination.NextPage
 	}
+
+	return nil
+}
+
+// Resources returns the resources with the given ids, in the order of the ids. Resources that were fetched or listed
+// less than maxAge ago are taken from the cache, the others are listed like in [NameCache.Warm] or fetched one by one.
+// Resources that do not exist (anymore) are skipped.
+func (c *NameCache[R]) Resources(ctx context.Context, ids []int64, maxAge time.Duration, listFn ListResourcesFn[R]) ([]*R, error) {
+	missing := func(ids []int64) []int64 {
+		c.Lock()
+		defer c.Unlock()
+
+		var missing []int64
+		for _, id := range ids {
+			if c.resource(id, maxAge) == nil {
+				missing = append(missing, id)
+			}
+		}
+		return missing
+	}
+
+	if err := c.warm(ctx, ids, listFn, missing); err != nil {
+		return nil, err
+	}
+
+	resources := make([]*R, 0, len(ids))
+	for _, id := range ids {
+		c.Lock()
+		resource := c.resource(id, maxAge)
+		c.Unlock()
+
+		if resource == nil {
+			var err error
+			resource, err = c.getFn(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if resource == nil {
+				// The API client returns no error for missing resources
+				continue
+			}
+			c.Insert(resource)
+		}
+
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// resource returns the cached resource, or nil if it is not cached or older than maxAge. Caller must hold the mutex.
+func (c *NameCache[R]) resource(id int64, maxAge time.Duration) *R {
+	elem, ok := c.cache[id]
+	if !ok {
+		return nil
+	}
+
+	entry := elem.Value.(*nameCacheEntry[R])
+	if !c.now().Before(entry.updatedAt.Add(maxAge)) {
+		return nil
+	}
+	return entry.resource
+}
+
+// Stats returns the number of cached entries and the counters since the cache was created.
+func (c *NameCache[R]) Stats() NameCacheStats {
+	c.Lock()
+	entries := len(c.cache)
+	c.Unlock()
+
+	return NameCacheStats{
+		Entries: entries,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Errors:  c.errors.Load(),
+	}
+}
+
+// SetTTL sets the maximum age of cached names. Older names are looked up again on their next use, like names that are
+// not cached. Zero disables the expiry. The TTL applies to the entries that are already cached, too.
+func (c *NameCache[R]) SetTTL(ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.ttl = ttl
+}
+
+// Config returns the TTL and the maximum number of entries of the cache.
+func (c *NameCache[R]) Config() (ttl time.Duration, maxEntries int) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.ttl, c.maxEntries
+}
+
+// Clear removes all entries from the cache and returns the number of removed entries.
+func (c *NameCache[R]) Clear() int {
+	c.Lock()
+	defer c.Unlock()
+
+	cleared := len(c.cache)
+	c.cache = map[int64]*list.Element{}
+	c.recency.Init()
+
+	return cleared
 }
@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func newTestNameCache(maxEntries int) *NameCache[hcloud.Server] {
+	return NewNameCache[hcloud.Server](
+		nil,
+		func(ctx context.Context, id int64) (*hcloud.Server, error) { return nil, errors.New("not found") },
+		func(server *hcloud.Server) (int64, string) { return server.ID, server.Name },
+		maxEntries,
+	)
+}
+
+func TestNameCache_Eviction(t *testing.T) {
+	ctx := context.Background()
+	c := newTestNameCache(2)
+
+	c.Insert(&hcloud.Server{ID: 1, Name: "one"}, &hcloud.Server{ID: 2, Name: "two"})
+	c.Insert(&hcloud.Server{ID: 3, Name: "three"})
+
+	if _, err := c.Get(ctx, 1); err == nil {
+		t.Errorf("expected oldest entry to be evicted")
+	}
+
+	for id, want := range map[int64]string{2: "two", 3: "three"} {
+		if got, err := c.Get(ctx, id); err != nil || got != want {
+			t.Errorf("Get(%d) = %q, %v, want %q", id, got, err, want)
+		}
+	}
+}
+
+func TestNameCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	c := newTestNameCache(10)
+
+	c.Insert(&hcloud.Server{ID: 1, Name: "one"})
+	_, _ = c.Get(ctx, 1)
+	_, _ = c.Get(ctx, 1)
+	_, _ = c.Get(ctx, 2)
+
+	want := NameCacheStats{Entries: 1, Hits: 2, Misses: 1, Errors: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNameCache_GetUpdatesRecency(t *testing.T) {
+	ctx := context.Background()
+	c := newTestNameCache(2)
+
+	c.Insert(&hcloud.Server{ID: 1, Name: "one"}, &hcloud.Server{ID: 2, Name: "two"})
+
+	// Mark 1 as recently used, so 2 is evicted next
+	if _, err := c.Get(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	c.Insert(&hcloud.Server{ID: 3, Name: "three"})
+
+	if _, err := c.Get(ctx, 2); err == nil {
+		t.Errorf("expected least recently used entry to be evicted")
+	}
+	if got, err := c.Get(ctx, 1); err != nil || got != "one" {
+		t.Errorf("Get(1) = %q, %v, want %q", got, err, "one")
+	}
+}
+
+func TestNameCache_EmptyNameRetry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	name := ""
+	apiCalls := 0
+	c := NewNameCache[hcloud.Server](
+		nil,
+		func(ctx context.Context, id int64) (*hcloud.Server, error) {
+			apiCalls++
+			return &hcloud.Server{ID: id, Name: name}, nil
+		},
+		func(server *hcloud.Server) (int64, string) { return server.ID, server.Name },
+		10,
+	)
+	c.now = func() time.Time { return now }
+
+	if got, err := c.Get(ctx, 1); err != nil || got != "" {
+		t.Fatalf("Get(1) = %q, %v, want empty name", got, err)
+	}
+
+	// The server got its name, but the empty name is still used during the backoff
+	name = "webserver"
+	if got, _ := c.Get(ctx, 1); got != "" || apiCalls != 1 {
+		t.Errorf("Get(1) = %q with %d API calls, want cached empty name", got, apiCalls)
+	}
+
+	now = now.Add(EmptyNameRetryBackoff)
+	if got, _ := c.Get(ctx, 1); got != "webserver" || apiCalls != 2 {
+		t.Errorf("Get(1) = %q with %d API calls, want name from second API call", got, apiCalls)
+	}
+	if got, _ := c.Get(ctx, 1); got != "webserver" || apiCalls != 2 {
+		t.Errorf("Get(1) = %q with %d API calls, want cached name", got, apiCalls)
+	}
+}
+
+func TestNameCache_Warm(t *testing.T) {
+	ctx := context.Background()
+	c := newTestNameCache(10)
+	c.Insert(&hcloud.Server{ID: 1, Name: "one"})
+
+	if got := c.Missing([]int64{1, 2, 3}); len(got) != 2 {
+		t.Fatalf("Missing() = %v, want [2 3]", got)
+	}
+
+	var requestedPages []int
+	listFn := func(ctx context.Context, opts hcloud.ListOpts) ([]*hcloud.Server, *hcloud.Response, error) {
+		requestedPages = append(requestedPages, opts.Page)
+		resp := &hcloud.Response{Meta: hcloud.Meta{Pagination: &hcloud.Pagination{Page: opts.Page, LastPage: 3, NextPage: opts.Page + 1}}}
+		if opts.Page == 1 {
+			return []*hcloud.Server{{ID: 2, Name: "two"}}, resp, nil
+		}
+		return []*hcloud.Server{{ID: 3, Name: "three"}}, resp, nil
+	}
+
+	if err := c.Warm(ctx, []int64{1, 2, 3}, listFn); err != nil {
+		t.Fatal(err)
+	}
+
+	// After the first page only one name is missing, which is cheaper to look up than the two remaining pages
+	if len(requestedPages) != 1 {
+		t.Errorf("Warm() requested pages %v, want only the first page", requestedPages)
+	}
+	if got, err := c.Get(ctx, 2); err != nil || got != "two" {
+		t.Errorf("Get(2) = %q, %v, want %q", got, err, "two")
+	}
+
+	requestedPages = nil
+	if err := c.Warm(ctx, []int64{1, 2}, listFn); err != nil {
+		t.Fatal(err)
+	}
+	if len(requestedPages) != 0 {
+		t.Errorf("Warm() should not list resources if all names are cached, requested pages %v", requestedPages)
+	}
+}
+
+func TestNameCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	c := newTestNameCache(10)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+
+	c.Insert(&hcloud.Server{ID: 1, Name: "one"})
+
+	now = now.Add(time.Hour)
+	if name, err := c.Get(ctx, 1); err != nil || name != "one" {
+		t.Errorf("Get() = %q, %v, want the cached name without a TTL", name, err)
+	}
+
+	c.SetTTL(time.Minute)
+	if missing := c.Missing([]int64{1}); len(missing) != 1 {
+		t.Errorf("Missing() = %v, want the expired entry", missing)
+	}
+	misses := c.Stats().Misses
+	// The lookup fails, so the expired name is kept
+	if name, err := c.Get(ctx, 1); err != nil || name != "one" {
+		t.Errorf("Get() = %q, %v, want the expired name if the lookup fails", name, err)
+	}
+	if got := c.Stats().Misses; got != misses+1 {
+		t.Error("Get() should look up the expired name again")
+	}
+
+	c.Insert(&hcloud.Server{ID: 1, Name: "one"})
+	now = now.Add(30 * time.Second)
+	if name, err := c.Get(ctx, 1); err != nil || name != "one" {
+		t.Errorf("Get() = %q, %v, want the cached name before the TTL ends", name, err)
+	}
+}
+
+func TestNameCache_TTL_refresh(t *testing.T) {
+	ctx := context.Background()
+	servers := map[int64]*hcloud.Server{1: {ID: 1, Name: "renamed"}}
+	c := NewNameCache[hcloud.Server](
+		nil,
+		func(ctx context.Context, id int64) (*hcloud.Server, error) { return servers[id], nil },
+		func(server *hcloud.Server) (int64, string) { return server.ID, server.Name },
+		10,
+	)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+	c.SetTTL(time.Minute)
+
+	c.Insert(&hcloud.Server{ID: 1, Name: "one"}, &hcloud.Server{ID: 2, Name: "two"})
+	now = now.Add(time.Hour)
+
+	if name, err := c.Get(ctx, 1); err != nil || name != "renamed" {
+		t.Errorf("Get() = %q, %v, want the new name", name, err)
+	}
+	// Deleted resources are not kept with their expired name
+	if _, err := c.Get(ctx, 2); !hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+		t.Errorf("Get() error = %v, want not found for a deleted resource", err)
+	}
+}
@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// The Hetzner Cloud API returns the network metrics of every interface of a server as separate series named
+// `network.N.bandwidth.in|out`, where N is the index of the interface. The API does not say which interface is public
+// and which is private, so the mapping is derived from the server:
+//
+//   - The first interface (index 0) is always the public interface.
+//   - The private networks follow in the order of [hcloud.Server.PrivateNet], which is the order they were attached.
+//
+// A server without public IPs has no public interface and the API omits the `network.0` series (see
+// [missingNetworkNotices]), but its private interfaces still start at index 1.
+//
+// [MetricsTypeServerPublicNetwork] and [MetricsTypeServerPrivateNetwork] rename these series to
+// `public_network.bandwidth.in|out` and `private_network.bandwidth.in|out`. Servers can be attached to multiple
+// private networks, their bandwidth is summed up.
+
+var networkInterfaceBandwidthSeries = regexp.MustCompile(`^network\.(\d+)\.bandwidth\.(in|out)$`)
+
+// isNetworkInterfaceMetricsType returns true for the metrics types that require the network interfaces of the server.
+func isNetworkInterfaceMetricsType(metricsType MetricsType) bool {
+	return metricsType == MetricsTypeServerPublicNetwork || metricsType == MetricsTypeServerPrivateNetwork
+}
+
+// networkInterfaces describes which interface indexes of a server are connected to the public and private networks.
+type networkInterfaces struct {
+	// public is the index of the public interface, or -1 if the server has no public IPs.
+	public  int
+	private []int
+}
+
+func serverNetworkInterfaces(server *hcloud.Server) networkInterfaces {
+	interfaces := networkInterfaces{public: -1}
+
+	if !server.PublicNet.IPv4.IsUnspecified() || !server.PublicNet.IPv6.IsUnspecified() {
+		interfaces.public = 0
+	}
+
+	for i := range server.PrivateNet {
+		interfaces.private = append(interfaces.private, i+1)
+	}
+
+	return interfaces
+}
+
+// splitNetworkInterfaces adds the public and private network series for the requested metrics types, based on the
+// per-interface series in metrics. The per-interface series are removed, unless they are part of another requested
+// metrics type.
+func splitNetworkInterfaces(metrics *hcloud.ServerMetrics, interfaces networkInterfaces, metricsTypes []MetricsType) *hcloud.ServerMetrics {
+	metricsCopy := *metrics
+	metricsCopy.TimeSeries = make(map[string][]hcloud.ServerMetricsValue, len(metrics.TimeSeries))
+
+	keep := make(map[string]bool)
+	for _, metricsType := range metricsTypes {
+		if isNetworkInterfaceMetricsType(metricsType) {
+			continue
+		}
+		for _, series := range serverMetricsTypeSeries[metricsType] {
+			keep[series] = true
+		}
+	}
+
+	for name, series := range metrics.TimeSeries {
+		if !networkInterfaceBandwidthSeries.MatchString(name) || keep[name] {
+			metricsCopy.TimeSeries[name] = series
+		}
+	}
+
+	for _, direction := range []string{"in", "out"} {
+		if slices.Contains(metricsTypes, MetricsTypeServerPublicNetwork) && interfaces.public >= 0 {
+			if series, ok := metrics.TimeSeries[interfaceSeriesName(interfaces.public, direction)]; ok {
+				metricsCopy.TimeSeries["public_network.bandwidth."+direction] = series
+			}
+		}
+
+		if slices.Contains(metricsTypes, MetricsTypeServerPrivateNetwork) && len(interfaces.private) > 0 {
+			privateSeries := make([][]hcloud.ServerMetricsValue, 0, len(interfaces.private))
+			for _, index := range interfaces.private {
+				if series, ok := metrics.TimeSeries[interfaceSeriesName(index, direction)]; ok {
+					privateSeries = append(privateSeries, series)
+				}
+			}
+			if len(privateSeries) > 0 {
+				metricsCopy.TimeSeries["private_network.bandwidth."+direction] = sumSeries(privateSeries)
+			}
+		}
+	}
+
+	return &metricsCopy
+}
+
+func interfaceSeriesName(index int, direction string) string {
+	return fmt.Sprintf("network.%d.bandwidth.%s", index, direction)
+}
+
+// sumSeries adds up the values of all series with the same timestamp. If any value can not be parsed, it is kept
+// as-is, so the parse failure is reported when the frames are built.
+func sumSeries(allSeries [][]hcloud.ServerMetricsValue) []hcloud.ServerMetricsValue {
+	if len(allSeries) == 1 {
+		return allSeries[0]
+	}
+
+	type sum struct {
+		value   float64
+		invalid string
+	}
+	sums := make(map[float64]*sum)
+	var timestamps []float64
+
+	for _, series := range allSeries {
+		for _, value := range series {
+			s, ok := sums[value.Timestamp]
+			if !ok {
+				s = &sum{}
+				sums[value.Timestamp] = s
+				timestamps = append(timestamps, value.Timestamp)
+			}
+
+			parsedValue, err := strconv.ParseFloat(value.Value, 64)
+			if err != nil {
+				s.invalid = value.Value
+				continue
+			}
+			s.value += parsedValue
+		}
+	}
+
+	slices.Sort(timestamps)
+
+	result := make([]hcloud.ServerMetricsValue, 0, len(timestamps))
+	for _, timestamp := range timestamps {
+		s := sums[timestamp]
+		value := strconv.FormatFloat(s.value, 'f', -1, 64)
+		if s.invalid != "" {
+			value = s.invalid
+		}
+		result = append(result, hcloud.ServerMetricsValue{Timestamp: timestamp, Value: value})
+	}
+
+	return result
+}
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func Test_serverNetworkInterfaces(t *testing.T) {
+	tests := []struct {
+		name   string
+		server *hcloud.Server
+		want   networkInterfaces
+	}{
+		{
+			name:   "public only",
+			server: &hcloud.Server{PublicNet: hcloud.ServerPublicNet{IPv4: hcloud.ServerPublicNetIPv4{IP: net.ParseIP("192.0.2.1")}}},
+			want:   networkInterfaces{public: 0},
+		},
+		{
+			name: "public and private",
+			server: &hcloud.Server{
+				PublicNet:  hcloud.ServerPublicNet{IPv6: hcloud.ServerPublicNetIPv6{IP: net.ParseIP("2001:db8::1")}},
+				PrivateNet: []hcloud.ServerPrivateNet{{}, {}},
+			},
+			want: networkInterfaces{public: 0, private: []int{1, 2}},
+		},
+		{
+			name:   "private only",
+			server: &hcloud.Server{PrivateNet: []hcloud.ServerPrivateNet{{}}},
+			want:   networkInterfaces{public: -1, private: []int{1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serverNetworkInterfaces(tt.server); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("serverNetworkInterfaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_splitNetworkInterfaces(t *testing.T) {
+	metrics := &hcloud.ServerMetrics{
+		TimeSeries: map[string][]hcloud.ServerMetricsValue{
+			"network.0.bandwidth.in": {{Timestamp: 1, Value: "10"}},
+			"network.1.bandwidth.in": {{Timestamp: 1, Value: "1"}, {Timestamp: 2, Value: "2"}},
+			"network.2.bandwidth.in": {{Timestamp: 1, Value: "3"}},
+		},
+	}
+	interfaces := networkInterfaces{public: 0, private: []int{1, 2}}
+
+	got := splitNetworkInterfaces(metrics, interfaces, []MetricsType{MetricsTypeServerPublicNetwork, MetricsTypeServerPrivateNetwork})
+
+	want := map[string][]hcloud.ServerMetricsValue{
+		"public_network.bandwidth.in":  {{Timestamp: 1, Value: "10"}},
+		"private_network.bandwidth.in": {{Timestamp: 1, Value: "4"}, {Timestamp: 2, Value: "2"}},
+	}
+	if !reflect.DeepEqual(got.TimeSeries, want) {
+		t.Errorf("splitNetworkInterfaces() = %v, want %v", got.TimeSeries, want)
+	}
+
+	got = splitNetworkInterfaces(metrics, interfaces, []MetricsType{MetricsTypeServerNetworkBandwidth, MetricsTypeServerPublicNetwork})
+	if _, ok := got.TimeSeries["network.0.bandwidth.in"]; !ok {
+		t.Errorf("splitNetworkInterfaces() should keep series of other requested metrics types")
+	}
+	if _, ok := got.TimeSeries["network.1.bandwidth.in"]; ok {
+		t.Errorf("splitNetworkInterfaces() should remove per-interface series")
+	}
+}
+
+func Test_splitNetworkInterfaces_privateOnly(t *testing.T) {
+	// The API omits the series of the missing public interface
+	metrics := &hcloud.ServerMetrics{
+		TimeSeries: map[string][]hcloud.ServerMetricsValue{
+			"network.1.bandwidth.in": {{Timestamp: 1, Value: "5"}},
+		},
+	}
+	metricsTypes := []MetricsType{MetricsTypeServerPublicNetwork, MetricsTypeServerPrivateNetwork}
+	interfaces := serverNetworkInterfaces(&hcloud.Server{PrivateNet: []hcloud.ServerPrivateNet{{}}})
+
+	got := splitNetworkInterfaces(metrics, interfaces, metricsTypes)
+
+	want := map[string][]hcloud.ServerMetricsValue{
+		"private_network.bandwidth.in": {{Timestamp: 1, Value: "5"}},
+	}
+	if !reflect.DeepEqual(got.TimeSeries, want) {
+		t.Errorf("splitNetworkInterfaces() = %v, want %v", got.TimeSeries, want)
+	}
+
+	notices := missingNetworkNotices(1, "db-1", got, metricsTypes)
+	if len(notices) != 1 || notices[0].Text != "Server db-1 has no public network interface, no public-network-bandwidth metrics available." {
+		t.Errorf("missingNetworkNotices() = %v, want a notice for the public interface only", notices)
+	}
+}
@@ -1,18 +1,27 @@
 package plugin
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apricote/grafana-hcloud-datasource/pkg/set"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/sourcegraph/conc/iter"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
+// ErrQueryRunnerClosed is returned for all requests that are pending or sent after [QueryRunner.Close].
+var ErrQueryRunnerClosed = errors.New("query runner was closed, the data source settings were probably changed")
+
 type HCloudMetrics interface {
 	hcloud.ServerMetrics | hcloud.LoadBalancerMetrics
 }
@@ -38,83 +47,239 @@ type FilterMetricsFn[M HCloudMetrics] func(metrics *M, metricsTypes []MetricsTyp
 // will only send 1 request to the API instead of 5.
 //
 // The downside is that responses are slower, because we always wait for the buffer period to end before sending the
-// requests.
+// requests. If the buffer period is zero, buffering is disabled and every call to [QueryRunner.RequestMetrics] sends
+// its own API requests right away. With [QueryRunner.EnableFastPath], requests for a single resource also skip the
+// buffer while it is idle.
 type QueryRunner[M HCloudMetrics] struct {
 	mutex sync.Mutex
 
 	bufferPeriod time.Duration
 	bufferTimer  *time.Timer
+	// afterFunc starts the buffer timer, tests replace it to flush the buffer explicitly
+	afterFunc func(d time.Duration, f func()) *time.Timer
 
-	apiRequestFn    APIRequestFn[M]
+	fetcher         MetricsFetcher[M]
 	filterMetricsFn FilterMetricsFn[M]
 
 	requests map[int64][]request[M]
+
+	// fastPath sends requests for a single resource right away if the buffer is idle, see [QueryRunner.EnableFastPath]
+	fastPath bool
+
+	// ctx is the parent of all API requests, it is cancelled by [QueryRunner.Close]
+	ctx    context.Context
+	cancel context.CancelFunc
+	closed bool
+
+	// flushCounter is used to generate ids for every buffer flush, to correlate the
+	// API requests with the requests from Grafana in the logs.
+	flushCounter atomic.Uint64
+	// fetchCounter is used to generate ids for every call to the [MetricsFetcher], see [apiCall].
+	fetchCounter atomic.Uint64
+
+	// bufferedRequests and fetches count the buffered requests per resource and the fetched resources of all flushes,
+	// see [QueryRunner.Stats].
+	bufferedRequests atomic.Uint64
+	fetches          atomic.Uint64
+}
+
+// QueryRunnerStats describes how many requests were coalesced by the buffer of the QueryRunner.
+type QueryRunnerStats struct {
+	Flushes uint64 `json:"flushes"`
+	// Requests is the number of requests for the metrics of a single resource that were buffered.
+	Requests uint64 `json:"requests"`
+	// Fetches is the number of metrics fetched from the API for these requests. Requests for the same resource and
+	// options share a fetch.
+	Fetches uint64 `json:"fetches"`
+	// CoalescingRatio is Requests divided by Fetches, ie. 2 means that every API request answered two requests on
+	// average. It is 0 before the first flush.
+	CoalescingRatio float64 `json:"coalescingRatio"`
+}
+
+// NewQueryRunner creates a QueryRunner that sends one API request per resource. apiTimeout limits the duration of
+// every API request. At most maxConcurrentRequests API requests are sent in parallel, zero disables the limit.
+func NewQueryRunner[M HCloudMetrics](bufferPeriod time.Duration, apiTimeout time.Duration, maxConcurrentRequests int, apiRequestFn APIRequestFn[M], filterMetrics FilterMetricsFn[M]) *QueryRunner[M] {
+	return newQueryRunner(bufferPeriod, singleResourceFetcher[M]{
+		requestFn: apiRequestFn,
+		timeout:   apiTimeout,
+		limiter:   newRequestLimiter(maxConcurrentRequests),
+	}, filterMetrics)
 }
 
-func NewQueryRunner[M HCloudMetrics](bufferPeriod time.Duration, apiRequestFn APIRequestFn[M], filterMetrics FilterMetricsFn[M]) *QueryRunner[M] {
+// NewBulkQueryRunner creates a QueryRunner that requests the metrics of all resources with the same options in a
+// single API request. apiTimeout and maxConcurrentRequests are the same as for [NewQueryRunner].
+func NewBulkQueryRunner[M HCloudMetrics](bufferPeriod time.Duration, apiTimeout time.Duration, maxConcurrentRequests int, apiRequestFn BulkAPIRequestFn[M], filterMetrics FilterMetricsFn[M]) *QueryRunner[M] {
+	return newQueryRunner(bufferPeriod, bulkFetcher[M]{
+		requestFn: apiRequestFn,
+		timeout:   apiTimeout,
+		limiter:   newRequestLimiter(maxConcurrentRequests),
+	}, filterMetrics)
+}
+
+func newQueryRunner[M HCloudMetrics](bufferPeriod time.Duration, fetcher MetricsFetcher[M], filterMetrics FilterMetricsFn[M]) *QueryRunner[M] {
 	q := &QueryRunner[M]{
 		bufferPeriod:    bufferPeriod,
-		apiRequestFn:    apiRequestFn,
+		afterFunc:       time.AfterFunc,
+		fetcher:         fetcher,
 		filterMetricsFn: filterMetrics,
 		requests:        make(map[int64][]request[M]),
 	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
 
 	return q
 }
 
+// EnableFastPath makes the QueryRunner send requests for a single resource right away if no other requests are
+// buffered, instead of waiting for the buffer period. This removes the buffer latency for the common case of a
+// panel showing a single server, while requests for multiple resources (ie. label selectors) are still buffered.
+//
+// Requests that take the fast path are not combined with requests that arrive while they are in flight, so panels
+// that are loaded at the same time and show the same resource might send one API request each. It must be called
+// before the first request.
+func (q *QueryRunner[M]) EnableFastPath() {
+	q.fastPath = true
+}
+
 type request[M HCloudMetrics] struct {
 	opts       RequestOpts
 	responseCh chan<- response[M]
+
+	// logger carries the attributes (ie. trace id) of the Grafana request
+	logger log.Logger
+	// traceID is the id of the Grafana trace, it is added to the logs of the API requests sent for this request
+	traceID string
 }
 
 type response[M HCloudMetrics] struct {
 	id   int64
 	opts RequestOpts
 
+	// call is the API request that returned the metrics
+	call apiCall
+
 	metrics *M
 	err     error
+
+	// shared is true if the API response was also used to answer other requests
+	shared bool
+}
+
+// apiCall identifies a single API request. With a [bulkFetcher], the responses for multiple resources share one.
+type apiCall struct {
+	// fetch is the id of the call to [MetricsFetcher.FetchMetrics]
+	fetch uint64
+	// index is [FetchResult.Call]
+	index int
+}
+
+// RequestStats describes how the QueryRunner answered a call to [QueryRunner.RequestMetrics].
+type RequestStats struct {
+	// APICalls is the number of API requests that were used to answer the request. Without a bulk endpoint (see
+	// [NewBulkQueryRunner]), this is one per resource.
+	APICalls int
+	// SharedAPICalls is the number of API requests that were also used to answer other requests.
+	SharedAPICalls int
 }
 
 // RequestMetrics requests metrics matching the arguments given.
 // It will return a slice of metrics for each id in the same order
-func (q *QueryRunner[M]) RequestMetrics(ctx context.Context, ids []int64, opts RequestOpts) (map[int64]*M, error) {
+//
+// Resources that do not exist (anymore), ie. because they were deleted, do not fail the request. They are included
+// in the result with nil metrics, so the caller can inform the user about them.
+func (q *QueryRunner[M]) RequestMetrics(ctx context.Context, ids []int64, opts RequestOpts) (map[int64]*M, RequestStats, error) {
 	responseCh := make(chan response[M], len(ids))
 	req := request[M]{
 		opts:       opts,
 		responseCh: responseCh,
+		logger:     logger.FromContext(ctx),
+		traceID:    traceIDFromContext(ctx),
 	}
 
 	q.mutex.Lock()
-	for _, id := range ids {
-		q.requests[id] = append(q.requests[id], req)
+	if q.closed {
+		q.mutex.Unlock()
+		return nil, RequestStats{}, ErrQueryRunnerClosed
+	}
+	direct := q.bufferPeriod <= 0 || q.canTakeFastPath(ids)
+	if !direct {
+		for _, id := range ids {
+			q.requests[id] = append(q.requests[id], req)
+		}
+		q.startBuffer()
 	}
-	q.startBuffer()
 	q.mutex.Unlock()
 
+	if direct {
+		// Buffering is disabled or there is nothing to combine the request with, send the requests right away.
+		// The requests are cancelled by [QueryRunner.Close] like buffered ones, or when the caller gives up.
+		fetchCtx, cancel := context.WithCancel(log.WithContextualAttributes(q.ctx, log.ContextualAttributesFromContext(ctx)))
+		stop := context.AfterFunc(ctx, cancel)
+		go func() {
+			defer cancel()
+			defer stop()
+
+			fetchID := q.fetchCounter.Add(1)
+			for id, result := range q.fetcher.FetchMetrics(fetchCtx, ids, opts) {
+				metrics := result.Metrics
+				if result.Err == nil {
+					metrics = q.filterMetricsFn(metrics, opts.MetricsTypes)
+				}
+				responseCh <- response[M]{
+					id:      id,
+					opts:    opts,
+					call:    apiCall{fetch: fetchID, index: result.Call},
+					metrics: metrics,
+					err:     result.Err,
+				}
+			}
+		}()
+	}
+
 	results := make(map[int64]*M, len(ids))
+	var stats RequestStats
+	calls, sharedCalls := set.New[apiCall](), set.New[apiCall]()
 
 	for len(results) < len(ids) {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return nil, stats, ctx.Err()
 		case resp := <-responseCh:
+			if !calls.Has(resp.call) {
+				calls.Insert(resp.call)
+				stats.APICalls++
+			}
+			if resp.shared && !sharedCalls.Has(resp.call) {
+				sharedCalls.Insert(resp.call)
+				stats.SharedAPICalls++
+			}
+
+			if hcloud.IsError(resp.err, hcloud.ErrorCodeNotFound) {
+				results[resp.id] = nil
+				continue
+			}
 			if resp.err != nil {
 				// TODO: This could be improved by returning results for successful requests
 				//       and informing the user about the partial failure through Notices
-				return nil, resp.err
+				return nil, stats, resp.err
 			}
 
 			results[resp.id] = resp.metrics
 		}
 	}
 
-	return results, nil
+	return results, stats, nil
+}
+
+// canTakeFastPath returns true if the request for ids can skip the buffer, see [QueryRunner.EnableFastPath]. Caller
+// must hold the mutex.
+func (q *QueryRunner[M]) canTakeFastPath(ids []int64) bool {
+	return q.fastPath && len(ids) == 1 && len(q.requests) == 0 && q.bufferTimer == nil
 }
 
 // startBuffer starts the buffer timer if it's not already running. Caller must hold the mutex.
 func (q *QueryRunner[M]) startBuffer() {
 	if q.bufferTimer == nil {
-		q.bufferTimer = time.AfterFunc(q.bufferPeriod, q.sendRequests)
+		q.bufferTimer = q.afterFunc(q.bufferPeriod, q.sendRequests)
 	}
 }
 
@@ -125,50 +290,75 @@ func (q *QueryRunner[M]) startBuffer() {
 // it removes all requests that have been answered from q.requests and resets
 // the buffer timer.
 func (q *QueryRunner[M]) sendRequests() {
-	ctx := context.Background()
+	flushID := q.flushCounter.Add(1)
+	ctx := log.WithContextualAttributes(q.ctx, []any{"flushID", flushID})
 
 	q.mutex.Lock()
 	defer q.resetBufferTimer()
 
-	// Actual length might be larger, but it is a reasonable starting point
-	allRequests := make([]struct {
-		id   int64
-		opts RequestOpts
-	}, 0, len(q.requests))
+	// Resources with the same options are fetched together, so a [MetricsFetcher] that supports multiple
+	// resources per API request can combine them.
+	type fetchGroup struct {
+		opts     RequestOpts
+		ids      []int64
+		traceIDs set.Set[string]
+	}
+	groups := make(map[requestKey]*fetchGroup)
+	var requestCount, fetchCount int
 
 	for id, requests := range q.requests {
-		id := id
 		allOpts := make([]RequestOpts, 0, len(requests))
 		for _, req := range requests {
+			req.logger.Debug("Sending buffered request", "flushID", flushID, "resourceID", id)
 			allOpts = append(allOpts, req.opts)
 		}
 
-		uniqueOpts := uniqueRequests(allOpts)
+		requestCount += len(requests)
+
+		for _, opts := range uniqueRequests(allOpts) {
+			fetchCount++
+			key := opts.key()
+			if _, ok := groups[key]; !ok {
+				groups[key] = &fetchGroup{opts: opts, traceIDs: set.New[string]()}
+			}
+			groups[key].ids = append(groups[key].ids, id)
 
-		for _, opts := range uniqueOpts {
-			allRequests = append(allRequests, struct {
-				id   int64
-				opts RequestOpts
-			}{id: id, opts: opts})
+			for _, req := range requests {
+				if req.traceID != "" && opts.matches(req.opts) {
+					groups[key].traceIDs.Insert(req.traceID)
+				}
+			}
 		}
 	}
 
 	// We are finished reading from q for now, lets unlock the mutex until we need it again
 	q.mutex.Unlock()
 
-	iter.ForEach(allRequests, func(req *struct {
-		id   int64
-		opts RequestOpts
-	}) {
-		metrics, err := q.apiRequestFn(ctx, req.id, req.opts)
+	q.bufferedRequests.Add(uint64(requestCount))
+	q.fetches.Add(uint64(fetchCount))
+	logger.FromContext(ctx).Debug("Flushing buffered requests", "requests", requestCount, "fetches", fetchCount)
 
-		q.sendResponse(response[M]{
-			id:   req.id,
-			opts: req.opts,
+	iter.ForEach(slices.Collect(maps.Values(groups)), func(group **fetchGroup) {
+		// The API requests answer the requests of all these traces, so they are all added to the logs
+		traceIDs := (*group).traceIDs.ToSlice()
+		slices.Sort(traceIDs)
+		groupCtx := log.WithContextualAttributes(ctx, []any{"traceIds", traceIDs})
+		fetchID := q.fetchCounter.Add(1)
 
-			metrics: metrics,
-			err:     err,
-		})
+		for id, result := range q.fetcher.FetchMetrics(groupCtx, (*group).ids, (*group).opts) {
+			if result.Err != nil {
+				logger.FromContext(groupCtx).Warn("API request for metrics failed", "resourceID", id, "error", result.Err)
+			}
+
+			q.sendResponse(response[M]{
+				id:   id,
+				opts: (*group).opts,
+				call: apiCall{fetch: fetchID, index: result.Call},
+
+				metrics: result.Metrics,
+				err:     result.Err,
+			})
+		}
 	})
 }
 
@@ -179,23 +369,42 @@ func (q *QueryRunner[M]) sendResponse(resp response[M]) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
+	if len(q.requests[resp.id]) == 0 {
+		// The requests were already answered, ie. by [QueryRunner.Close]
+		return
+	}
+
 	// Send the response to all open requests that match it
 	// Remove all requests that have received a response from q.requests
 	newRequestsForID := make([]request[M], 0, len(q.requests[resp.id])-1)
+	matchingRequests := make([]request[M], 0, len(q.requests[resp.id]))
 	for _, req := range q.requests[resp.id] {
 		if resp.opts.matches(req.opts) {
-			req.responseCh <- response[M]{
-				id:   resp.id,
-				opts: req.opts,
-
-				metrics: q.filterMetricsFn(resp.metrics, req.opts.MetricsTypes),
-				err:     resp.err,
-			}
+			matchingRequests = append(matchingRequests, req)
 		} else {
 			newRequestsForID = append(newRequestsForID, req)
 		}
 	}
 
+	for _, req := range matchingRequests {
+		var metrics *M
+		// Failed requests (ie. timeouts) have no metrics to filter
+		if resp.err == nil {
+			metrics = q.filterMetricsFn(resp.metrics, req.opts.MetricsTypes)
+		}
+
+		req.responseCh <- response[M]{
+			id:   resp.id,
+			opts: req.opts,
+			call: resp.call,
+
+			metrics: metrics,
+			err:     resp.err,
+
+			shared: len(matchingRequests) > 1,
+		}
+	}
+
 	if len(newRequestsForID) == 0 {
 		delete(q.requests, resp.id)
 	} else {
@@ -205,15 +414,72 @@ func (q *QueryRunner[M]) sendResponse(resp response[M]) {
 
 // resetBufferTimer will reset the buffer timer so new requests can be sent.
 // It will also trigger a new buffer period if unanswered requests remain in the [q.requests]
+// Requires locking q.mutex, it runs after the API requests of a flush, concurrently to [QueryRunner.Close].
 func (q *QueryRunner[M]) resetBufferTimer() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
 	q.bufferTimer = nil
 
-	if len(q.requests) > 0 {
+	if len(q.requests) > 0 && !q.closed {
 		logger.Info("Reset buffer timer but there are still open requests, starting new buffer period", "openResources", len(q.requests))
 		q.startBuffer()
 	}
 }
 
+// Stats returns the counters of all buffer flushes since the QueryRunner was created. Requests that are sent while
+// buffering is disabled or that take the fast path are not counted, as they are never coalesced.
+func (q *QueryRunner[M]) Stats() QueryRunnerStats {
+	stats := QueryRunnerStats{
+		Flushes:  q.flushCounter.Load(),
+		Requests: q.bufferedRequests.Load(),
+		Fetches:  q.fetches.Load(),
+	}
+	if stats.Fetches > 0 {
+		stats.CoalescingRatio = float64(stats.Requests) / float64(stats.Fetches)
+	}
+	return stats
+}
+
+// Close stops the buffer timer, cancels all API requests in flight and answers all pending requests with
+// [ErrQueryRunnerClosed]. All later requests also fail with this error. It is safe to call Close multiple times.
+func (q *QueryRunner[M]) Close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.cancel()
+
+	if q.bufferTimer != nil {
+		q.bufferTimer.Stop()
+		q.bufferTimer = nil
+	}
+
+	for id, requests := range q.requests {
+		for _, req := range requests {
+			// The channel has room for one response per requested resource, so this does not block
+			req.responseCh <- response[M]{id: id, opts: req.opts, err: ErrQueryRunnerClosed}
+		}
+	}
+	q.requests = make(map[int64][]request[M])
+}
+
+// traceIDFromContext returns the id of the Grafana trace, which the plugin SDK adds to the contextual log attributes of
+// every request. It is empty if the request is not traced.
+func traceIDFromContext(ctx context.Context) string {
+	attributes := log.ContextualAttributesFromContext(ctx)
+	for i := 0; i+1 < len(attributes); i += 2 {
+		if key, ok := attributes[i].(string); ok && key == "traceId" {
+			traceID, _ := attributes[i+1].(string)
+			return traceID
+		}
+	}
+	return ""
+}
+
 // uniqueRequests deduplicates requests by combining requests with the same time range and step. All metrics types are added together
 func uniqueRequests(requests []RequestOpts) []RequestOpts {
 	type key struct {
@@ -229,11 +495,7 @@ func uniqueRequests(requests []RequestOpts) []RequestOpts {
 			step:      req.Step,
 		}
 
-		if _, ok := unique[k]; !ok {
-			unique[k] = set.New[MetricsType]()
-		}
-
-		unique[k].Insert(req.MetricsTypes...)
+		unique[k] = unique[k].Union(set.From(req.MetricsTypes...))
 	}
 
 	uniqueSlice := make([]RequestOpts, 0, len(unique))
@@ -248,9 +510,29 @@ func uniqueRequests(requests []RequestOpts) []RequestOpts {
 		})
 	}
 
+	// Map iteration order is random, sort to make the result deterministic
+	slices.SortFunc(uniqueSlice, func(a, b RequestOpts) int {
+		return cmp.Or(
+			a.TimeRange.From.Compare(b.TimeRange.From),
+			a.TimeRange.To.Compare(b.TimeRange.To),
+			cmp.Compare(a.Step, b.Step),
+		)
+	})
+
 	return uniqueSlice
 }
 
+type requestKey struct {
+	timeRange    backend.TimeRange
+	step         int
+	metricsTypes string
+}
+
+// key identifies requests with the same options. The metrics types must be sorted, like in [uniqueRequests].
+func (r RequestOpts) key() requestKey {
+	return requestKey{timeRange: r.TimeRange, step: r.Step, metricsTypes: fmt.Sprint(r.MetricsTypes)}
+}
+
 // matches returns true if a response to r can fully satisfy other.
 func (r RequestOpts) matches(other RequestOpts) bool {
 	timeRangeMatches := r.TimeRange.From == other.TimeRange.From && r.TimeRange.To == other.TimeRange.To
@@ -1,9 +1,14 @@
 package plugin
 
 import (
+	"context"
+	"errors"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -116,3 +121,518 @@ func Test_uniqueRequests(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryRunner_RequestMetrics_Stats(t *testing.T) {
+	var apiCalls atomic.Int32
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		time.Minute,
+		time.Second,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			apiCalls.Add(1)
+			return &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}, nil
+		},
+		filterServerMetrics,
+	)
+
+	opts := RequestOpts{
+		MetricsTypes: []MetricsType{MetricsTypeServerCPU},
+		TimeRange:    backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+		Step:         1,
+	}
+	flush := flushManually(q)
+
+	var wg sync.WaitGroup
+	stats := make([]RequestStats, 2)
+	for i, ids := range [][]int64{{1, 2}, {2}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, s, err := q.RequestMetrics(context.Background(), ids, opts)
+			if err != nil {
+				t.Error(err)
+			}
+			stats[i] = s
+		}()
+	}
+	flush(t, 3)
+	wg.Wait()
+
+	if got := apiCalls.Load(); got != 2 {
+		t.Errorf("API was called %d times, want 2", got)
+	}
+
+	want := []RequestStats{{APICalls: 2, SharedAPICalls: 1}, {APICalls: 1, SharedAPICalls: 1}}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("RequestMetrics() stats = %v, want %v", stats, want)
+	}
+}
+
+func TestQueryRunner_RequestMetrics_Timeout(t *testing.T) {
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		time.Millisecond,
+		10*time.Millisecond,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		filterServerMetrics,
+	)
+
+	_, _, err := q.RequestMetrics(context.Background(), []int64{1}, RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RequestMetrics() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestQueryRunner_RequestMetrics_NotFound(t *testing.T) {
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		0,
+		time.Second,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			if id == 2 {
+				return nil, hcloud.Error{Code: hcloud.ErrorCodeNotFound, Message: "server not found"}
+			}
+			return &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}, nil
+		},
+		filterServerMetrics,
+	)
+
+	metrics, _, err := q.RequestMetrics(context.Background(), []int64{1, 2}, RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}})
+	if err != nil {
+		t.Fatalf("RequestMetrics() should not fail for missing resources, got %v", err)
+	}
+
+	if metrics[1] == nil {
+		t.Errorf("RequestMetrics() should return metrics for existing resource")
+	}
+	if serverMetrics, ok := metrics[2]; !ok || serverMetrics != nil {
+		t.Errorf("RequestMetrics() should return nil metrics for missing resource, got %v", serverMetrics)
+	}
+}
+
+func TestQueryRunner_RequestMetrics_BufferingDisabled(t *testing.T) {
+	var apiCalls atomic.Int32
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		0,
+		time.Second,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			apiCalls.Add(1)
+			return &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}, nil
+		},
+		filterServerMetrics,
+	)
+
+	metrics, stats, err := q.RequestMetrics(context.Background(), []int64{1, 2}, RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(metrics) != 2 {
+		t.Errorf("RequestMetrics() returned metrics for %d resources, want 2", len(metrics))
+	}
+	if got := apiCalls.Load(); got != 2 {
+		t.Errorf("API was called %d times, want 2", got)
+	}
+	if want := (RequestStats{APICalls: 2}); stats != want {
+		t.Errorf("RequestMetrics() stats = %v, want %v", stats, want)
+	}
+}
+
+func TestQueryRunner_RequestMetrics_MaxConcurrentRequests(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		0,
+		time.Second,
+		2,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				observed := maxInFlight.Load()
+				if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			return &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}, nil
+		},
+		filterServerMetrics,
+	)
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := q.RequestMetrics(context.Background(), []int64{1, 2, 3, 4, 5}, RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := maxInFlight.Load(); got > 2 {
+		t.Errorf("%d API requests were sent in parallel, want at most 2", got)
+	}
+}
+
+func TestQueryRunner_RequestMetrics_Bulk(t *testing.T) {
+	var mutex sync.Mutex
+	var requestedIDs [][]int64
+	q := NewBulkQueryRunner[hcloud.ServerMetrics](
+		time.Minute,
+		time.Second,
+		0,
+		func(ctx context.Context, ids []int64, opts RequestOpts) (map[int64]*hcloud.ServerMetrics, error) {
+			mutex.Lock()
+			requestedIDs = append(requestedIDs, ids)
+			mutex.Unlock()
+
+			metrics := make(map[int64]*hcloud.ServerMetrics)
+			for _, id := range ids {
+				if id != 3 {
+					metrics[id] = &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}
+				}
+			}
+			return metrics, nil
+		},
+		filterServerMetrics,
+	)
+
+	opts := RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}}
+	flush := flushManually(q)
+
+	var wg sync.WaitGroup
+	results := make([]map[int64]*hcloud.ServerMetrics, 2)
+	for i, ids := range [][]int64{{1, 2}, {2, 3}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			metrics, _, err := q.RequestMetrics(context.Background(), ids, opts)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = metrics
+		}()
+	}
+	flush(t, 4)
+	wg.Wait()
+
+	if len(requestedIDs) != 1 || len(requestedIDs[0]) != 3 {
+		t.Errorf("API should be called once for all resources, got %v", requestedIDs)
+	}
+	if results[0][1] == nil || results[0][2] == nil || results[1][2] == nil {
+		t.Errorf("RequestMetrics() should return metrics for all existing resources, got %v", results)
+	}
+	if metrics, ok := results[1][3]; !ok || metrics != nil {
+		t.Errorf("RequestMetrics() should return nil metrics for resources missing in the response, got %v", metrics)
+	}
+}
+
+func TestQueryRunner_Close(t *testing.T) {
+	var apiCalls atomic.Int32
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		50*time.Millisecond,
+		time.Second,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			apiCalls.Add(1)
+			return &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}, nil
+		},
+		filterServerMetrics,
+	)
+	opts := RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}}
+
+	errCh := make(chan error)
+	go func() {
+		_, _, err := q.RequestMetrics(context.Background(), []int64{1, 2}, opts)
+		errCh <- err
+	}()
+
+	// Close while the request is waiting for the buffer period
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	q.Close()
+
+	if err := <-errCh; !errors.Is(err, ErrQueryRunnerClosed) {
+		t.Errorf("pending RequestMetrics() error = %v, want %v", err, ErrQueryRunnerClosed)
+	}
+	if _, _, err := q.RequestMetrics(context.Background(), []int64{1}, opts); !errors.Is(err, ErrQueryRunnerClosed) {
+		t.Errorf("RequestMetrics() after Close() error = %v, want %v", err, ErrQueryRunnerClosed)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := apiCalls.Load(); got != 0 {
+		t.Errorf("API was called %d times after Close(), want 0", got)
+	}
+}
+
+func TestQueryRunner_Close_duringFlush(t *testing.T) {
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	var once sync.Once
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		time.Millisecond,
+		time.Minute,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			once.Do(func() { close(started) })
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		filterServerMetrics,
+	)
+	opts := RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}}
+
+	go func() {
+		defer close(finished)
+		if _, _, err := q.RequestMetrics(context.Background(), []int64{1, 2}, opts); !errors.Is(err, ErrQueryRunnerClosed) {
+			t.Errorf("RequestMetrics() error = %v, want %v", err, ErrQueryRunnerClosed)
+		}
+	}()
+
+	// Close while the API requests of the flush are running
+	<-started
+	q.Close()
+	<-finished
+
+	// The flush ends after the API requests were cancelled, it must not start a new buffer period
+	time.Sleep(50 * time.Millisecond)
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.bufferTimer != nil {
+		t.Error("the buffer timer was restarted after Close()")
+	}
+}
+
+func TestQueryRunner_Close_direct(t *testing.T) {
+	started := make(chan struct{})
+	var logAttributes []any
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		0,
+		time.Minute,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			logAttributes = log.ContextualAttributesFromContext(ctx)
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		filterServerMetrics,
+	)
+
+	errCh := make(chan error)
+	go func() {
+		ctx := log.WithContextualAttributes(context.Background(), []any{"traceId", "abc"})
+		_, _, err := q.RequestMetrics(ctx, []int64{1}, RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}})
+		errCh <- err
+	}()
+
+	// Close while the unbuffered request is running
+	<-started
+	q.Close()
+
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("RequestMetrics() error = %v, want %v", err, context.Canceled)
+	}
+	if want := []any{"traceId", "abc"}; !reflect.DeepEqual(logAttributes, want) {
+		t.Errorf("log attributes of the API request = %v, want %v", logAttributes, want)
+	}
+}
+
+func TestQueryRunner_RequestMetrics_TraceID(t *testing.T) {
+	var logAttributes []any
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		time.Millisecond,
+		time.Second,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			logAttributes = log.ContextualAttributesFromContext(ctx)
+			return &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}, nil
+		},
+		filterServerMetrics,
+	)
+
+	ctx := log.WithContextualAttributes(context.Background(), []any{"traceId", "abc"})
+	if _, _, err := q.RequestMetrics(ctx, []int64{1}, RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []any{"flushID", uint64(1), "traceIds", []string{"abc"}}
+	if !reflect.DeepEqual(logAttributes, want) {
+		t.Errorf("log attributes of the API request = %v, want %v", logAttributes, want)
+	}
+}
+
+func TestQueryRunner_Stats(t *testing.T) {
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		time.Minute,
+		time.Second,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			return &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}, nil
+		},
+		filterServerMetrics,
+	)
+	opts := RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}}
+	flush := flushManually(q)
+
+	if stats := q.Stats(); stats.CoalescingRatio != 0 {
+		t.Errorf("CoalescingRatio before the first flush = %v, want 0", stats.CoalescingRatio)
+	}
+
+	var wg sync.WaitGroup
+	for _, ids := range [][]int64{{1, 2}, {1, 2}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := q.RequestMetrics(context.Background(), ids, opts); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	flush(t, 4)
+	wg.Wait()
+
+	want := QueryRunnerStats{Flushes: 1, Requests: 4, Fetches: 2, CoalescingRatio: 2}
+	if got := q.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestQueryRunner_RequestMetrics_FastPath(t *testing.T) {
+	q := NewQueryRunner[hcloud.ServerMetrics](
+		50*time.Millisecond,
+		time.Second,
+		0,
+		func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+			return &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}, nil
+		},
+		filterServerMetrics,
+	)
+	q.EnableFastPath()
+	opts := RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}}
+
+	metrics, _, err := q.RequestMetrics(context.Background(), []int64{1}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics[1] == nil {
+		t.Errorf("RequestMetrics() should return metrics for the resource")
+	}
+	if stats := q.Stats(); stats.Flushes != 0 {
+		t.Errorf("request for a single resource should skip the buffer, got %d flushes", stats.Flushes)
+	}
+
+	if _, _, err := q.RequestMetrics(context.Background(), []int64{1, 2}, opts); err != nil {
+		t.Fatal(err)
+	}
+	if stats := q.Stats(); stats.Flushes != 1 {
+		t.Errorf("request for multiple resources should be buffered, got %d flushes", stats.Flushes)
+	}
+}
+
+func BenchmarkQueryRunner_RequestMetrics(b *testing.B) {
+	for _, fastPath := range []bool{false, true} {
+		name := "buffered"
+		if fastPath {
+			name = "fast path"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			q := NewQueryRunner[hcloud.ServerMetrics](
+				DefaultBufferPeriod,
+				time.Second,
+				0,
+				func(ctx context.Context, id int64, opts RequestOpts) (*hcloud.ServerMetrics, error) {
+					return &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}, nil
+				},
+				filterServerMetrics,
+			)
+			if fastPath {
+				q.EnableFastPath()
+			}
+			opts := RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}}
+
+			for i := 0; i < b.N; i++ {
+				if _, _, err := q.RequestMetrics(context.Background(), []int64{1}, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryRunner_RequestMetrics_Stats_bulk(t *testing.T) {
+	q := NewBulkQueryRunner[hcloud.ServerMetrics](
+		time.Minute,
+		time.Second,
+		0,
+		func(ctx context.Context, ids []int64, opts RequestOpts) (map[int64]*hcloud.ServerMetrics, error) {
+			metrics := make(map[int64]*hcloud.ServerMetrics)
+			for _, id := range ids {
+				metrics[id] = &hcloud.ServerMetrics{TimeSeries: map[string][]hcloud.ServerMetricsValue{}}
+			}
+			return metrics, nil
+		},
+		filterServerMetrics,
+	)
+	flush := flushManually(q)
+
+	var stats RequestStats
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var err error
+		if _, stats, err = q.RequestMetrics(context.Background(), []int64{1, 2, 3}, RequestOpts{MetricsTypes: []MetricsType{MetricsTypeServerCPU}}); err != nil {
+			t.Error(err)
+		}
+	}()
+	flush(t, 3)
+	<-done
+
+	// The metrics of all resources were returned by a single API request
+	if want := (RequestStats{APICalls: 1}); stats != want {
+		t.Errorf("RequestMetrics() stats = %v, want %v", stats, want)
+	}
+}
+
+// flushManually replaces the buffer timer of q, so tests do not depend on the timing of the buffer period. The
+// returned function waits until n requests for single resources are buffered and then flushes the buffer.
+func flushManually[M HCloudMetrics](q *QueryRunner[M]) func(t *testing.T, n int) {
+	var pending func()
+	q.afterFunc = func(_ time.Duration, f func()) *time.Timer {
+		// Called with q.mutex held
+		pending = f
+		return time.NewTimer(time.Hour)
+	}
+
+	return func(t *testing.T, n int) {
+		t.Helper()
+
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			var flush func()
+
+			q.mutex.Lock()
+			buffered := 0
+			for _, requests := range q.requests {
+				buffered += len(requests)
+			}
+			if buffered == n && pending != nil {
+				flush, pending = pending, nil
+			}
+			q.mutex.Unlock()
+
+			if flush != nil {
+				flush()
+				return
+			}
+		}
+		t.Fatalf("%d requests were not buffered in time", n)
+	}
+}
@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type SeriesSort string
+
+const (
+	SeriesSortNone     SeriesSort = "none"
+	SeriesSortLastAsc  SeriesSort = "last-asc"
+	SeriesSortLastDesc SeriesSort = "last-desc"
+	SeriesSortName     SeriesSort = "name"
+)
+
+// Validate returns an error if the sort order is unknown. An empty value is treated as [SeriesSortNone].
+func (s SeriesSort) Validate() error {
+	switch s {
+	case "", SeriesSortNone, SeriesSortLastAsc, SeriesSortLastDesc, SeriesSortName:
+		return nil
+	default:
+		return fmt.Errorf("unknown series sort: %q", s)
+	}
+}
+
+// sortSeries orders the frames by the last value or the name of their series. Unlike [topNFrames], every series is
+// ranked on its own, so the in & out series of a resource can end up apart. Series without any values are always
+// last. Ties keep the input order, which is the order of [sortFrames].
+func sortSeries(frames []*data.Frame, by SeriesSort) {
+	switch by {
+	case SeriesSortLastAsc, SeriesSortLastDesc:
+		last := make(map[*data.Frame]*float64, len(frames))
+		for _, frame := range frames {
+			last[frame] = lastValue(frame)
+		}
+
+		slices.SortStableFunc(frames, func(a, b *data.Frame) int {
+			lastA, lastB := last[a], last[b]
+			switch {
+			case lastA == nil && lastB == nil:
+				return 0
+			case lastA == nil:
+				return 1
+			case lastB == nil:
+				return -1
+			case by == SeriesSortLastDesc:
+				return cmp.Compare(*lastB, *lastA)
+			default:
+				return cmp.Compare(*lastA, *lastB)
+			}
+		})
+	case SeriesSortName:
+		slices.SortStableFunc(frames, func(a, b *data.Frame) int {
+			labelsA, labelsB := valuesLabels(a), valuesLabels(b)
+			if c := cmp.Compare(labelsA[LabelName], labelsB[LabelName]); c != 0 {
+				return c
+			}
+			return cmp.Compare(labelsA[LabelSeriesName], labelsB[LabelSeriesName])
+		})
+	}
+}
+
+// lastValue returns the last non-null value of the values field of the frame.
+func lastValue(frame *data.Frame) *float64 {
+	if len(frame.Fields) == 0 {
+		return nil
+	}
+	valuesField := frame.Fields[len(frame.Fields)-1]
+	for i := valuesField.Len() - 1; i >= 0; i-- {
+		if value, ok := valuesField.At(i).(*float64); ok && value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+// valuesLabels returns the labels of the values field of the frame.
+func valuesLabels(frame *data.Frame) data.Labels {
+	if len(frame.Fields) == 0 {
+		return nil
+	}
+	return frame.Fields[len(frame.Fields)-1].Labels
+}
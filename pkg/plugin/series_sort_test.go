@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func Test_sortSeries(t *testing.T) {
+	frame := func(id, name, seriesName string, values ...*float64) *data.Frame {
+		times := make([]time.Time, 0, len(values))
+		for i := range values {
+			times = append(times, time.Unix(int64(i), 0))
+		}
+		return data.NewFrame("",
+			data.NewField("time", nil, times),
+			data.NewField(seriesName, data.Labels{LabelID: id, LabelName: name, LabelSeriesName: seriesName}, values),
+		)
+	}
+	ids := func(frames []*data.Frame) []string {
+		result := make([]string, 0, len(frames))
+		for _, f := range frames {
+			result = append(result, f.Fields[1].Labels[LabelID]+"/"+f.Fields[1].Labels[LabelSeriesName])
+		}
+		return result
+	}
+
+	tests := []struct {
+		by   SeriesSort
+		want []string
+	}{
+		{by: "", want: []string{"1/in", "1/out", "2/in", "2/out", "3/in"}},
+		{by: SeriesSortNone, want: []string{"1/in", "1/out", "2/in", "2/out", "3/in"}},
+		{by: SeriesSortLastDesc, want: []string{"2/in", "1/out", "3/in", "1/in", "2/out"}},
+		{by: SeriesSortLastAsc, want: []string{"1/in", "1/out", "3/in", "2/in", "2/out"}},
+		{by: SeriesSortName, want: []string{"3/in", "2/in", "2/out", "1/in", "1/out"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.by), func(t *testing.T) {
+			frames := []*data.Frame{
+				frame("1", "web-b", "in", hcloud.Ptr(10.0), hcloud.Ptr(1.0)),
+				frame("1", "web-b", "out", hcloud.Ptr(0.0), hcloud.Ptr(4.0)),
+				frame("2", "web-a", "in", hcloud.Ptr(1.0), hcloud.Ptr(5.0)),
+				frame("2", "web-a", "out", nil, nil),
+				frame("3", "db", "in", hcloud.Ptr(4.0), hcloud.Ptr(4.0), nil),
+			}
+
+			sortSeries(frames, tt.by)
+			if got := ids(frames); !slices.Equal(got, tt.want) {
+				t.Errorf("sortSeries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package plugin
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type TopNBy string
+
+const (
+	TopNByLast TopNBy = "last"
+	TopNByMax  TopNBy = "max"
+	TopNByAvg  TopNBy = "avg"
+)
+
+// Validate returns an error if the statistic is unknown. An empty value is treated as [TopNByLast].
+func (t TopNBy) Validate() error {
+	switch t {
+	case "", TopNByLast, TopNByMax, TopNByAvg:
+		return nil
+	default:
+		return fmt.Errorf("unknown top n statistic: %q", t)
+	}
+}
+
+// statistic calculates the value used for ranking from all non-null values of a series.
+// ok is false if the series has no values.
+func (t TopNBy) statistic(values []float64) (result float64, ok bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	switch t {
+	case TopNByMax:
+		result = math.Inf(-1)
+		for _, value := range values {
+			result = math.Max(result, value)
+		}
+	case TopNByAvg:
+		for _, value := range values {
+			result += value
+		}
+		result /= float64(len(values))
+	default:
+		result = values[len(values)-1]
+	}
+
+	return result, true
+}
+
+// topNFrames only keeps the frames of the n resources with the highest statistic. The statistic is calculated for
+// every series of a resource and then summed up, so resources with multiple series (ie. bandwidth in & out) are
+// ranked by their total. Resources with equal statistics are ranked by their [LabelID] to keep the result stable.
+//
+// The order of the returned frames matches the input order.
+func topNFrames(frames []*data.Frame, n int, by TopNBy) []*data.Frame {
+	if n <= 0 {
+		return frames
+	}
+
+	scores := make(map[string]float64)
+	for _, frame := range frames {
+		if len(frame.Fields) == 0 {
+			continue
+		}
+		valuesField := frame.Fields[len(frame.Fields)-1]
+		id := valuesField.Labels[LabelID]
+
+		values := make([]float64, 0, valuesField.Len())
+		for i := 0; i < valuesField.Len(); i++ {
+			if value, ok := valuesField.At(i).(*float64); ok && value != nil {
+				values = append(values, *value)
+			}
+		}
+
+		if _, ok := scores[id]; !ok {
+			scores[id] = 0
+		}
+		if statistic, ok := by.statistic(values); ok {
+			scores[id] += statistic
+		}
+	}
+
+	if len(scores) <= n {
+		return frames
+	}
+
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		if c := cmp.Compare(scores[b], scores[a]); c != 0 {
+			return c
+		}
+		return compareIDs(a, b)
+	})
+
+	keep := make(map[string]bool, n)
+	for _, id := range ids[:n] {
+		keep[id] = true
+	}
+
+	filtered := make([]*data.Frame, 0, len(frames))
+	for _, frame := range frames {
+		if len(frame.Fields) > 0 && keep[frame.Fields[len(frame.Fields)-1].Labels[LabelID]] {
+			filtered = append(filtered, frame)
+		}
+	}
+
+	return filtered
+}
+
+// compareIDs compares two [LabelID] values numerically, falling back to a string comparison for invalid ids.
+func compareIDs(a, b string) int {
+	idA, errA := strconv.ParseInt(a, 10, 64)
+	idB, errB := strconv.ParseInt(b, 10, 64)
+	if errA != nil || errB != nil {
+		return cmp.Compare(a, b)
+	}
+	return cmp.Compare(idA, idB)
+}
+
+// sameResourceFrames only keeps the frames of the resources that have a frame in reference, ie. to restrict a previous
+// period to the top N resources of the current period. The order of the returned frames matches the input order.
+func sameResourceFrames(frames []*data.Frame, reference []*data.Frame) []*data.Frame {
+	keep := make(map[string]bool, len(reference))
+	for _, frame := range reference {
+		if len(frame.Fields) > 0 {
+			keep[frame.Fields[len(frame.Fields)-1].Labels[LabelID]] = true
+		}
+	}
+
+	filtered := make([]*data.Frame, 0, len(frames))
+	for _, frame := range frames {
+		if len(frame.Fields) > 0 && keep[frame.Fields[len(frame.Fields)-1].Labels[LabelID]] {
+			filtered = append(filtered, frame)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func Test_topNFrames(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	frame := func(id string, seriesName string, values ...*float64) *data.Frame {
+		times := make([]time.Time, 0, len(values))
+		for i := range values {
+			times = append(times, time.Unix(int64(i), 0))
+		}
+		return data.NewFrame("",
+			data.NewField("time", nil, times),
+			data.NewField(seriesName, data.Labels{LabelID: id, LabelSeriesName: seriesName}, values),
+		)
+	}
+	ids := func(frames []*data.Frame) []string {
+		result := make([]string, 0, len(frames))
+		for _, f := range frames {
+			result = append(result, f.Fields[1].Labels[LabelID]+"/"+f.Fields[1].Labels[LabelSeriesName])
+		}
+		return result
+	}
+
+	frames := []*data.Frame{
+		frame("1", "in", ptr(10), ptr(1)),
+		frame("1", "out", ptr(0), ptr(1)),
+		frame("2", "in", ptr(1), ptr(5)),
+		frame("2", "out", ptr(1), nil),
+		frame("3", "in", ptr(4), ptr(2)),
+		frame("10", "in", ptr(4), ptr(2)),
+	}
+
+	tests := []struct {
+		name string
+		n    int
+		by   TopNBy
+		want []string
+	}{
+		{name: "disabled", n: 0, by: TopNByLast, want: []string{"1/in", "1/out", "2/in", "2/out", "3/in", "10/in"}},
+		{name: "last", n: 1, by: TopNByLast, want: []string{"2/in", "2/out"}},
+		{name: "max", n: 1, by: TopNByMax, want: []string{"1/in", "1/out"}},
+		{name: "avg with tie broken by id", n: 3, by: TopNByAvg, want: []string{"1/in", "1/out", "2/in", "2/out", "3/in"}},
+		{name: "more than available", n: 10, by: TopNByLast, want: []string{"1/in", "1/out", "2/in", "2/out", "3/in", "10/in"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(topNFrames(frames, tt.n, tt.by))
+			if len(got) != len(tt.want) {
+				t.Fatalf("topNFrames() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("topNFrames() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
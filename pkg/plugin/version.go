@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/grafana-plugin-sdk-go/build"
+)
+
+// Version is the version of the plugin if the build info of the plugin SDK is not available, ie. because the backend
+// was not built with mage. It can be set with `-ldflags "-X
+// github.com/apricote/grafana-hcloud-datasource/pkg/plugin.Version=1.2.3"`.
+var Version = ""
+
+// UnknownVersion is used in the user agent if the version could not be determined.
+const UnknownVersion = "unknown"
+
+// pluginVersion returns the version of the plugin for the user agent, so Hetzner Cloud support can tell the plugin
+// versions apart. The build info of the plugin SDK is used first, then [Version], and finally the plugin.json that is
+// distributed next to the plugin executable.
+func pluginVersion(ctx context.Context) string {
+	ctxLogger := logger.FromContext(ctx)
+
+	buildInfo, err := build.GetBuildInfo()
+	if err == nil && buildInfo.Version != "" {
+		return buildInfo.Version
+	}
+	if err != nil {
+		ctxLogger.Warn("get build info failed", "error", err)
+	}
+
+	if Version != "" {
+		return Version
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		ctxLogger.Warn("failed to locate plugin executable", "error", err)
+		return UnknownVersion
+	}
+	version, err := versionFromPluginJSON(filepath.Join(filepath.Dir(executable), "plugin.json"))
+	if err != nil {
+		ctxLogger.Warn("failed to read version from plugin.json", "error", err)
+		return UnknownVersion
+	}
+	if version == "" {
+		return UnknownVersion
+	}
+	return version
+}
+
+// versionFromPluginJSON returns the version from the plugin.json at path. It returns an empty version if the file still
+// contains the placeholder of the source tree.
+func versionFromPluginJSON(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var pluginJSON struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(content, &pluginJSON); err != nil {
+		return "", err
+	}
+
+	if pluginJSON.Info.Version == "%VERSION%" {
+		return "", nil
+	}
+	return pluginJSON.Info.Version, nil
+}
@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_versionFromPluginJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "released", content: `{"id": "apricote-hcloud-datasource", "info": {"version": "1.2.3"}}`, want: "1.2.3"},
+		{name: "source placeholder", content: `{"info": {"version": "%VERSION%"}}`, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plugin.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := versionFromPluginJSON(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("versionFromPluginJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := versionFromPluginJSON(filepath.Join(t.TempDir(), "plugin.json")); err == nil {
+		t.Error("versionFromPluginJSON() should fail for a missing file")
+	}
+}
@@ -27,6 +27,40 @@ func (s Set[T]) ToSlice() []T {
 	return elements
 }
 
+// Union returns a new set with the elements of both sets.
+func (s Set[T]) Union(other Set[T]) Set[T] {
+	union := make(Set[T], len(s)+len(other))
+	for element := range s {
+		union[element] = struct{}{}
+	}
+	for element := range other {
+		union[element] = struct{}{}
+	}
+	return union
+}
+
+// Intersection returns a new set with the elements that are in both sets.
+func (s Set[T]) Intersection(other Set[T]) Set[T] {
+	intersection := New[T]()
+	for element := range s {
+		if other.Has(element) {
+			intersection[element] = struct{}{}
+		}
+	}
+	return intersection
+}
+
+// Difference returns a new set with the elements of s that are not in other.
+func (s Set[T]) Difference(other Set[T]) Set[T] {
+	difference := New[T]()
+	for element := range s {
+		if !other.Has(element) {
+			difference[element] = struct{}{}
+		}
+	}
+	return difference
+}
+
 func From[T comparable](element ...T) Set[T] {
 	set := New[T]()
 	set.Insert(element...)
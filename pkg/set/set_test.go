@@ -0,0 +1,47 @@
+package set
+
+import (
+	"slices"
+	"testing"
+)
+
+func sorted(s Set[int]) []int {
+	elements := s.ToSlice()
+	slices.Sort(elements)
+	return elements
+}
+
+func TestSet_Union(t *testing.T) {
+	got := From(1, 2).Union(From(2, 3))
+	if want := []int{1, 2, 3}; !slices.Equal(sorted(got), want) {
+		t.Errorf("Union() = %v, want %v", sorted(got), want)
+	}
+
+	var empty Set[int]
+	if got := empty.Union(From(1)); !slices.Equal(sorted(got), []int{1}) {
+		t.Errorf("Union() of a nil set = %v, want [1]", sorted(got))
+	}
+}
+
+func TestSet_Intersection(t *testing.T) {
+	got := From(1, 2, 3).Intersection(From(2, 3, 4))
+	if want := []int{2, 3}; !slices.Equal(sorted(got), want) {
+		t.Errorf("Intersection() = %v, want %v", sorted(got), want)
+	}
+
+	if got := From(1).Intersection(New[int]()); len(got) != 0 {
+		t.Errorf("Intersection() with an empty set = %v, want empty set", sorted(got))
+	}
+}
+
+func TestSet_Difference(t *testing.T) {
+	a := From(1, 2, 3)
+	got := a.Difference(From(2, 4))
+	if want := []int{1, 3}; !slices.Equal(sorted(got), want) {
+		t.Errorf("Difference() = %v, want %v", sorted(got), want)
+	}
+
+	if !a.Has(2) {
+		t.Errorf("Difference() must not modify the receiver")
+	}
+}
@@ -1,5 +1,5 @@
 import React, { ChangeEvent } from 'react';
-import { Badge, Checkbox, FieldSet, Icon, InlineField, LinkButton, SecretInput, VerticalGroup } from '@grafana/ui';
+import { Badge, Checkbox, FieldSet, Icon, InlineField, Input, LinkButton, SecretInput, VerticalGroup } from '@grafana/ui';
 import { DataSourcePluginOptionsEditorProps } from '@grafana/data';
 import { DataSourceOptions, SecureJsonData } from '../types';
 import { OptionGroup } from './OptionGroup';
@@ -54,11 +54,144 @@ export function ConfigEditor(props: Props) {
     });
   };
 
+  const onPreloadNameCacheChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        preloadNameCache: event.target.checked,
+      },
+    });
+  };
+
+  const onEmptySelectionMeansAllChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        emptySelectionMeansAll: event.target.checked,
+      },
+    });
+  };
+
+  const onIncludeProjectLabelChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        includeProjectLabel: event.target.checked,
+      },
+    });
+  };
+
+  const onProjectNameChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        projectName: event.target.value,
+      },
+    });
+  };
+
+  const onVarFormatChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        varFormat: event.target.value,
+      },
+    });
+  };
+
+  const onQueryConcurrencyChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        queryConcurrency: event.target.value === '' ? undefined : parseInt(event.target.value, 10),
+      },
+    });
+  };
+
+  const onMaxPointsPerSeriesChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        maxPointsPerSeries: event.target.value === '' ? undefined : parseInt(event.target.value, 10),
+      },
+    });
+  };
+
+  const onMaxResourcesChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        maxResources: event.target.value === '' ? undefined : parseInt(event.target.value, 10),
+      },
+    });
+  };
+
+  const onTrailingBucketsToDropChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        trailingBucketsToDrop: event.target.value === '' ? undefined : parseInt(event.target.value, 10),
+      },
+    });
+  };
+
+  const onDecimalsChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        decimals: event.target.value === '' ? undefined : parseInt(event.target.value, 10),
+      },
+    });
+  };
+
+  const onDefaultLegendFormatChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        defaultLegendFormat: event.target.value,
+      },
+    });
+  };
+
+  const onDisableBufferingChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        disableBuffering: event.target.checked,
+      },
+    });
+  };
+
+  const onSingleResourceFastPathChange = (event: ChangeEvent<HTMLInputElement>) => {
+    onOptionsChange({
+      ...options,
+      jsonData: {
+        ...options.jsonData,
+        singleResourceFastPath: event.target.checked,
+      },
+    });
+  };
+
   const { secureJsonFields } = options;
   const secureJsonData = (options.secureJsonData || {}) as SecureJsonData;
   const jsonData = options.jsonData;
 
-  const collapsedInfoList = [`Debug Logging: ${jsonData.debug ? 'Enabled' : 'Disabled'}`];
+  const collapsedInfoList = [
+    `Debug Logging: ${jsonData.debug ? 'Enabled' : 'Disabled'}`,
+    `Buffering: ${jsonData.disableBuffering ? 'Disabled' : 'Enabled'}`,
+  ];
 
   return (
     <div className="gf-form-group">
@@ -98,6 +231,138 @@ export function ConfigEditor(props: Props) {
           />
         </InlineField>
       </FieldSet>
+      <FieldSet label={'Queries'}>
+        <InlineField
+          label="Default Legend Format"
+          labelWidth={24}
+          tooltip="Used for all metrics queries that do not set their own legend format. Leave empty to use the automatic legend."
+        >
+          <Input
+            value={jsonData.defaultLegendFormat || ''}
+            placeholder="{{ series_display_name }} {{ name }}"
+            width={64}
+            onChange={onDefaultLegendFormatChange}
+          />
+        </InlineField>
+        <InlineField
+          label="Variable Format"
+          labelWidth={24}
+          tooltip="Format of the var field returned by resource list queries. Supports the labels id and name."
+        >
+          <Input
+            value={jsonData.varFormat || ''}
+            placeholder="{{ name }} : {{ id }}"
+            width={64}
+            onChange={onVarFormatChange}
+          />
+        </InlineField>
+        <InlineField
+          label="Query Concurrency"
+          labelWidth={24}
+          tooltip="Maximum number of queries and metrics API requests that are processed in parallel. Defaults to 10."
+        >
+          <Input
+            type="number"
+            min={1}
+            value={jsonData.queryConcurrency ?? ''}
+            placeholder="10"
+            width={16}
+            onChange={onQueryConcurrencyChange}
+          />
+        </InlineField>
+        <InlineField
+          label="Max Points Per Series"
+          labelWidth={24}
+          tooltip="If a metrics query would return more data points per series, the step is raised. Defaults to 10000, 0 disables the limit."
+        >
+          <Input
+            type="number"
+            min={0}
+            value={jsonData.maxPointsPerSeries ?? ''}
+            placeholder="10000"
+            width={16}
+            onChange={onMaxPointsPerSeriesChange}
+          />
+        </InlineField>
+        <InlineField
+          label="Max Resources"
+          labelWidth={24}
+          tooltip="Queries that select more resources fail, as every resource requires one API request. Defaults to 100."
+        >
+          <Input
+            type="number"
+            min={1}
+            value={jsonData.maxResources ?? ''}
+            placeholder="100"
+            width={16}
+            onChange={onMaxResourcesChange}
+          />
+        </InlineField>
+        <InlineField
+          label="Drop Incomplete Buckets"
+          labelWidth={24}
+          tooltip="Maximum number of data points at the end of every series that are dropped while their step has not ended yet. Set to 0 to keep them. Defaults to 1."
+        >
+          <Input
+            type="number"
+            min={0}
+            value={jsonData.trailingBucketsToDrop ?? ''}
+            placeholder="1"
+            width={16}
+            onChange={onTrailingBucketsToDropChange}
+          />
+        </InlineField>
+        <InlineField
+          label="Decimals"
+          labelWidth={24}
+          tooltip="Number of decimals shown for all metrics values. Panels can still override it. Leave empty to let Grafana decide."
+        >
+          <Input
+            type="number"
+            min={0}
+            value={jsonData.decimals ?? ''}
+            placeholder="auto"
+            width={16}
+            onChange={onDecimalsChange}
+          />
+        </InlineField>
+        <Checkbox
+          value={jsonData.preloadNameCache}
+          label={'Preload Resource Names'}
+          description={
+            'Load the names of all servers & load balancers when the data source starts. Disable for projects with many resources.'
+          }
+          onChange={onPreloadNameCacheChange}
+        ></Checkbox>
+        <Checkbox
+          value={jsonData.emptySelectionMeansAll}
+          label={'Empty Selection Means All'}
+          description={
+            'Queries without a label selector, IDs or names return all resources of the project. By default, they return no resources.'
+          }
+          onChange={onEmptySelectionMeansAllChange}
+        ></Checkbox>
+        <Checkbox
+          value={jsonData.includeProjectLabel}
+          label={'Include Project Label'}
+          description={'Add the label project to all metrics, to tell apart series from multiple projects in one panel.'}
+          onChange={onIncludeProjectLabelChange}
+        ></Checkbox>
+        {jsonData.includeProjectLabel && (
+          <InlineField
+            label="Project Name"
+            labelWidth={24}
+            tooltip="Value of the project label. Leave empty to use the name of the data source."
+          >
+            <Input
+              value={jsonData.projectName || ''}
+              placeholder={options.name}
+              width={64}
+              onChange={onProjectNameChange}
+            />
+          </InlineField>
+        )}
+      </FieldSet>
       <FieldSet label={'Development'}>
         <p>These option are used to develop the Datasource. It should not be necessary to set them in production.</p>
         <OptionGroup title="Options" collapsedInfo={collapsedInfoList}>
@@ -108,6 +373,22 @@ export function ConfigEditor(props: Props) {
               description={'Enable to see all requests & responses with the Hetzner Cloud API in the Grafana Logs'}
               onChange={onDebugChange}
             ></Checkbox>
+            <Checkbox
+              value={jsonData.disableBuffering}
+              label={'Disable Buffering'}
+              description={
+                'Send metrics requests right away instead of combining requests from multiple panels. Reduces latency, but uses more of the API rate limit.'
+              }
+              onChange={onDisableBufferingChange}
+            ></Checkbox>
+            <Checkbox
+              value={jsonData.singleResourceFastPath}
+              label={'Single Resource Fast Path'}
+              description={
+                'Send metrics requests for a single resource right away while no other requests are buffered. Reduces latency of small dashboards, but panels showing the same resource might each send their own request.'
+              }
+              onChange={onSingleResourceFastPathChange}
+            ></Checkbox>
           </VerticalGroup>
         </OptionGroup>
       </FieldSet>
@@ -1,7 +1,7 @@
 import { AutoSizeInput, InlineField } from '@grafana/ui';
 import React from 'react';
 
-const LABELS = ['id', 'name', 'series_name', 'series_display_name'];
+const LABELS = ['id', 'name', 'series_name', 'series_display_name', 'unit', 'direction', 'project'];
 
 interface LegendFormatFieldProps {
   legendFormat: string;
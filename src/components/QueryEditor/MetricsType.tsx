@@ -9,6 +9,8 @@ const serverOptions = [
   { value: ServerMetricsTypes.DiskIOPS, label: 'Disk IOPS' },
   { value: ServerMetricsTypes.NetworkBandwidth, label: 'Network Bandwidth' },
   { value: ServerMetricsTypes.NetworkPPS, label: 'Network PPS' },
+  { value: ServerMetricsTypes.PublicNetworkBandwidth, label: 'Public Network Bandwidth' },
+  { value: ServerMetricsTypes.PrivateNetworkBandwidth, label: 'Private Network Bandwidth' },
 ];
 
 const lbOptions = [
@@ -18,9 +18,11 @@ import { LabelSelectorField } from './LabelSelector';
 import { LegendFormatField } from './LegendFormat';
 import { QueryTypeField } from './QueryType';
 import { ResourceSelectorField } from './ResourceSelector';
+import { ResourcesField } from './Resources';
 import { MetricsTypeField } from './MetricsType';
 import { ResourceTypeField } from './ResourceType';
 import { SelectByField } from './SelectBy';
+import { StepField } from './Step';
 import { VariableSelectorField } from './VariableSelector';
 
 type Props = QueryEditorProps<DataSource, Query, DataSourceOptions>;
@@ -34,7 +36,10 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
     labelSelectors = [],
     resourceIDs = [],
     resourceIDsVariable = '',
+    resources = [],
     legendFormat = '',
+    step,
+    alignStepToBoundary,
   } = query;
 
   const onChangeRunQuery = useCallback(
@@ -71,7 +76,11 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
   return (
     <>
       <InlineFieldRow>
-        <ResourceTypeField resourceType={resourceType} onChange={onResourceTypeChange} />
+        <ResourceTypeField
+          resourceType={resourceType}
+          includeNetworks={queryType === QueryType.ResourceList}
+          onChange={onResourceTypeChange}
+        />
         {queryType === QueryType.Metrics && (
           <MetricsTypeField
             metricsType={metricsType}
@@ -87,7 +96,13 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
             onChange={(v) => onChangeRunQuery({ ...query, labelSelectors: v })}
           />
         )}
-        {queryType === QueryType.Metrics && (
+        {(queryType === QueryType.Metrics ||
+          queryType === QueryType.ServerSpecs ||
+          queryType === QueryType.ServerStatus ||
+          queryType === QueryType.ServerTraffic ||
+          queryType === QueryType.ServerTrafficRemaining ||
+          queryType === QueryType.Actions ||
+          queryType === QueryType.Events) && (
           <>
             <SelectByField selectBy={selectBy} onChange={(selectBy) => onChangeRunQuery({ ...query, selectBy })} />
             {selectBy === SelectBy.ID && (
@@ -104,6 +119,9 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
                 onChange={(labelSelectors) => onChangeRunQuery({ ...query, labelSelectors })}
               />
             )}
+            {selectBy === SelectBy.Resource && (
+              <ResourcesField values={resources} onChange={(resources) => onChangeRunQuery({ ...query, resources })} />
+            )}
             {selectBy === SelectBy.Name && (
               <VariableSelectorField
                 variable={resourceIDsVariable}
@@ -121,6 +139,14 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
             legendFormat={legendFormat}
             onChange={(legendFormat) => onChangeRunQuery({ ...query, legendFormat })}
           />
+          {queryType === QueryType.Metrics && (
+            <StepField
+              step={step}
+              alignStepToBoundary={alignStepToBoundary}
+              onChange={(step) => onChangeRunQuery({ ...query, step })}
+              onAlignChange={(alignStepToBoundary) => onChangeRunQuery({ ...query, alignStepToBoundary })}
+            />
+          )}
         </OptionGroup>
       </InlineFieldRow>
     </>
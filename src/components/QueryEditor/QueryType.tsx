@@ -6,6 +6,12 @@ import type { SelectableValue } from '@grafana/data';
 const queryTypes: Array<SelectableValue<QueryType>> = [
   { label: 'Metrics', value: QueryType.Metrics, icon: 'chart-line' },
   { label: 'Resource List', value: QueryType.ResourceList, icon: 'table' },
+  { label: 'Server Specs', value: QueryType.ServerSpecs, icon: 'info-circle' },
+  { label: 'Server Status', value: QueryType.ServerStatus, icon: 'heart-rate' },
+  { label: 'Server Traffic', value: QueryType.ServerTraffic, icon: 'exchange-alt' },
+  { label: 'Traffic Remaining', value: QueryType.ServerTrafficRemaining, icon: 'exchange-alt' },
+  { label: 'Actions', value: QueryType.Actions, icon: 'history' },
+  { label: 'Events', value: QueryType.Events, icon: 'list-ul' },
 ];
 
 interface QueryTypeFieldProps {
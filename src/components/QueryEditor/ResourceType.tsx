@@ -7,15 +7,27 @@ const resourceTypes = [
   { label: 'Load Balancer', value: ResourceType.LoadBalancer },
 ];
 
+// Networks and placement groups have no metrics, so they are only available for resource lists
+const resourceListTypes = [
+  ...resourceTypes,
+  { label: 'Network', value: ResourceType.Network },
+  { label: 'Placement Group', value: ResourceType.PlacementGroup },
+];
+
 interface ResourceTypeFieldProps {
   resourceType: ResourceType;
+  includeNetworks?: boolean;
   onChange: (resourceType: ResourceType) => void;
 }
 
-export function ResourceTypeField({ resourceType, onChange }: ResourceTypeFieldProps) {
+export function ResourceTypeField({ resourceType, includeNetworks = false, onChange }: ResourceTypeFieldProps) {
   return (
     <InlineField label="Resource Type">
-      <Select options={resourceTypes} value={resourceType} onChange={(value) => onChange(value.value!)}></Select>
+      <Select
+        options={includeNetworks ? resourceListTypes : resourceTypes}
+        value={resourceType}
+        onChange={(value) => onChange(value.value!)}
+      ></Select>
     </InlineField>
   );
 }
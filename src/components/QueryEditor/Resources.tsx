@@ -0,0 +1,18 @@
+import { InlineField, TagsInput } from '@grafana/ui';
+import React from 'react';
+
+interface ResourcesFieldProps {
+  values: string[];
+  onChange: (values: string[]) => void;
+}
+
+export function ResourcesField({ values, onChange }: ResourcesFieldProps) {
+  return (
+    <InlineField
+      label={'Resources'}
+      tooltip={'IDs and names, e.g. "web-1, 12345, db-prod". Numbers are used as IDs, everything else as a name.'}
+    >
+      <TagsInput tags={values} onChange={onChange} placeholder={'IDs or names (enter key to add)'} />
+    </InlineField>
+  );
+}
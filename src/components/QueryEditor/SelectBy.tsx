@@ -10,6 +10,7 @@ interface SelectByFieldProps {
 const selectByOptions = [
   { label: 'IDs', value: SelectBy.ID, icon: 'gf-layout-simple' },
   { label: 'Labels', value: SelectBy.Label, icon: 'filter' },
+  { label: 'IDs & Names', value: SelectBy.Resource, icon: 'list-ul' },
   { label: 'Variable', value: SelectBy.Name, icon: 'grafana' },
 ];
 
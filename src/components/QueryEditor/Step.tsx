@@ -0,0 +1,42 @@
+import { AutoSizeInput, InlineField, InlineSwitch } from '@grafana/ui';
+import React from 'react';
+
+interface StepFieldProps {
+  step?: number;
+  alignStepToBoundary?: boolean;
+  onChange: (step: number | undefined) => void;
+  onAlignChange: (alignStepToBoundary: boolean) => void;
+}
+export function StepField({ step, alignStepToBoundary, onChange, onAlignChange }: StepFieldProps) {
+  return (
+    <>
+      <InlineField
+        label={'Step'}
+        tooltip={
+          'Resolution of the metrics in seconds. Leave empty to calculate it from the panel interval. Large time ranges might still use a larger step.'
+        }
+      >
+        <AutoSizeInput
+          type="number"
+          min={1}
+          value={step ?? ''}
+          placeholder={'Auto'}
+          minLength={8}
+          onCommitChange={(e) => {
+            const value = parseInt(e.currentTarget.value, 10);
+            onChange(Number.isNaN(value) || value <= 0 ? undefined : value);
+          }}
+        ></AutoSizeInput>
+      </InlineField>
+      <InlineField
+        label={'Align Step'}
+        tooltip={'Align the data points to multiples of the step, e.g. to the top of the hour for a step of 3600s.'}
+      >
+        <InlineSwitch
+          value={alignStepToBoundary ?? false}
+          onChange={(e) => onAlignChange(e.currentTarget.checked)}
+        ></InlineSwitch>
+      </InlineField>
+    </>
+  );
+}
@@ -1,7 +1,19 @@
 import { DataSourceInstanceSettings, CoreApp, SelectableValue, ScopedVars } from '@grafana/data';
 import { DataSourceWithBackend, getTemplateSrv } from '@grafana/runtime';
 
-import { Query, DataSourceOptions, DEFAULT_QUERY, SelectBy } from './types';
+import {
+  Query,
+  DataSourceOptions,
+  DEFAULT_QUERY,
+  SelectBy,
+  CacheRefreshResult,
+  CacheStats,
+  QueryRunnerStatsResponse,
+  MetricsTypeInfo,
+  NetworkZoneInfo,
+  SelectableValueWithLabels,
+  ResourceType,
+} from './types';
 import { VariableSupport } from './variables';
 
 export class DataSource extends DataSourceWithBackend<Query, DataSourceOptions> {
@@ -9,6 +21,8 @@ export class DataSource extends DataSourceWithBackend<Query, DataSourceOptions>
     super(instanceSettings);
 
     this.variables = new VariableSupport();
+    // Annotation queries use the regular query editor, the Actions query type returns annotation frames
+    this.annotations = {};
   }
 
   applyTemplateVariables(query: Query, scopedVars: ScopedVars): Query {
@@ -18,6 +32,10 @@ export class DataSource extends DataSourceWithBackend<Query, DataSourceOptions>
       query.labelSelectors = query.labelSelectors.map((selector) => templateSrv.replace(selector, scopedVars, 'json'));
     }
 
+    if (query.resources) {
+      query.resources = query.resources.map((resource) => templateSrv.replace(resource, scopedVars, 'csv'));
+    }
+
     if (query.selectBy === SelectBy.Name) {
       query.selectBy = SelectBy.ID;
 
@@ -37,14 +55,58 @@ export class DataSource extends DataSourceWithBackend<Query, DataSourceOptions>
     return DEFAULT_QUERY;
   }
 
-  async getServers(): Promise<Array<SelectableValue<number>>> {
-    return this.getResource('servers');
+  async getServers(selector?: string): Promise<Array<SelectableValue<number>>> {
+    return this.getResource('servers', selector ? { selector } : undefined);
+  }
+
+  async getServersWithLabels(): Promise<Array<SelectableValueWithLabels<number>>> {
+    return this.getResource('servers', { withLabels: true });
+  }
+
+  async searchServers(search: string): Promise<Array<SelectableValue<number>>> {
+    return this.getResource('servers/search', { q: search });
+  }
+
+  async getServerStatusCounts(): Promise<Record<string, number>> {
+    return this.getResource('servers/status-counts');
   }
 
   async getLoadBalancers(): Promise<Array<SelectableValue<number>>> {
     return this.getResource('load-balancers');
   }
 
+  async getLoadBalancersWithLabels(): Promise<Array<SelectableValueWithLabels<number>>> {
+    return this.getResource('load-balancers', { withLabels: true });
+  }
+
+  async getPlacementGroups(): Promise<Array<SelectableValue<number>>> {
+    return this.getResource('placement-groups');
+  }
+
+  async resolveLabelSelector(resourceType: ResourceType, selector: string): Promise<Array<SelectableValue<number>>> {
+    return this.getResource('resolve', { type: resourceType, selector });
+  }
+
+  async refreshCache(warm = false): Promise<CacheRefreshResult> {
+    return this.postResource('cache/refresh' + (warm ? '?warm=true' : ''));
+  }
+
+  async getNetworkZones(): Promise<NetworkZoneInfo[]> {
+    return this.getResource('network-zones');
+  }
+
+  async getMetricsTypes(resourceType: ResourceType): Promise<MetricsTypeInfo[]> {
+    return this.getResource('metric-types', { resourceType });
+  }
+
+  async getCacheStats(): Promise<CacheStats> {
+    return this.getResource('cache/stats');
+  }
+
+  async getQueryRunnerStats(): Promise<QueryRunnerStatsResponse> {
+    return this.getResource('query-runner/stats');
+  }
+
   filterQuery(query: Query): boolean {
     if (query.selectBy === SelectBy.Name && query.resourceIDsVariable === '') {
       return false;
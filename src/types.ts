@@ -1,14 +1,22 @@
-import { DataSourceJsonData } from '@grafana/data';
+import { DataSourceJsonData, SelectableValue } from '@grafana/data';
 import { DataQuery } from '@grafana/schema';
 
 export enum QueryType {
   ResourceList = 'resource-list',
   Metrics = 'metrics',
+  ServerSpecs = 'server-specs',
+  ServerStatus = 'server-status',
+  ServerTraffic = 'server-traffic',
+  ServerTrafficRemaining = 'server-traffic-remaining',
+  Actions = 'actions',
+  Events = 'events',
 }
 
 export enum ResourceType {
   Server = 'server',
   LoadBalancer = 'load-balancer',
+  Network = 'network',
+  PlacementGroup = 'placement-group',
 }
 
 export enum ServerMetricsTypes {
@@ -17,6 +25,8 @@ export enum ServerMetricsTypes {
   DiskIOPS = 'disk-iops',
   NetworkBandwidth = 'network-bandwidth',
   NetworkPPS = 'network-pps',
+  PublicNetworkBandwidth = 'public-network-bandwidth',
+  PrivateNetworkBandwidth = 'private-network-bandwidth',
 }
 
 export enum LoadBalancerMetricsTypes {
@@ -32,19 +42,72 @@ export enum SelectBy {
   Label = 'label',
   ID = 'id',
   Name = 'name',
+  ResourceName = 'resource-name',
+  Resource = 'resource',
+}
+
+export enum Aggregation {
+  None = 'none',
+  Sum = 'sum',
+  Avg = 'avg',
+  Max = 'max',
+}
+
+export enum OutputFormat {
+  Long = 'long',
+  Wide = 'wide',
+}
+
+export enum TopNBy {
+  Last = 'last',
+  Max = 'max',
+  Avg = 'avg',
+}
+
+export enum SeriesSort {
+  None = 'none',
+  LastAsc = 'last-asc',
+  LastDesc = 'last-desc',
+  Name = 'name',
 }
 
 export interface Query extends DataQuery {
   queryType: QueryType;
   resourceType: ResourceType;
+  resourceTypes?: ResourceType[];
   metricsType: MetricsType;
+  metricsTypes?: MetricsType[];
 
   selectBy: SelectBy;
   labelSelectors: string[];
   resourceIDs: number[];
   resourceIDsVariable: string;
+  resourceNames?: string[];
+  resources?: string[];
+  nameFilter?: string;
+  savedSelector?: string;
+  excludeLabelSelectors?: string[];
+  statusSelector?: string[];
 
   legendFormat: string;
+  frameName?: string;
+  aggregation?: Aggregation;
+  topN?: number;
+  topNBy?: TopNBy;
+  seriesSort?: SeriesSort;
+  debug?: boolean;
+  limit?: number;
+  networkId?: number;
+  step?: number;
+  alignStepToBoundary?: boolean;
+  instant?: boolean;
+  compareOffset?: string;
+  outputFormat?: OutputFormat;
+  checkOtherResourceType?: boolean;
+  unitOverrides?: Record<string, string>;
+  rawValues?: boolean;
+  includeSubnets?: boolean;
+  includePrices?: boolean;
 }
 
 export const DEFAULT_QUERY: Partial<Query> = {
@@ -66,11 +129,100 @@ export const DEFAULT_VARIABLE_QUERY: Partial<Query> = {
   resourceIDs: [],
 };
 
+export interface SelectableValueWithLabels<T> extends SelectableValue<T> {
+  labels?: Record<string, string>;
+}
+
+export interface SeriesInfo {
+  name: string;
+  displayName: string;
+  unit: string;
+}
+
+export interface MetricsTypeInfo {
+  value: MetricsType;
+  label: string;
+  series: SeriesInfo[];
+}
+
+export interface NameCacheStats {
+  entries: number;
+  hits: number;
+  misses: number;
+  errors: number;
+}
+
+export interface CacheStats {
+  servers: NameCacheStats;
+  loadBalancers: NameCacheStats;
+}
+
+export interface QueryRunnerStats {
+  flushes: number;
+  requests: number;
+  fetches: number;
+  coalescingRatio: number;
+}
+
+export interface QueryRunnerStatsResponse {
+  servers: QueryRunnerStats;
+  loadBalancers: QueryRunnerStats;
+}
+
+export interface NetworkZoneInfo {
+  value: string;
+  label: string;
+  locations: string[];
+}
+
+export interface NameCacheConfig {
+  ttlSeconds: number;
+  size: number;
+}
+
+export interface CacheRefreshResult {
+  clearedServers: number;
+  clearedLoadBalancers: number;
+  warmed: boolean;
+}
+
 /**
  * These are options configured for each DataSource instance
  */
 export interface DataSourceOptions extends DataSourceJsonData {
   debug: boolean;
+  defaultLegendFormat?: string;
+  varFormat?: string;
+  nameCacheSize?: number;
+  nameCacheTTLSeconds?: number;
+  preloadNameCache?: boolean;
+  queryConcurrency?: number;
+  apiTimeoutSeconds?: number;
+  disableBuffering?: boolean;
+  singleResourceFastPath?: boolean;
+  seriesOverrides?: Record<string, SeriesOverride>;
+  emptySelectionMeansAll?: boolean;
+  maxPointsPerSeries?: number;
+  maxResources?: number;
+  trailingBucketsToDrop?: number;
+  decimals?: number;
+  healthCheckCacheSeconds?: number;
+  includeProjectLabel?: boolean;
+  projectName?: string;
+  appIdentifier?: string;
+  thresholds?: Record<string, ThresholdStep[]>;
+  savedSelectors?: Record<string, string>;
+  customHeaders?: Record<string, string>;
+}
+
+export interface SeriesOverride {
+  displayName?: string;
+  unit?: string;
+}
+
+export interface ThresholdStep {
+  value?: number;
+  color: string;
 }
 
 /**